@@ -0,0 +1,42 @@
+package buildinfo
+
+import (
+	"runtime/debug"
+)
+
+// Version is set at build time with -ldflags "-X github.com/buglloc/sowettybot/internal/buildinfo.Version=v1.2.3".
+var Version = ""
+
+// String returns the version, falls back to the VCS revision Go stamps into binaries.
+func String() string {
+	if Version != "" {
+		return Version
+	}
+
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "unknown"
+	}
+
+	var revision, modified string
+	for _, s := range info.Settings {
+		switch s.Key {
+		case "vcs.revision":
+			revision = s.Value
+		case "vcs.modified":
+			if s.Value == "true" {
+				modified = "-dirty"
+			}
+		}
+	}
+
+	if revision == "" {
+		return info.Main.Version
+	}
+
+	if len(revision) > 12 {
+		revision = revision[:12]
+	}
+
+	return revision + modified
+}
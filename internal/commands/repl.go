@@ -0,0 +1,31 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/spf13/cobra"
+
+	"github.com/buglloc/sowettybot/internal/service"
+)
+
+var replCmd = &cobra.Command{
+	Use:           "repl",
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	Short:         "Runs bot commands in the terminal, no bot token required",
+	RunE: func(_ *cobra.Command, _ []string) error {
+		repl, err := service.NewRepl(cfg, os.Stdin, os.Stdout)
+		if err != nil {
+			return fmt.Errorf("unable to create repl: %w", err)
+		}
+
+		ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+		defer cancel()
+
+		return repl.Run(ctx)
+	},
+}
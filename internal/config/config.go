@@ -14,12 +14,52 @@ type RateIT struct {
 
 type Telegram struct {
 	APIKey string `yaml:"api_key"`
+	// MediaChatID is a chat where charts are uploaded to get a file_id for inline query results
+	MediaChatID int     `yaml:"media_chat_id"`
+	Webhook     Webhook `yaml:"webhook"`
+}
+
+// Webhook receives updates over HTTP instead of long polling.
+type Webhook struct {
+	Enabled bool `yaml:"enabled"`
+	// URL is the public address Telegram posts updates to, its path is served
+	URL    string `yaml:"url"`
+	Listen string `yaml:"listen"`
+	// CertFile and KeyFile serve HTTPS, leave them empty behind a reverse proxy terminating TLS
+	CertFile string `yaml:"cert_file"`
+	KeyFile  string `yaml:"key_file"`
+	// SecretToken is checked against the X-Telegram-Bot-Api-Secret-Token header, a random one is used if empty
+	SecretToken string `yaml:"secret_token"`
 }
 
 type History struct {
 	StorageFile string `yaml:"storage_file"`
 }
 
+type Storage struct {
+	// Dir keeps subscriptions, preferences and the like, an empty one disables persistence
+	Dir string `yaml:"dir"`
+}
+
+type Digest struct {
+	Timezone string `yaml:"timezone"`
+}
+
+type Best struct {
+	ReferenceAmount float64 `yaml:"reference_amount"`
+	// MaxAge marks rates older than it as stale
+	MaxAge time.Duration `yaml:"max_age"`
+}
+
+type Access struct {
+	// Enabled restricts the bot to allowed users and chats, anyone can use it otherwise
+	Enabled bool `yaml:"enabled"`
+	// Admins manage access and approve newcomers
+	Admins []int `yaml:"admins"`
+	Users  []int `yaml:"users"`
+	Chats  []int `yaml:"chats"`
+}
+
 type Exchange struct {
 	Name  string `yaml:"name"`
 	Slug  string `yaml:"slug"`
@@ -27,43 +67,129 @@ type Exchange struct {
 }
 
 type HistoryLimits struct {
+	// Overall is the number of last entries read at all, the weekly digest compares two weeks,
+	// so it takes 336 entries with hourly rates
 	Overall int `yaml:"overall"`
 	Short   int `yaml:"short"`
 	Long    int `yaml:"long"`
 }
 
+// RateLimit allows Burst commands at once, then one per Every, zero Burst disables it.
+type RateLimit struct {
+	Burst int           `yaml:"burst"`
+	Every time.Duration `yaml:"every"`
+}
+
 type Limits struct {
 	History HistoryLimits `yaml:"history"`
+	User    RateLimit     `yaml:"user"`
+	Chat    RateLimit     `yaml:"chat"`
+	// ChartRenders is the number of charts rendered at once, zero means unlimited
+	ChartRenders int        `yaml:"chart_renders"`
+	Send         SendLimits `yaml:"send"`
+}
+
+// SendLimits pace the messages sent to Telegram, which answers with 429 on floods.
+type SendLimits struct {
+	Global RateLimit `yaml:"global"`
+	Chat   RateLimit `yaml:"chat"`
+}
+
+type Escalation struct {
+	// Step notifies again every time the rate drops one more step below the threshold,
+	// ignoring cooldown and hysteresis
+	Step  float64  `yaml:"step"`
+	Sinks []string `yaml:"sinks"`
+}
+
+type Policy struct {
+	Cooldown   time.Duration `yaml:"cooldown"`
+	Precision  float64       `yaml:"precision"`
+	RearmDelta float64       `yaml:"rearm_delta"`
+	NewLowOnly bool          `yaml:"new_low_only"`
+	MaxPerDay  int           `yaml:"max_per_day"`
+	Escalation Escalation    `yaml:"escalation"`
 }
 
 type Notification struct {
-	Threshold float64 `yaml:"threshold"`
-	ChatID    int     `yaml:"chat_id"`
+	// Rule is an alert expression, e.g. "korona < contact - 0.02", threshold is ignored for rules
+	Rule      string   `yaml:"rule"`
+	Threshold float64  `yaml:"threshold"`
+	ChatID    int      `yaml:"chat_id"`
+	Sinks     []string `yaml:"sinks"`
+	Policy    Policy   `yaml:"policy"`
+}
+
+type SinkKind string
+
+const (
+	SinkKindTelegram SinkKind = "telegram"
+	SinkKindWebhook  SinkKind = "webhook"
+	SinkKindEmail    SinkKind = "email"
+	SinkKindLog      SinkKind = "log"
+	SinkKindStdout   SinkKind = "stdout"
+)
+
+type TelegramSink struct {
+	ChatID int `yaml:"chat_id"`
+}
+
+type WebhookSink struct {
+	URL     string            `yaml:"url"`
+	Headers map[string]string `yaml:"headers"`
+}
+
+type EmailSink struct {
+	Addr     string   `yaml:"addr"`
+	From     string   `yaml:"from"`
+	To       []string `yaml:"to"`
+	Subject  string   `yaml:"subject"`
+	Username string   `yaml:"username"`
+	Password string   `yaml:"password"`
+}
+
+type Sink struct {
+	Name     string       `yaml:"name"`
+	Kind     SinkKind     `yaml:"kind"`
+	Telegram TelegramSink `yaml:"telegram"`
+	Webhook  WebhookSink  `yaml:"webhook"`
+	Email    EmailSink    `yaml:"email"`
 }
 
 type Notifier struct {
 	CheckPeriod   time.Duration  `yaml:"check_period"`
+	Sinks         []Sink         `yaml:"sinks"`
 	Notifications []Notification `yaml:"notifications"`
 }
 
 type Config struct {
 	Debug     bool       `yaml:"debug"`
+	Language  string     `yaml:"language"`
 	RateIT    RateIT     `yaml:"rate_it"`
 	Telegram  Telegram   `yaml:"telegram"`
 	Notifier  Notifier   `yaml:"notifier"`
 	History   History    `yaml:"history"`
+	Storage   Storage    `yaml:"storage"`
+	Digest    Digest     `yaml:"digest"`
+	Best      Best       `yaml:"best"`
+	Access    Access     `yaml:"access"`
 	Exchanges []Exchange `yaml:"exchanges"`
 	Limits    Limits     `yaml:"limits"`
 }
 
 func LoadConfig(configs ...string) (*Config, error) {
 	out := &Config{
-		Debug: false,
+		Debug:    false,
+		Language: "en",
 		RateIT: RateIT{
 			Upstream: "http://127.0.0.1:3000",
 		},
 		Telegram: Telegram{
 			APIKey: os.Getenv("TG_TOKEN"),
+			Webhook: Webhook{
+				Listen:      ":8443",
+				SecretToken: os.Getenv("TG_WEBHOOK_SECRET"),
+			},
 		},
 		Limits: Limits{
 			History: HistoryLimits{
@@ -71,10 +197,36 @@ func LoadConfig(configs ...string) (*Config, error) {
 				Short:   72,
 				Long:    0,
 			},
+			User: RateLimit{
+				Burst: 5,
+				Every: 6 * time.Second,
+			},
+			Chat: RateLimit{
+				Burst: 10,
+				Every: 3 * time.Second,
+			},
+			ChartRenders: 2,
+			Send: SendLimits{
+				Global: RateLimit{
+					Burst: 30,
+					Every: time.Second / 30,
+				},
+				Chat: RateLimit{
+					Burst: 3,
+					Every: time.Second,
+				},
+			},
+		},
+		Storage: Storage{
+			Dir: "/var/lib/sowettybot",
 		},
 		Notifier: Notifier{
 			CheckPeriod: 10 * time.Minute,
 		},
+		Best: Best{
+			ReferenceAmount: 10000,
+			MaxAge:          time.Hour,
+		},
 		Exchanges: []Exchange{
 			{
 				Name:  "Contact (RU -> THB)",
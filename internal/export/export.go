@@ -0,0 +1,207 @@
+package export
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/buglloc/sowettybot/internal/config"
+	"github.com/buglloc/sowettybot/internal/models"
+)
+
+type Format string
+
+const (
+	FormatCSV  Format = "csv"
+	FormatJSON Format = "json"
+)
+
+var Formats = []Format{FormatCSV, FormatJSON}
+
+// Meta describes the exported range, times are written in Location.
+type Meta struct {
+	Exchanges []config.Exchange
+	Location  *time.Location
+	From      time.Time
+	To        time.Time
+	Generated time.Time
+}
+
+// FileName names the export after its range, e.g. "rates_20230601-20230615.csv".
+func FileName(meta Meta, format Format) string {
+	return fmt.Sprintf(
+		"rates_%s-%s.%s",
+		meta.From.In(meta.location()).Format("20060102"),
+		meta.To.In(meta.location()).Format("20060102"),
+		format,
+	)
+}
+
+// Write exports the entries, zero values (failed fetches) are left empty.
+func Write(w io.Writer, format Format, meta Meta, entries []models.History) error {
+	switch format {
+	case FormatCSV:
+		return writeCSV(w, meta, entries)
+	case FormatJSON:
+		return writeJSON(w, meta, entries)
+	default:
+		return fmt.Errorf("unsupported format %q", format)
+	}
+}
+
+// writeCSV puts the metadata into leading "#" comments, the header row has exchange names.
+func writeCSV(w io.Writer, meta Meta, entries []models.History) error {
+	tz := meta.location()
+	for _, kv := range [][2]string{
+		{"generated", meta.Generated.In(tz).Format(time.RFC3339)},
+		{"timezone", tz.String()},
+		{"from", meta.From.In(tz).Format(time.RFC3339)},
+		{"to", meta.To.In(tz).Format(time.RFC3339)},
+	} {
+		if _, err := fmt.Fprintf(w, "# %s: %s\n", kv[0], kv[1]); err != nil {
+			return fmt.Errorf("write metadata: %w", err)
+		}
+	}
+
+	slugs := seriesOf(entries)
+	cw := csv.NewWriter(w)
+	header := []string{"time"}
+	for _, slug := range slugs {
+		header = append(header, meta.exchange(slug).Name)
+	}
+
+	if err := cw.Write(header); err != nil {
+		return fmt.Errorf("write header: %w", err)
+	}
+
+	for _, entry := range entries {
+		row := []string{entry.When.In(tz).Format(time.RFC3339)}
+		for _, slug := range slugs {
+			cell := ""
+			if v, ok := valueOf(entry, slug); ok {
+				cell = strconv.FormatFloat(v, 'f', -1, 64)
+			}
+			row = append(row, cell)
+		}
+
+		if err := cw.Write(row); err != nil {
+			return fmt.Errorf("write entry: %w", err)
+		}
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+type jsonExport struct {
+	Generated time.Time      `json:"generated"`
+	Timezone  string         `json:"timezone"`
+	From      time.Time      `json:"from"`
+	To        time.Time      `json:"to"`
+	Exchanges []jsonExchange `json:"exchanges"`
+	Entries   []jsonEntry    `json:"entries"`
+}
+
+type jsonExchange struct {
+	Slug  string `json:"slug"`
+	Name  string `json:"name"`
+	Route string `json:"route,omitempty"`
+}
+
+type jsonEntry struct {
+	Time  time.Time           `json:"time"`
+	Rates map[string]*float64 `json:"rates"`
+}
+
+func writeJSON(w io.Writer, meta Meta, entries []models.History) error {
+	tz := meta.location()
+	out := jsonExport{
+		Generated: meta.Generated.In(tz),
+		Timezone:  tz.String(),
+		From:      meta.From.In(tz),
+		To:        meta.To.In(tz),
+		Exchanges: []jsonExchange{},
+		Entries:   make([]jsonEntry, len(entries)),
+	}
+
+	slugs := seriesOf(entries)
+	for _, slug := range slugs {
+		ex := meta.exchange(slug)
+		out.Exchanges = append(out.Exchanges, jsonExchange{
+			Slug:  slug,
+			Name:  ex.Name,
+			Route: ex.Route,
+		})
+	}
+
+	for i, entry := range entries {
+		out.Entries[i] = jsonEntry{
+			Time:  entry.When.In(tz),
+			Rates: make(map[string]*float64, len(slugs)),
+		}
+
+		for _, slug := range slugs {
+			var rate *float64
+			if v, ok := valueOf(entry, slug); ok {
+				rate = &v
+			}
+			out.Entries[i].Rates[slug] = rate
+		}
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(out); err != nil {
+		return fmt.Errorf("encode: %w", err)
+	}
+
+	return nil
+}
+
+func (m Meta) location() *time.Location {
+	if m.Location == nil {
+		return time.Local
+	}
+
+	return m.Location
+}
+
+// exchange finds the configured exchange, unknown ones are named after the slug.
+func (m Meta) exchange(slug string) config.Exchange {
+	for _, ex := range m.Exchanges {
+		if ex.Slug == slug {
+			return ex
+		}
+	}
+
+	return config.Exchange{Name: slug, Slug: slug}
+}
+
+// seriesOf lists the exchanges in order of appearance, the set may change over the history.
+func seriesOf(entries []models.History) []string {
+	var out []string
+	seen := make(map[string]bool)
+	for _, entry := range entries {
+		for _, name := range entry.Names {
+			if !seen[name] {
+				seen[name] = true
+				out = append(out, name)
+			}
+		}
+	}
+
+	return out
+}
+
+func valueOf(entry models.History, slug string) (float64, bool) {
+	for i, name := range entry.Names {
+		if name == slug && i < len(entry.Values) && entry.Values[i] != 0 {
+			return entry.Values[i], true
+		}
+	}
+
+	return 0, false
+}
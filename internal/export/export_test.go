@@ -0,0 +1,75 @@
+package export
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/buglloc/sowettybot/internal/config"
+	"github.com/buglloc/sowettybot/internal/models"
+)
+
+func testExport(t *testing.T) (Meta, []models.History) {
+	tz, err := time.LoadLocation("Asia/Bangkok")
+	require.NoError(t, err)
+
+	start := time.Date(2023, 6, 1, 3, 0, 0, 0, time.UTC)
+	meta := Meta{
+		Exchanges: []config.Exchange{
+			{Name: "Contact (RU -> THB)", Slug: "contact", Route: "contact/ru-th"},
+		},
+		Location:  tz,
+		From:      start,
+		To:        start.Add(2 * time.Hour),
+		Generated: start.Add(3 * time.Hour),
+	}
+
+	entries := []models.History{
+		{When: start, Names: []string{"contact", "korona"}, Values: []float64{0.41, 0.4}},
+		{When: start.Add(time.Hour), Names: []string{"contact", "korona"}, Values: []float64{0.42, 0}},
+	}
+
+	return meta, entries
+}
+
+func TestWriteCSV(t *testing.T) {
+	meta, entries := testExport(t)
+
+	var out bytes.Buffer
+	require.NoError(t, Write(&out, FormatCSV, meta, entries))
+	require.Equal(t, `# generated: 2023-06-01T13:00:00+07:00
+# timezone: Asia/Bangkok
+# from: 2023-06-01T10:00:00+07:00
+# to: 2023-06-01T12:00:00+07:00
+time,Contact (RU -> THB),korona
+2023-06-01T10:00:00+07:00,0.41,0.4
+2023-06-01T11:00:00+07:00,0.42,
+`, out.String())
+	require.Equal(t, "rates_20230601-20230601.csv", FileName(meta, FormatCSV))
+}
+
+func TestWriteJSON(t *testing.T) {
+	meta, entries := testExport(t)
+
+	var out bytes.Buffer
+	require.NoError(t, Write(&out, FormatJSON, meta, entries))
+
+	var got jsonExport
+	require.NoError(t, json.Unmarshal(out.Bytes(), &got))
+	require.Equal(t, "Asia/Bangkok", got.Timezone)
+	require.Equal(t, []jsonExchange{
+		{Slug: "contact", Name: "Contact (RU -> THB)", Route: "contact/ru-th"},
+		{Slug: "korona", Name: "korona"},
+	}, got.Exchanges)
+	require.Len(t, got.Entries, 2)
+	require.Equal(t, 0.42, *got.Entries[1].Rates["contact"])
+	require.Nil(t, got.Entries[1].Rates["korona"])
+}
+
+func TestWriteUnsupported(t *testing.T) {
+	meta, entries := testExport(t)
+	require.Error(t, Write(&bytes.Buffer{}, Format("xml"), meta, entries))
+}
@@ -3,6 +3,7 @@ package history
 import (
 	"fmt"
 	"os"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
@@ -36,6 +37,24 @@ func (h *History) Entries(limit int) ([]models.History, error) {
 	return h.lockedEntries(limit)
 }
 
+func (h *History) Between(from, to time.Time) ([]models.History, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	entries, err := h.lockedEntries(0)
+	if err != nil {
+		return nil, err
+	}
+
+	start := sort.Search(len(entries), func(i int) bool {
+		return !entries[i].When.Before(from)
+	})
+	end := sort.Search(len(entries), func(i int) bool {
+		return !entries[i].When.Before(to)
+	})
+	return entries[start:end], nil
+}
+
 func (h *History) lockedEntries(limit int) ([]models.History, error) {
 	if h.storeFile == "" {
 		return nil, nil
@@ -0,0 +1,74 @@
+package history
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// writeHistory writes n hourly entries starting at start.
+func writeHistory(t *testing.T, start time.Time, n int) string {
+	path := filepath.Join(t.TempDir(), "rates.txt")
+	f, err := os.Create(path)
+	require.NoError(t, err)
+	defer func() { _ = f.Close() }()
+
+	for i := 0; i < n; i++ {
+		when := start.Add(time.Duration(i) * time.Hour)
+		_, err := fmt.Fprintf(f, "%s\tcontact=%.2f\tkorona=%.2f\n", when.Format(time.RFC822), 2.5+float64(i)/100, 2.6)
+		require.NoError(t, err)
+	}
+
+	return path
+}
+
+func TestBetween(t *testing.T) {
+	start := time.Date(2023, 6, 30, 0, 0, 0, 0, time.UTC)
+	hour := func(n int) time.Time {
+		return start.Add(time.Duration(n) * time.Hour)
+	}
+
+	cases := []struct {
+		name  string
+		limit int
+		from  time.Time
+		to    time.Time
+		first time.Time
+		count int
+	}{
+		{name: "all", limit: 100, from: hour(-1), to: hour(48), first: hour(0), count: 48},
+		{name: "inner", limit: 100, from: hour(10), to: hour(20), first: hour(10), count: 10},
+		{name: "between_entries", limit: 100, from: start.Add(90 * time.Minute), to: start.Add(210 * time.Minute), first: hour(2), count: 2},
+		{name: "before", limit: 100, from: hour(-10), to: hour(0)},
+		{name: "after", limit: 100, from: hour(48), to: hour(60)},
+		// the reader stops one line past the limit
+		{name: "limited", limit: 10, from: hour(0), to: hour(48), first: hour(37), count: 11},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			h := NewHistory(writeHistory(t, start, 48), tc.limit)
+			entries, err := h.Between(tc.from, tc.to)
+			require.NoError(t, err)
+			require.Len(t, entries, tc.count)
+			if tc.count == 0 {
+				return
+			}
+
+			require.True(t, tc.first.Equal(entries[0].When), "first entry at %s", entries[0].When)
+			for _, entry := range entries {
+				require.False(t, entry.When.Before(tc.from))
+				require.True(t, entry.When.Before(tc.to))
+			}
+		})
+	}
+}
+
+func TestBetweenNoFile(t *testing.T) {
+	entries, err := NewHistory("", 100).Between(time.Time{}, time.Now())
+	require.NoError(t, err)
+	require.Empty(t, entries)
+}
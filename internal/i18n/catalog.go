@@ -0,0 +1,516 @@
+package i18n
+
+// catalog holds messages by language, plural forms use "#one", "#few", "#many" and "#other" key suffixes.
+var catalog = map[Lang]map[string]string{
+	LangEN: {
+		"lang.name": "English",
+
+		"layout.datetime": "02 Jan 15:04 MST",
+		"layout.time":     "15:04 MST",
+
+		"duration.minutes#one":   "%d minute",
+		"duration.minutes#other": "%d minutes",
+		"duration.hours#one":     "%d hour",
+		"duration.hours#other":   "%d hours",
+		"duration.days#one":      "%d day",
+		"duration.days#other":    "%d days",
+
+		"weekday.0": "Sunday",
+		"weekday.1": "Monday",
+		"weekday.2": "Tuesday",
+		"weekday.3": "Wednesday",
+		"weekday.4": "Thursday",
+		"weekday.5": "Friday",
+		"weekday.6": "Saturday",
+
+		"error.generic":     "ooops, shit happens: %v",
+		"error.panic":       "ooops:\n```\n%+v\n```",
+		"error.unsupported": "Sorry, unsupported command",
+		"error.admin_only":  "Sorry, only group admins can do that",
+
+		"history.unavailable": "Sorry, history is unavailable so far",
+		"history.usage":       "/history [all|period|dates] [exchange]",
+		"longhistory.usage":   "/longhistory [all|period|dates] [exchange]",
+		"rawhistory.usage":    "/rawhistory [count] [period|dates] [exchange]",
+
+		"args.invalid":        "%s, usage:\n%s",
+		"args.usage":          "Usage:\n%s",
+		"args.err.count":      "invalid number %q (from 1 to %d expected)",
+		"args.err.amount":     "invalid amount %q",
+		"args.err.period":     "invalid period %q (e.g. 12h, 3d or 2w expected)",
+		"args.err.date":       "invalid date %q (YYYY-MM-DD expected)",
+		"args.err.date_order": "start date %s is after end date %s",
+		"args.err.exchange":   "unknown exchange %q, available: %s",
+		"args.err.unexpected": "unexpected arguments: %s",
+		"args.err.id":         "invalid id %q",
+
+		"start.greeting": "Nice to see you ;)",
+
+		"help.title":     "Commands:",
+		"help.admin":     "Group admins only:",
+		"help.details":   "Type /help <command> for details",
+		"help.examples":  "Examples:",
+		"help.unknown":   "Unknown command %q, type /help to list them",
+		"help.usage":     "/help [command]",
+		"help.bot_admin": "Bot admins only:",
+
+		"cmd.start":       "start the bot",
+		"cmd.help":        "list commands",
+		"cmd.rates":       "current exchange rates",
+		"cmd.best":        "exchanges ranked by rate",
+		"cmd.history":     "rates chart",
+		"cmd.longhistory": "long rates chart",
+		"cmd.rawhistory":  "rates history as text",
+		"cmd.stats":       "rates statistics for a period",
+		"cmd.export":      "rates history as a CSV or JSON file",
+		"cmd.digest":      "scheduled rates digest",
+		"cmd.rule":        "rate alert rules",
+		"cmd.live":        "pinned live-updating rates",
+		"cmd.lang":        "bot language",
+		"cmd.settings":    "your defaults: exchanges, charts, time zone",
+		"cmd.chatid":      "show the chat id",
+		"cmd.access":      "manage who can use the bot",
+		"cmd.status":      "bot health",
+		"chatid":          "chat id: `%d`",
+		"rates.patient":   "I'll check exchange rates...please be patient...",
+
+		"chart.outdated": "Sorry, this button is outdated",
+		"chart.empty":    "Nothing to show, enable at least one exchange",
+
+		"digest.usage":            "/digest daily HH:MM [time zone]\n/digest weekly <weekday> HH:MM [time zone]\n/digest now\n/digest off",
+		"digest.disabled":         "Digest is disabled. Usage:\n%s",
+		"digest.scheduled":        "Digest is scheduled %s",
+		"digest.already_disabled": "Digest is already disabled",
+		"digest.off":              "Digest disabled",
+		"digest.schedule.daily":   "daily at %02d:%02d (%s)",
+		"digest.schedule.weekly":  "weekly on %s at %02d:%02d (%s)",
+
+		"digest.err.period_required":    "period is required",
+		"digest.err.unsupported_period": "unsupported period %q (daily or weekly expected)",
+		"digest.err.weekday_required":   "weekday is required",
+		"digest.err.invalid_weekday":    "invalid weekday %q",
+		"digest.err.time_required":      "time is required",
+		"digest.err.invalid_time":       "invalid time %q (HH:MM expected)",
+		"digest.err.invalid_tz":         "invalid time zone %q",
+
+		"rule.usage":           "/rule list\n/rule add <expression>\n/rule del <number>",
+		"rule.empty":           "No rules yet. Usage:\n%s",
+		"rule.count#one":       "You have %d rule:",
+		"rule.count#other":     "You have %d rules:",
+		"rule.invalid":         "Invalid rule:\n```\n%s\n%s\n```",
+		"rule.added":           "Rule added: `%s`",
+		"rule.number_required": "Rule number is required. Usage:\n%s",
+		"rule.invalid_number":  "invalid rule number %q",
+		"rule.not_found":       "Rule #%d not found",
+		"rule.too_many":        "You have too many rules, %d at most. Remove some with /rule del",
+		"rule.removed":         "Rule #%d removed",
+		"rule.unknown":         "Unknown subcommand. Usage:\n%s",
+
+		"live.usage":            "/live\n/live off",
+		"live.already_disabled": "Live board is already disabled",
+		"live.off":              "Live board disabled",
+
+		"access.requested":        "You don't have access yet, the request has been sent to the bot admins",
+		"access.pending":          "Your access request is still waiting for approval",
+		"access.denied":           "Sorry, you don't have access to this bot",
+		"access.admin_only":       "Only bot admins can do that",
+		"access.granted":          "Access granted, type /help to start",
+		"access.rejected":         "Sorry, your access request has been denied",
+		"access.request":          "Access request from %s",
+		"access.request.approved": "%s\n✓ approved by %s",
+		"access.request.denied":   "%s\n✗ denied by %s",
+		"access.request.outdated": "The request has already been resolved",
+		"access.resolved":         "Done",
+		"access.button.approve":   "✓ Approve",
+		"access.button.deny":      "✗ Deny",
+		"access.inline.title":     "No access",
+		"access.usage":            "/access [list]\n/access allow|admin|block <id>\n/access remove <id>",
+		"access.status.enabled":   "Access control is enabled",
+		"access.status.disabled":  "Access control is disabled, anyone can use the bot",
+		"access.entries":          "Allowed users and chats:",
+		"access.pending_list":     "Pending requests:",
+		"access.config":           "config",
+		"access.role.user":        "user",
+		"access.role.admin":       "admin",
+		"access.role.blocked":     "blocked",
+		"access.set":              "`%d` is now %s",
+		"access.removed":          "`%d` removed",
+		"access.not_found":        "`%d` not found",
+		"access.err.config":       "`%d` is set in the config and can't be changed",
+		"access.err.action":       "unknown action %q",
+		"access.err.id_required":  "user or chat id required",
+
+		"ratelimit.slow_down#one":   "Slow down please, try again in %d second",
+		"ratelimit.slow_down#other": "Slow down please, try again in %d seconds",
+		"chart.err.busy":            "too many charts are being drawn right now, try again later",
+
+		"lang.usage":   "/lang en|ru|auto",
+		"lang.current": "Current language: %s",
+		"lang.set":     "Language set to %s",
+		"lang.auto":    "Language will follow your Telegram settings",
+		"lang.unknown": "Unknown language %q, available: %s",
+
+		"settings.usage":                 "/settings\n/settings tz <zone>|auto\n/settings reset",
+		"settings.summary":               "Settings\nlanguage: %s\ntime zone: %s\nexchanges: %s\nchart range: %s\nchart size: %s\nSMA: %s\ndark mode: %s\n\nTime zone is set with `/settings tz Europe/Moscow`",
+		"settings.on":                    "on",
+		"settings.off":                   "off",
+		"settings.auto":                  "auto",
+		"settings.default":               "default",
+		"settings.size.small":            "small",
+		"settings.size.medium":           "medium",
+		"settings.size.large":            "large",
+		"settings.button.lang":           "Language",
+		"settings.button.range":          "Chart range",
+		"settings.button.size":           "Size",
+		"settings.button.sma":            "SMA",
+		"settings.button.dark":           "Dark mode",
+		"settings.button.reset":          "Reset",
+		"settings.reset":                 "Settings reset to defaults",
+		"settings.tz.set":                "Time zone set to %s",
+		"settings.tz.auto":               "Time zone reset to the default one",
+		"settings.outdated":              "This button is outdated, send /settings again",
+		"settings.not_yours":             "These are someone else's settings, send /settings to change yours",
+		"settings.err.action":            "unknown setting %q",
+		"settings.err.timezone_required": "time zone required",
+		"settings.err.timezone":          "unknown time zone %q",
+
+		"groups.greeting": "Hi there! Anyone can ask for /rates or /history here, group admins can configure alerts with /rule, /digest and /live",
+
+		"inline.error.title":              "Ooops, shit happens",
+		"inline.rates.title":              "Exchange rates",
+		"inline.rates.description":        "Current rates of all exchanges",
+		"inline.convert.title":            "Convert %s",
+		"inline.convert.description":      "Amount at current rates of all exchanges",
+		"inline.chart.title":              "Rates chart for %s",
+		"inline.chart.description":        "updated %s",
+		"inline.chart.disabled.title":     "Charts are unavailable",
+		"inline.chart.disabled.text":      "Sorry, inline charts are not configured",
+		"inline.chart.disabled.describe":  "telegram.media_chat_id is not configured",
+		"inline.convert.err.no_rates":     "no rates available",
+		"inline.chart.err.no_history":     "history is unavailable so far",
+		"inline.convert.err.non_positive": "amount must be positive",
+
+		"alert.rule":      "YAY! Rule matched: %s",
+		"alert.escalate":  "WOW! Exchange rate keeps falling (threshold is %s)!",
+		"alert.threshold": "YAY! Nice exchange rate (threshold is %s)!",
+
+		"tmpl.rates.header":        "----- %s on %s -----",
+		"tmpl.digest.title.daily":  "daily digest: %s -> %s",
+		"tmpl.digest.title.weekly": "weekly digest: %s -> %s",
+		"tmpl.digest.latest":       "latest: ",
+		"tmpl.digest.min":          "min:    ",
+		"tmpl.digest.max":          "max:    ",
+		"tmpl.digest.avg":          "avg:    ",
+		"tmpl.digest.change":       "change: ",
+		"tmpl.live.title":          "Live rates on %s",
+		"tmpl.live.updated":        "updated %s ago",
+		"tmpl.conversion.title":    "%s at current rates",
+		"tmpl.best.title":          "Best rates for %s",
+		"tmpl.best.stale":          "stale",
+		"tmpl.best.cost":           "costs %s",
+		"tmpl.best.spread":         "spread: %s (%s)",
+		"tmpl.best.savings":        "the best one saves %s",
+
+		"best.usage":       "/best [amount]",
+		"best.unavailable": "Sorry, rates are unavailable so far",
+
+		"tmpl.stats.title":       "statistics: %s -> %s",
+		"tmpl.stats.count#one":   "%d value",
+		"tmpl.stats.count#other": "%d values",
+		"tmpl.stats.latest":      "latest:     ",
+		"tmpl.stats.min":         "min:        ",
+		"tmpl.stats.max":         "max:        ",
+		"tmpl.stats.mean":        "mean:       ",
+		"tmpl.stats.median":      "median:     ",
+		"tmpl.stats.stddev":      "stddev:     ",
+		"tmpl.stats.volatility":  "volatility: ",
+		"tmpl.stats.day_change":  "24h change: ",
+
+		"tmpl.status.version":       "version: %s",
+		"tmpl.status.uptime":        "uptime:  %s (since %s)",
+		"tmpl.status.history":       "history: last entry %s ago (%s)",
+		"tmpl.status.history.empty": "history: empty",
+		"tmpl.status.routes":        "routes",
+		"tmpl.status.routes.none":   "nothing fetched yet",
+		"tmpl.status.route.success": "ok:     %s",
+		"tmpl.status.route.failure": "failed: %s, %s",
+		"tmpl.status.cache":         "rates cache",
+		"tmpl.status.cache.stats":   "items: %d, hits: %d, misses: %d, insertions: %d, evictions: %d",
+		"tmpl.status.rules":         "notifications",
+		"tmpl.status.rules.none":    "no rules",
+		"tmpl.status.rule.never":    "never fired",
+		"tmpl.status.rule.fired":    "fired %s, %d today",
+		"tmpl.status.queues":        "queues",
+		"status.rule.threshold":     "threshold %s",
+		"status.queue.renders":      "chart renders",
+		"status.queue.access":       "access requests",
+		"status.queue.jobs":         "scheduled jobs",
+		"status.queue.outbox":       "queued messages",
+
+		"stats.usage": "/stats [period|dates] [exchange]",
+
+		"export.usage":         "/export [period|dates] [csv|json]",
+		"export.caption":       "%s from %s to %s",
+		"export.entries#one":   "%d entry",
+		"export.entries#other": "%d entries",
+	},
+	LangRU: {
+		"lang.name": "Русский",
+
+		"layout.datetime": "02.01 15:04 MST",
+		"layout.time":     "15:04 MST",
+
+		"duration.minutes#one":  "%d минута",
+		"duration.minutes#few":  "%d минуты",
+		"duration.minutes#many": "%d минут",
+		"duration.hours#one":    "%d час",
+		"duration.hours#few":    "%d часа",
+		"duration.hours#many":   "%d часов",
+		"duration.days#one":     "%d день",
+		"duration.days#few":     "%d дня",
+		"duration.days#many":    "%d дней",
+
+		"weekday.0": "воскресенье",
+		"weekday.1": "понедельник",
+		"weekday.2": "вторник",
+		"weekday.3": "среда",
+		"weekday.4": "четверг",
+		"weekday.5": "пятница",
+		"weekday.6": "суббота",
+
+		"error.generic":     "упс, что-то пошло не так: %v",
+		"error.panic":       "упс:\n```\n%+v\n```",
+		"error.unsupported": "Извините, такая команда не поддерживается",
+		"error.admin_only":  "Извините, это могут делать только администраторы группы",
+
+		"history.unavailable": "Извините, история пока недоступна",
+		"history.usage":       "/history [all|период|даты] [обменник]",
+		"longhistory.usage":   "/longhistory [all|период|даты] [обменник]",
+		"rawhistory.usage":    "/rawhistory [количество] [период|даты] [обменник]",
+
+		"args.invalid":        "%s, использование:\n%s",
+		"args.usage":          "Использование:\n%s",
+		"args.err.count":      "неверное число %q (ожидается от 1 до %d)",
+		"args.err.amount":     "неверная сумма %q",
+		"args.err.period":     "неверный период %q (например, 12h, 3d или 2w)",
+		"args.err.date":       "неверная дата %q (ожидается ГГГГ-ММ-ДД)",
+		"args.err.date_order": "начальная дата %s позже конечной %s",
+		"args.err.exchange":   "неизвестный обменник %q, доступны: %s",
+		"args.err.unexpected": "лишние аргументы: %s",
+		"args.err.id":         "неверный id %q",
+
+		"start.greeting": "Рад видеть ;)",
+
+		"help.title":     "Команды:",
+		"help.admin":     "Только для администраторов группы:",
+		"help.details":   "Наберите /help <команда>, чтобы узнать подробности",
+		"help.examples":  "Примеры:",
+		"help.unknown":   "Неизвестная команда %q, наберите /help для списка команд",
+		"help.usage":     "/help [команда]",
+		"help.bot_admin": "Только для администраторов бота:",
+
+		"cmd.start":       "начать работу с ботом",
+		"cmd.help":        "список команд",
+		"cmd.rates":       "текущие курсы",
+		"cmd.best":        "обменники по выгодности курса",
+		"cmd.history":     "график курсов",
+		"cmd.longhistory": "длинный график курсов",
+		"cmd.rawhistory":  "история курсов текстом",
+		"cmd.stats":       "статистика курсов за период",
+		"cmd.export":      "история курсов файлом CSV или JSON",
+		"cmd.digest":      "дайджест курсов по расписанию",
+		"cmd.rule":        "правила оповещений о курсах",
+		"cmd.live":        "закреплённое обновляемое табло курсов",
+		"cmd.lang":        "язык бота",
+		"cmd.settings":    "ваши настройки: обменники, графики, часовой пояс",
+		"cmd.chatid":      "показать id чата",
+		"cmd.access":      "управлять доступом к боту",
+		"cmd.status":      "состояние бота",
+		"chatid":          "id чата: `%d`",
+		"rates.patient":   "Проверяю курсы...немного терпения...",
+
+		"chart.outdated": "Извините, эта кнопка устарела",
+		"chart.empty":    "Нечего показать, включите хотя бы один обменник",
+
+		"digest.usage":            "/digest daily ЧЧ:ММ [часовой пояс]\n/digest weekly <день недели> ЧЧ:ММ [часовой пояс]\n/digest now\n/digest off",
+		"digest.disabled":         "Дайджест выключен. Использование:\n%s",
+		"digest.scheduled":        "Дайджест запланирован %s",
+		"digest.already_disabled": "Дайджест уже выключен",
+		"digest.off":              "Дайджест выключен",
+		"digest.schedule.daily":   "ежедневно в %02d:%02d (%s)",
+		"digest.schedule.weekly":  "еженедельно, %s в %02d:%02d (%s)",
+
+		"digest.err.period_required":    "не указан период",
+		"digest.err.unsupported_period": "неизвестный период %q (ожидается daily или weekly)",
+		"digest.err.weekday_required":   "не указан день недели",
+		"digest.err.invalid_weekday":    "неизвестный день недели %q",
+		"digest.err.time_required":      "не указано время",
+		"digest.err.invalid_time":       "неверное время %q (ожидается ЧЧ:ММ)",
+		"digest.err.invalid_tz":         "неизвестный часовой пояс %q",
+
+		"rule.usage":           "/rule list\n/rule add <выражение>\n/rule del <номер>",
+		"rule.empty":           "Правил пока нет. Использование:\n%s",
+		"rule.count#one":       "У вас %d правило:",
+		"rule.count#few":       "У вас %d правила:",
+		"rule.count#many":      "У вас %d правил:",
+		"rule.invalid":         "Неверное правило:\n```\n%s\n%s\n```",
+		"rule.added":           "Правило добавлено: `%s`",
+		"rule.number_required": "Не указан номер правила. Использование:\n%s",
+		"rule.invalid_number":  "неверный номер правила %q",
+		"rule.not_found":       "Правило №%d не найдено",
+		"rule.too_many":        "Слишком много правил, можно не больше %d. Удалите лишние через /rule del",
+		"rule.removed":         "Правило №%d удалено",
+		"rule.unknown":         "Неизвестная подкоманда. Использование:\n%s",
+
+		"live.usage":            "/live\n/live off",
+		"live.already_disabled": "Живое табло уже выключено",
+		"live.off":              "Живое табло выключено",
+
+		"access.requested":        "У вас пока нет доступа, запрос отправлен администраторам бота",
+		"access.pending":          "Ваш запрос на доступ ещё ждёт одобрения",
+		"access.denied":           "Извините, у вас нет доступа к этому боту",
+		"access.admin_only":       "Это доступно только администраторам бота",
+		"access.granted":          "Доступ открыт, наберите /help, чтобы начать",
+		"access.rejected":         "Извините, ваш запрос на доступ отклонён",
+		"access.request":          "Запрос доступа от %s",
+		"access.request.approved": "%s\n✓ одобрил %s",
+		"access.request.denied":   "%s\n✗ отклонил %s",
+		"access.request.outdated": "Запрос уже обработан",
+		"access.resolved":         "Готово",
+		"access.button.approve":   "✓ Одобрить",
+		"access.button.deny":      "✗ Отклонить",
+		"access.inline.title":     "Нет доступа",
+		"access.usage":            "/access [list]\n/access allow|admin|block <id>\n/access remove <id>",
+		"access.status.enabled":   "Контроль доступа включён",
+		"access.status.disabled":  "Контроль доступа выключен, ботом может пользоваться кто угодно",
+		"access.entries":          "Разрешённые пользователи и чаты:",
+		"access.pending_list":     "Ожидающие запросы:",
+		"access.config":           "конфиг",
+		"access.role.user":        "пользователь",
+		"access.role.admin":       "администратор",
+		"access.role.blocked":     "заблокирован",
+		"access.set":              "`%d` теперь %s",
+		"access.removed":          "`%d` удалён",
+		"access.not_found":        "`%d` не найден",
+		"access.err.config":       "`%d` задан в конфиге и не может быть изменён",
+		"access.err.action":       "неизвестное действие %q",
+		"access.err.id_required":  "нужен id пользователя или чата",
+
+		"ratelimit.slow_down#one":  "Помедленнее, пожалуйста, попробуйте снова через %d секунду",
+		"ratelimit.slow_down#few":  "Помедленнее, пожалуйста, попробуйте снова через %d секунды",
+		"ratelimit.slow_down#many": "Помедленнее, пожалуйста, попробуйте снова через %d секунд",
+		"chart.err.busy":           "сейчас рисуется слишком много графиков, попробуйте позже",
+
+		"lang.usage":   "/lang en|ru|auto",
+		"lang.current": "Текущий язык: %s",
+		"lang.set":     "Язык переключён: %s",
+		"lang.auto":    "Язык будет выбираться по настройкам Telegram",
+		"lang.unknown": "Неизвестный язык %q, доступны: %s",
+
+		"settings.usage":                 "/settings\n/settings tz <зона>|auto\n/settings reset",
+		"settings.summary":               "Настройки\nязык: %s\nчасовой пояс: %s\nобменники: %s\nпериод графика: %s\nразмер графика: %s\nSMA: %s\nтёмная тема: %s\n\nЧасовой пояс задаётся командой `/settings tz Europe/Moscow`",
+		"settings.on":                    "вкл",
+		"settings.off":                   "выкл",
+		"settings.auto":                  "авто",
+		"settings.default":               "по умолчанию",
+		"settings.size.small":            "маленький",
+		"settings.size.medium":           "средний",
+		"settings.size.large":            "большой",
+		"settings.button.lang":           "Язык",
+		"settings.button.range":          "Период графика",
+		"settings.button.size":           "Размер",
+		"settings.button.sma":            "SMA",
+		"settings.button.dark":           "Тёмная тема",
+		"settings.button.reset":          "Сбросить",
+		"settings.reset":                 "Настройки сброшены",
+		"settings.tz.set":                "Часовой пояс: %s",
+		"settings.tz.auto":               "Часовой пояс сброшен на стандартный",
+		"settings.outdated":              "Кнопка устарела, отправьте /settings ещё раз",
+		"settings.not_yours":             "Это чужие настройки, отправьте /settings, чтобы изменить свои",
+		"settings.err.action":            "неизвестная настройка %q",
+		"settings.err.timezone_required": "нужен часовой пояс",
+		"settings.err.timezone":          "неизвестный часовой пояс %q",
+
+		"groups.greeting": "Всем привет! Спрашивайте /rates или /history, а администраторы группы могут настроить оповещения через /rule, /digest и /live",
+
+		"inline.error.title":              "Упс, что-то пошло не так",
+		"inline.rates.title":              "Курсы обмена",
+		"inline.rates.description":        "Текущие курсы всех обменников",
+		"inline.convert.title":            "Пересчитать %s",
+		"inline.convert.description":      "Сумма по текущим курсам всех обменников",
+		"inline.chart.title":              "График курсов за %s",
+		"inline.chart.description":        "обновлено %s",
+		"inline.chart.disabled.title":     "Графики недоступны",
+		"inline.chart.disabled.text":      "Извините, графики в инлайн-режиме не настроены",
+		"inline.chart.disabled.describe":  "не настроен telegram.media_chat_id",
+		"inline.convert.err.no_rates":     "курсы недоступны",
+		"inline.chart.err.no_history":     "история пока недоступна",
+		"inline.convert.err.non_positive": "сумма должна быть положительной",
+
+		"alert.rule":      "УРА! Сработало правило: %s",
+		"alert.escalate":  "ОГО! Курс продолжает падать (порог %s)!",
+		"alert.threshold": "УРА! Хороший курс (порог %s)!",
+
+		"tmpl.rates.header":        "----- %s на %s -----",
+		"tmpl.digest.title.daily":  "дайджест за день: %s -> %s",
+		"tmpl.digest.title.weekly": "дайджест за неделю: %s -> %s",
+		"tmpl.digest.latest":       "текущий: ",
+		"tmpl.digest.min":          "мин:     ",
+		"tmpl.digest.max":          "макс:    ",
+		"tmpl.digest.avg":          "средний: ",
+		"tmpl.digest.change":       "изм.:    ",
+		"tmpl.live.title":          "Курсы на %s",
+		"tmpl.live.updated":        "обновлено %s назад",
+		"tmpl.conversion.title":    "%s по текущим курсам",
+		"tmpl.best.title":          "Лучшие курсы для %s",
+		"tmpl.best.stale":          "устарел",
+		"tmpl.best.cost":           "стоит %s",
+		"tmpl.best.spread":         "разброс: %s (%s)",
+		"tmpl.best.savings":        "лучший экономит %s",
+
+		"best.usage":       "/best [сумма]",
+		"best.unavailable": "Извините, курсы пока недоступны",
+
+		"tmpl.stats.title":      "статистика: %s -> %s",
+		"tmpl.stats.count#one":  "%d значение",
+		"tmpl.stats.count#few":  "%d значения",
+		"tmpl.stats.count#many": "%d значений",
+		"tmpl.stats.latest":     "текущий:     ",
+		"tmpl.stats.min":        "мин:         ",
+		"tmpl.stats.max":        "макс:        ",
+		"tmpl.stats.mean":       "среднее:     ",
+		"tmpl.stats.median":     "медиана:     ",
+		"tmpl.stats.stddev":     "ст. откл.:   ",
+		"tmpl.stats.volatility": "волатильн.:  ",
+		"tmpl.stats.day_change": "за 24 часа:  ",
+
+		"tmpl.status.version":       "версия: %s",
+		"tmpl.status.uptime":        "аптайм: %s (с %s)",
+		"tmpl.status.history":       "история: последняя запись %s назад (%s)",
+		"tmpl.status.history.empty": "история: пусто",
+		"tmpl.status.routes":        "маршруты",
+		"tmpl.status.routes.none":   "ещё ничего не запрашивалось",
+		"tmpl.status.route.success": "успех:  %s",
+		"tmpl.status.route.failure": "ошибка: %s, %s",
+		"tmpl.status.cache":         "кэш курсов",
+		"tmpl.status.cache.stats":   "записей: %d, попаданий: %d, промахов: %d, вставок: %d, вытеснений: %d",
+		"tmpl.status.rules":         "оповещения",
+		"tmpl.status.rules.none":    "правил нет",
+		"tmpl.status.rule.never":    "ещё не срабатывало",
+		"tmpl.status.rule.fired":    "сработало %s, сегодня %d раз",
+		"tmpl.status.queues":        "очереди",
+		"status.rule.threshold":     "порог %s",
+		"status.queue.renders":      "отрисовка графиков",
+		"status.queue.access":       "запросы доступа",
+		"status.queue.jobs":         "задачи по расписанию",
+		"status.queue.outbox":       "сообщения в очереди",
+
+		"stats.usage": "/stats [период|даты] [обменник]",
+
+		"export.usage":        "/export [период|даты] [csv|json]",
+		"export.caption":      "%s с %s по %s",
+		"export.entries#one":  "%d запись",
+		"export.entries#few":  "%d записи",
+		"export.entries#many": "%d записей",
+	},
+}
@@ -0,0 +1,189 @@
+package i18n
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Lang string
+
+const (
+	LangEN Lang = "en"
+	LangRU Lang = "ru"
+)
+
+var Langs = []Lang{LangEN, LangRU}
+
+// ParseLang accepts language codes like "ru", "ru-RU" or "en_US".
+func ParseLang(code string) (Lang, bool) {
+	code = strings.ToLower(code)
+	if idx := strings.IndexAny(code, "-_"); idx >= 0 {
+		code = code[:idx]
+	}
+
+	for _, l := range Langs {
+		if string(l) == code {
+			return l, true
+		}
+	}
+
+	return "", false
+}
+
+type Localizer struct {
+	lang Lang
+}
+
+func NewLocalizer(lang Lang) *Localizer {
+	if _, ok := catalog[lang]; !ok {
+		lang = LangEN
+	}
+
+	return &Localizer{
+		lang: lang,
+	}
+}
+
+func (l *Localizer) Lang() Lang {
+	return l.lang
+}
+
+// T returns the translated message formatted with args, falls back to English and then to the key itself.
+func (l *Localizer) T(key string, args ...interface{}) string {
+	msg, ok := lookup(l.lang, key)
+	if !ok {
+		return key
+	}
+
+	if len(args) == 0 {
+		return msg
+	}
+
+	return fmt.Sprintf(msg, args...)
+}
+
+// N returns the plural form of the message for n, e.g. N("minutes", 5) -> "5 minutes".
+func (l *Localizer) N(key string, n int, args ...interface{}) string {
+	msg, ok := lookup(l.lang, key+"#"+pluralForm(l.lang, n))
+	if !ok {
+		msg, ok = lookup(l.lang, key+"#other")
+	}
+
+	if !ok {
+		return key
+	}
+
+	return fmt.Sprintf(msg, append([]interface{}{n}, args...)...)
+}
+
+// Float formats the number with the language decimal separator.
+func (l *Localizer) Float(v float64, prec int) string {
+	return l.localizeNumber(strconv.FormatFloat(v, 'f', prec, 64))
+}
+
+// Signed is like Float but always prints the sign.
+func (l *Localizer) Signed(v float64, prec int) string {
+	out := l.Float(v, prec)
+	if v >= 0 {
+		out = "+" + out
+	}
+
+	return out
+}
+
+func (l *Localizer) Duration(d time.Duration) string {
+	d = d.Truncate(time.Minute)
+	days := int(d / (24 * time.Hour))
+	hours := int(d % (24 * time.Hour) / time.Hour)
+	minutes := int(d % time.Hour / time.Minute)
+
+	var parts []string
+	if days > 0 {
+		parts = append(parts, l.N("duration.days", days))
+	}
+
+	if hours > 0 {
+		parts = append(parts, l.N("duration.hours", hours))
+	}
+
+	if minutes > 0 || len(parts) == 0 {
+		parts = append(parts, l.N("duration.minutes", minutes))
+	}
+
+	return strings.Join(parts, " ")
+}
+
+// Time formats t with the layout stored in the catalog under the key, e.g. "layout.datetime".
+func (l *Localizer) Time(t time.Time, layoutKey string) string {
+	return t.Format(l.T(layoutKey))
+}
+
+// Error translates errors created by Errorf, other errors are returned as is.
+func (l *Localizer) Error(err error) string {
+	var locErr *Error
+	if errors.As(err, &locErr) {
+		return l.T(locErr.Key, locErr.Args...)
+	}
+
+	return err.Error()
+}
+
+func (l *Localizer) localizeNumber(in string) string {
+	if l.lang == LangRU {
+		return strings.Replace(in, ".", ",", 1)
+	}
+
+	return in
+}
+
+// Error is an error that can be shown to users in their language.
+type Error struct {
+	Key  string
+	Args []interface{}
+}
+
+func Errorf(key string, args ...interface{}) *Error {
+	return &Error{
+		Key:  key,
+		Args: args,
+	}
+}
+
+func (e *Error) Error() string {
+	return NewLocalizer(LangEN).T(e.Key, e.Args...)
+}
+
+func lookup(lang Lang, key string) (string, bool) {
+	if msg, ok := catalog[lang][key]; ok {
+		return msg, true
+	}
+
+	msg, ok := catalog[LangEN][key]
+	return msg, ok
+}
+
+func pluralForm(lang Lang, n int) string {
+	if n < 0 {
+		n = -n
+	}
+
+	switch lang {
+	case LangRU:
+		switch {
+		case n%10 == 1 && n%100 != 11:
+			return "one"
+		case n%10 >= 2 && n%10 <= 4 && (n%100 < 12 || n%100 > 14):
+			return "few"
+		default:
+			return "many"
+		}
+	default:
+		if n == 1 {
+			return "one"
+		}
+		return "other"
+	}
+}
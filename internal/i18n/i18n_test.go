@@ -0,0 +1,78 @@
+package i18n
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPlural(t *testing.T) {
+	cases := []struct {
+		lang Lang
+		n    int
+		out  string
+	}{
+		{lang: LangEN, n: 1, out: "1 hour"},
+		{lang: LangEN, n: 2, out: "2 hours"},
+		{lang: LangEN, n: 11, out: "11 hours"},
+		{lang: LangRU, n: 1, out: "1 час"},
+		{lang: LangRU, n: 3, out: "3 часа"},
+		{lang: LangRU, n: 5, out: "5 часов"},
+		{lang: LangRU, n: 11, out: "11 часов"},
+		{lang: LangRU, n: 12, out: "12 часов"},
+		{lang: LangRU, n: 21, out: "21 час"},
+		{lang: LangRU, n: 22, out: "22 часа"},
+		{lang: LangRU, n: 111, out: "111 часов"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.out, func(t *testing.T) {
+			require.Equal(t, tc.out, NewLocalizer(tc.lang).N("duration.hours", tc.n))
+		})
+	}
+}
+
+func TestNumbers(t *testing.T) {
+	en := NewLocalizer(LangEN)
+	ru := NewLocalizer(LangRU)
+
+	require.Equal(t, "2.931", en.Float(2.9314, 3))
+	require.Equal(t, "2,931", ru.Float(2.9314, 3))
+	require.Equal(t, "+0,020", ru.Signed(0.02, 3))
+	require.Equal(t, "-0.020", en.Signed(-0.02, 3))
+	require.Equal(t, "1 день 2 часа 5 минут", ru.Duration(26*time.Hour+5*time.Minute+10*time.Second))
+	require.Equal(t, "0 minutes", en.Duration(30*time.Second))
+}
+
+func TestParseLang(t *testing.T) {
+	for in, expected := range map[string]Lang{"ru": LangRU, "ru-RU": LangRU, "EN_us": LangEN} {
+		lang, ok := ParseLang(in)
+		require.True(t, ok, in)
+		require.Equal(t, expected, lang, in)
+	}
+
+	_, ok := ParseLang("de")
+	require.False(t, ok)
+}
+
+func TestCatalogComplete(t *testing.T) {
+	for key := range catalog[LangEN] {
+		for _, lang := range Langs {
+			base, plural := strings.CutSuffix(key, "#other")
+			if !plural {
+				if strings.Contains(key, "#") {
+					continue
+				}
+
+				_, ok := catalog[lang][key]
+				require.True(t, ok, "missing %q for %s", key, lang)
+				continue
+			}
+
+			_, ok := catalog[lang][base+"#"+pluralForm(lang, 5)]
+			require.True(t, ok, "missing plural %q for %s", base, lang)
+		}
+	}
+}
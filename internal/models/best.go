@@ -0,0 +1,24 @@
+package models
+
+import "time"
+
+type BestExchange struct {
+	Position int
+	Name     string
+	Rate     float64
+	When     time.Time
+	// Source is either "cache" or "history"
+	Source string
+	Stale  bool
+	// Cost is the price of the reference amount, Overpay is how much more it costs than at the best exchange
+	Cost    float64
+	Overpay float64
+}
+
+type Best struct {
+	Amount    float64
+	Exchanges []BestExchange
+	Spread    float64
+	SpreadPct float64
+	Savings   float64
+}
@@ -0,0 +1,14 @@
+package models
+
+type ConversionExchange struct {
+	Name string
+	Rate float64
+	// Pay is the amount multiplied by the rate, Get is the amount divided by it
+	Pay float64
+	Get float64
+}
+
+type Conversion struct {
+	Amount    float64
+	Exchanges []ConversionExchange
+}
@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+type DigestExchange struct {
+	Name      string
+	Latest    float64
+	Min       float64
+	Max       float64
+	Avg       float64
+	Change    float64
+	ChangePct float64
+}
+
+type Digest struct {
+	Period    string
+	From      time.Time
+	To        time.Time
+	Exchanges []DigestExchange
+}
@@ -0,0 +1,15 @@
+package models
+
+import "time"
+
+type LiveExchange struct {
+	Name   string
+	Rate   float64
+	Change float64
+}
+
+type LiveBoard struct {
+	When      time.Time
+	Age       time.Duration
+	Exchanges []LiveExchange
+}
@@ -0,0 +1,28 @@
+package models
+
+import "time"
+
+type StatsExchange struct {
+	Name       string
+	Count      int
+	Latest     float64
+	LatestAt   time.Time
+	Min        float64
+	MinAt      time.Time
+	Max        float64
+	MaxAt      time.Time
+	Mean       float64
+	Median     float64
+	StdDev     float64
+	Volatility float64
+	// DayChange is the change against the value a day before the latest one, if known
+	HasDayChange bool
+	DayChange    float64
+	DayChangePct float64
+}
+
+type Stats struct {
+	From      time.Time
+	To        time.Time
+	Exchanges []StatsExchange
+}
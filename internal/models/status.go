@@ -0,0 +1,51 @@
+package models
+
+import "time"
+
+type StatusRoute struct {
+	Name        string
+	Route       string
+	LastSuccess time.Time
+	LastFailure time.Time
+	LastError   string
+}
+
+// Failing reports whether the last fetch of the route has failed.
+func (r StatusRoute) Failing() bool {
+	return r.LastFailure.After(r.LastSuccess)
+}
+
+type StatusRule struct {
+	ChatID     int
+	Rule       string
+	LastFired  time.Time
+	FiredToday int
+}
+
+type StatusQueue struct {
+	Name  string
+	Depth int
+	// Capacity is zero for unbounded queues
+	Capacity int
+}
+
+type StatusCache struct {
+	Items      int
+	Hits       uint64
+	Misses     uint64
+	Insertions uint64
+	Evictions  uint64
+}
+
+type Status struct {
+	Version   string
+	StartedAt time.Time
+	Uptime    time.Duration
+	// LastEntryAt is zero while the history is empty
+	LastEntryAt  time.Time
+	LastEntryAge time.Duration
+	Routes       []StatusRoute
+	Cache        StatusCache
+	Rules        []StatusRule
+	Queues       []StatusQueue
+}
@@ -19,8 +19,9 @@ const (
 )
 
 type Client struct {
-	httpc *resty.Client
-	log   zerolog.Logger
+	httpc    *resty.Client
+	log      zerolog.Logger
+	statuses routeStatuses
 }
 
 func NewClient(opts ...Option) (*Client, error) {
@@ -47,6 +48,17 @@ func NewClient(opts ...Option) (*Client, error) {
 }
 
 func (c *Client) Rate(ctx context.Context, route string) (models.Rate, error) {
+	rate, err := c.rate(ctx, route)
+	c.statuses.record(route, rate.When, err)
+	return rate, err
+}
+
+// Routes reports the latest fetches of every route requested so far, ordered by route.
+func (c *Client) Routes() []RouteStatus {
+	return c.statuses.list()
+}
+
+func (c *Client) rate(ctx context.Context, route string) (models.Rate, error) {
 	c.log.Info().
 		Any("route", route).
 		Msg("fetch rate")
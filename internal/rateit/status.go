@@ -0,0 +1,54 @@
+package rateit
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// RouteStatus is the outcome of the latest fetches of a route.
+type RouteStatus struct {
+	Route       string
+	LastSuccess time.Time
+	LastFailure time.Time
+	LastError   string
+}
+
+type routeStatuses struct {
+	mu     sync.Mutex
+	routes map[string]RouteStatus
+}
+
+func (s *routeStatuses) record(route string, when time.Time, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.routes == nil {
+		s.routes = make(map[string]RouteStatus)
+	}
+
+	status := s.routes[route]
+	status.Route = route
+	if err != nil {
+		status.LastFailure = when
+		status.LastError = err.Error()
+	} else {
+		status.LastSuccess = when
+	}
+	s.routes[route] = status
+}
+
+func (s *routeStatuses) list() []RouteStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	out := make([]RouteStatus, 0, len(s.routes))
+	for _, status := range s.routes {
+		out = append(out, status)
+	}
+
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].Route < out[j].Route
+	})
+	return out
+}
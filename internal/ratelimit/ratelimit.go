@@ -0,0 +1,100 @@
+package ratelimit
+
+import (
+	"sync"
+	"time"
+)
+
+// pruneSize is the number of buckets to start forgetting the idle ones.
+const pruneSize = 1024
+
+type Verdict struct {
+	Allowed bool
+	// RetryAfter is the time until the next token
+	RetryAfter time.Duration
+	// First is set for the first refusal in a row, to complain once instead of on every call
+	First bool
+}
+
+type bucket struct {
+	tokens  float64
+	updated time.Time
+	refused bool
+}
+
+// Limiter is a token bucket per key: Burst calls at once, then one per Every.
+type Limiter struct {
+	burst   float64
+	every   time.Duration
+	now     func() time.Time
+	mu      sync.Mutex
+	buckets map[int]*bucket
+}
+
+// NewLimiter returns a limiter, it allows everything if burst or every is not positive.
+func NewLimiter(burst int, every time.Duration) *Limiter {
+	return &Limiter{
+		burst:   float64(burst),
+		every:   every,
+		now:     time.Now,
+		buckets: make(map[int]*bucket),
+	}
+}
+
+func (l *Limiter) Enabled() bool {
+	return l.burst > 0 && l.every > 0
+}
+
+// Allow takes a token from the key bucket.
+func (l *Limiter) Allow(key int) Verdict {
+	if !l.Enabled() {
+		return Verdict{Allowed: true}
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	if len(l.buckets) >= pruneSize {
+		l.lockedPrune(now)
+	}
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: l.burst, updated: now}
+		l.buckets[key] = b
+	}
+
+	b.tokens = l.refill(b, now)
+	b.updated = now
+	if b.tokens >= 1 {
+		b.tokens--
+		b.refused = false
+		return Verdict{Allowed: true}
+	}
+
+	first := !b.refused
+	b.refused = true
+	return Verdict{
+		RetryAfter: time.Duration((1 - b.tokens) * float64(l.every)),
+		First:      first,
+	}
+}
+
+func (l *Limiter) refill(b *bucket, now time.Time) float64 {
+	tokens := b.tokens + float64(now.Sub(b.updated))/float64(l.every)
+	if tokens > l.burst {
+		return l.burst
+	}
+
+	return tokens
+}
+
+// lockedPrune drops full buckets, they are no different from new ones.
+func (l *Limiter) lockedPrune(now time.Time) {
+	for key, b := range l.buckets {
+		if l.refill(b, now) >= l.burst {
+			delete(l.buckets, key)
+		}
+	}
+}
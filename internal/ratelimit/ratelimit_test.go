@@ -0,0 +1,47 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLimiter(t *testing.T) {
+	now := time.Date(2023, 6, 1, 0, 0, 0, 0, time.UTC)
+	l := NewLimiter(2, 10*time.Second)
+	l.now = func() time.Time { return now }
+
+	require.True(t, l.Allow(1).Allowed)
+	require.True(t, l.Allow(1).Allowed)
+
+	v := l.Allow(1)
+	require.False(t, v.Allowed)
+	require.True(t, v.First)
+	require.Equal(t, 10*time.Second, v.RetryAfter)
+
+	now = now.Add(4 * time.Second)
+	v = l.Allow(1)
+	require.False(t, v.Allowed)
+	require.False(t, v.First)
+	require.Equal(t, 6*time.Second, v.RetryAfter)
+
+	// other keys have their own buckets
+	require.True(t, l.Allow(2).Allowed)
+
+	now = now.Add(6 * time.Second)
+	require.True(t, l.Allow(1).Allowed)
+	require.False(t, l.Allow(1).Allowed)
+
+	now = now.Add(time.Hour)
+	require.True(t, l.Allow(1).Allowed)
+	require.True(t, l.Allow(1).Allowed)
+	require.False(t, l.Allow(1).Allowed)
+}
+
+func TestLimiterDisabled(t *testing.T) {
+	l := NewLimiter(0, time.Second)
+	for i := 0; i < 10; i++ {
+		require.True(t, l.Allow(1).Allowed)
+	}
+}
@@ -1,9 +1,17 @@
 package renderer
 
+import (
+	"time"
+
+	"github.com/wcharczuk/go-chart"
+)
+
 type GraphConfig struct {
 	width   int
 	height  int
 	showSMA bool
+	dark    bool
+	tz      *time.Location
 }
 
 func NewGraphConfig() *GraphConfig {
@@ -28,3 +36,36 @@ func (g *GraphConfig) WithSMA(show bool) *GraphConfig {
 	g.showSMA = show
 	return g
 }
+
+func (g *GraphConfig) Dark(dark bool) *GraphConfig {
+	g.dark = dark
+	return g
+}
+
+// Location sets the time zone of the time axis, the server one by default.
+func (g *GraphConfig) Location(tz *time.Location) *GraphConfig {
+	g.tz = tz
+	return g
+}
+
+func (g *GraphConfig) location() *time.Location {
+	if g.tz == nil {
+		return time.Local
+	}
+
+	return g.tz
+}
+
+// timeFormatter formats axis ticks in the configured time zone, chart turns them into server local times.
+func (g *GraphConfig) timeFormatter(layout string) chart.ValueFormatter {
+	return func(v interface{}) string {
+		switch typed := v.(type) {
+		case time.Time:
+			return typed.In(g.location()).Format(layout)
+		case float64:
+			return time.Unix(0, int64(typed)).In(g.location()).Format(layout)
+		default:
+			return ""
+		}
+	}
+}
@@ -9,19 +9,37 @@ import (
 	"github.com/wcharczuk/go-chart"
 	"github.com/wcharczuk/go-chart/drawing"
 
+	"github.com/buglloc/sowettybot/internal/i18n"
 	"github.com/buglloc/sowettybot/internal/models"
 )
 
 type HistoryRenderer struct {
+	loc *i18n.Localizer
+	tz  *time.Location
 }
 
 func NewHistoryRenderer() *HistoryRenderer {
-	return &HistoryRenderer{}
+	return &HistoryRenderer{
+		loc: i18n.NewLocalizer(i18n.LangEN),
+		tz:  time.Local,
+	}
+}
+
+// For returns a renderer that speaks the localizer language and shows times in tz, nil means the server one.
+func (h *HistoryRenderer) For(loc *i18n.Localizer, tz *time.Location) *HistoryRenderer {
+	if tz == nil {
+		tz = time.Local
+	}
+
+	return &HistoryRenderer{
+		loc: loc,
+		tz:  tz,
+	}
 }
 
 func (h *HistoryRenderer) Rates(rates models.Rates) (string, error) {
 	var out strings.Builder
-	if err := renderTemplate(&out, "rates.gotmpl", rates); err != nil {
+	if err := renderTemplate(&out, h.loc, h.tz, "rates.gotmpl", rates); err != nil {
 		return "", fmt.Errorf("render failed: %w", err)
 	}
 
@@ -30,7 +48,61 @@ func (h *HistoryRenderer) Rates(rates models.Rates) (string, error) {
 
 func (h *HistoryRenderer) Log(entries []models.History) (string, error) {
 	var out strings.Builder
-	if err := renderTemplate(&out, "log.gotmpl", entries); err != nil {
+	if err := renderTemplate(&out, h.loc, h.tz, "log.gotmpl", entries); err != nil {
+		return "", fmt.Errorf("render failed: %w", err)
+	}
+
+	return out.String(), nil
+}
+
+func (h *HistoryRenderer) Digest(digest models.Digest) (string, error) {
+	var out strings.Builder
+	if err := renderTemplate(&out, h.loc, h.tz, "digest.gotmpl", digest); err != nil {
+		return "", fmt.Errorf("render failed: %w", err)
+	}
+
+	return out.String(), nil
+}
+
+func (h *HistoryRenderer) Live(board models.LiveBoard) (string, error) {
+	var out strings.Builder
+	if err := renderTemplate(&out, h.loc, h.tz, "live.gotmpl", board); err != nil {
+		return "", fmt.Errorf("render failed: %w", err)
+	}
+
+	return out.String(), nil
+}
+
+func (h *HistoryRenderer) Conversion(conv models.Conversion) (string, error) {
+	var out strings.Builder
+	if err := renderTemplate(&out, h.loc, h.tz, "conversion.gotmpl", conv); err != nil {
+		return "", fmt.Errorf("render failed: %w", err)
+	}
+
+	return out.String(), nil
+}
+
+func (h *HistoryRenderer) Best(best models.Best) (string, error) {
+	var out strings.Builder
+	if err := renderTemplate(&out, h.loc, h.tz, "best.gotmpl", best); err != nil {
+		return "", fmt.Errorf("render failed: %w", err)
+	}
+
+	return out.String(), nil
+}
+
+func (h *HistoryRenderer) Stats(stats models.Stats) (string, error) {
+	var out strings.Builder
+	if err := renderTemplate(&out, h.loc, h.tz, "stats.gotmpl", stats); err != nil {
+		return "", fmt.Errorf("render failed: %w", err)
+	}
+
+	return out.String(), nil
+}
+
+func (h *HistoryRenderer) Status(status models.Status) (string, error) {
+	var out strings.Builder
+	if err := renderTemplate(&out, h.loc, h.tz, "status.gotmpl", status); err != nil {
 		return "", fmt.Errorf("render failed: %w", err)
 	}
 
@@ -39,7 +111,7 @@ func (h *HistoryRenderer) Log(entries []models.History) (string, error) {
 
 func (h *HistoryRenderer) Graph(entries []models.History, out io.Writer, cfg *GraphConfig) (startDate time.Time, endDate time.Time, err error) {
 	var series []chart.TimeSeries
-	var prevData models.History
+	var prevValues []float64
 	for _, entry := range entries {
 		if len(series) == 0 {
 			series = make([]chart.TimeSeries, len(entry.Names))
@@ -54,14 +126,15 @@ func (h *HistoryRenderer) Graph(entries []models.History, out io.Writer, cfg *Gr
 			}
 		}
 
-		// fix up zeroes
+		// fix up zeroes on a copy, entries may be shared with the history cache
+		values := append([]float64(nil), entry.Values...)
 		ok := true
-		for i, val := range entry.Values {
+		for i, val := range values {
 			if val == 0.0 {
-				if prevData.When.IsZero() {
+				if prevValues == nil {
 					ok = false
 				} else {
-					entry.Values[i] = prevData.Values[i]
+					values[i] = prevValues[i]
 				}
 			}
 		}
@@ -69,16 +142,16 @@ func (h *HistoryRenderer) Graph(entries []models.History, out io.Writer, cfg *Gr
 			continue
 		}
 
-		for i := range entry.Values {
-			series[i].XValues = append(series[i].XValues, entry.When)
-			series[i].YValues = append(series[i].YValues, entry.Values[i])
+		for i := range values {
+			series[i].XValues = append(series[i].XValues, entry.When.In(cfg.location()))
+			series[i].YValues = append(series[i].YValues, values[i])
 		}
 
 		if startDate.IsZero() {
 			startDate = entry.When
 		}
 		endDate = entry.When
-		prevData = entry
+		prevValues = values
 	}
 
 	seriesesSize := 2
@@ -86,17 +159,29 @@ func (h *HistoryRenderer) Graph(entries []models.History, out io.Writer, cfg *Gr
 		seriesesSize++
 	}
 
+	palette := lightPalette
+	if cfg.dark {
+		palette = darkPalette
+	}
+
+	axisStyle := chart.Style{
+		Show:        true,
+		FontColor:   palette.text,
+		StrokeColor: palette.axis,
+	}
 	graph := chart.Chart{
-		Width:  cfg.width,
-		Height: cfg.height,
+		Width:      cfg.width,
+		Height:     cfg.height,
+		Background: chart.Style{FillColor: palette.background},
+		Canvas:     chart.Style{FillColor: palette.background},
 		XAxis: chart.XAxis{
 			Name:           "date",
-			Style:          chart.StyleShow(),
-			ValueFormatter: chart.TimeValueFormatterWithFormat("Mon 15:04"),
+			Style:          axisStyle,
+			ValueFormatter: cfg.timeFormatter("Mon 15:04"),
 			TickPosition:   chart.TickPositionUnderTick,
 		},
 		YAxis: chart.YAxis{
-			Style:    chart.StyleShow(),
+			Style:    axisStyle,
 			AxisType: chart.YAxisSecondary,
 		},
 		Series: make([]chart.Series, seriesesSize*len(series)),
@@ -123,8 +208,8 @@ func (h *HistoryRenderer) Graph(entries []models.History, out io.Writer, cfg *Gr
 
 	graph.Elements = []chart.Renderable{
 		chart.Legend(&graph, chart.Style{
-			FillColor:   drawing.ColorWhite,
-			FontColor:   chart.DefaultTextColor,
+			FillColor:   palette.background,
+			FontColor:   palette.text,
 			FontSize:    8.0,
 			StrokeColor: chart.ColorTransparent,
 		}),
@@ -132,3 +217,23 @@ func (h *HistoryRenderer) Graph(entries []models.History, out io.Writer, cfg *Gr
 
 	return startDate, endDate, graph.Render(chart.PNG, out)
 }
+
+type graphPalette struct {
+	background drawing.Color
+	text       drawing.Color
+	axis       drawing.Color
+}
+
+var (
+	lightPalette = graphPalette{
+		background: drawing.ColorWhite,
+		text:       chart.DefaultTextColor,
+		axis:       chart.DefaultAxisColor,
+	}
+
+	darkPalette = graphPalette{
+		background: drawing.ColorFromHex("1e1e1e"),
+		text:       drawing.ColorFromHex("d0d0d0"),
+		axis:       drawing.ColorFromHex("808080"),
+	}
+)
@@ -0,0 +1,30 @@
+package renderer
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/buglloc/sowettybot/internal/models"
+)
+
+func TestGraphKeepsEntries(t *testing.T) {
+	start := time.Date(2023, 6, 1, 0, 0, 0, 0, time.UTC)
+	entries := []models.History{
+		{When: start, Names: []string{"contact", "korona"}, Values: []float64{2.8, 2.9}},
+		{When: start.Add(time.Hour), Names: []string{"contact", "korona"}, Values: []float64{2.81, 0}},
+		{When: start.Add(2 * time.Hour), Names: []string{"contact", "korona"}, Values: []float64{2.82, 2.91}},
+	}
+
+	var out bytes.Buffer
+	from, to, err := NewHistoryRenderer().Graph(entries, &out, NewGraphConfig())
+	require.NoError(t, err)
+	require.NotZero(t, out.Len())
+	require.Equal(t, start, from)
+	require.Equal(t, start.Add(2*time.Hour), to)
+
+	// the failed fetch is drawn as the previous value, but stays zero in the entries
+	require.Equal(t, []float64{2.81, 0}, entries[1].Values)
+}
@@ -5,7 +5,11 @@ import (
 	"fmt"
 	"io"
 	"io/fs"
+	"sync"
 	"text/template"
+	"time"
+
+	"github.com/buglloc/sowettybot/internal/i18n"
 )
 
 //go:embed templates/*.gotmpl
@@ -17,18 +21,81 @@ var templates = func() *template.Template {
 		panic("can't create embed templates fs: " + err.Error())
 	}
 
-	funcMap := template.FuncMap{
-		"FormatRate": func(value float64) string {
-			return fmt.Sprintf("%.3f", value)
-		},
-	}
-
 	return template.Must(
-		template.New("").Funcs(funcMap).
+		template.New("").Funcs(funcMap(i18n.NewLocalizer(i18n.LangEN), time.Local)).
 			ParseFS(templates, "*.gotmpl"),
 	)
 }()
 
-func renderTemplate(w io.Writer, name string, data interface{}) error {
-	return templates.ExecuteTemplate(w, name, data)
+// localizedKey tells apart templates by language and time zone.
+type localizedKey struct {
+	lang i18n.Lang
+	tz   string
+}
+
+var (
+	localizedMu        sync.Mutex
+	localizedTemplates = make(map[localizedKey]*template.Template)
+)
+
+func funcMap(loc *i18n.Localizer, tz *time.Location) template.FuncMap {
+	return template.FuncMap{
+		"T": loc.T,
+		"N": loc.N,
+		"FormatRate": func(value float64) string {
+			return loc.Float(value, 3)
+		},
+		"FormatChange": func(value float64) string {
+			return loc.Signed(value, 3)
+		},
+		"FormatPct": func(value float64) string {
+			return loc.Signed(value, 2) + "%"
+		},
+		"FormatValue": func(value float64) string {
+			return loc.Float(value, 2)
+		},
+		"FormatAmount": func(value float64) string {
+			return loc.Float(value, 2)
+		},
+		"FormatAge": loc.Duration,
+		"FormatDateTime": func(t time.Time) string {
+			return loc.Time(t.In(tz), "layout.datetime")
+		},
+		"FormatTime": func(t time.Time) string {
+			return loc.Time(t.In(tz), "layout.time")
+		},
+		"Arrow": func(change float64) string {
+			switch {
+			case change > 0:
+				return "↑"
+			case change < 0:
+				return "↓"
+			default:
+				return "→"
+			}
+		},
+	}
+}
+
+// localized returns the templates for the language and time zone, there are as many as IANA zones at most.
+func localized(loc *i18n.Localizer, tz *time.Location) *template.Template {
+	localizedMu.Lock()
+	defer localizedMu.Unlock()
+
+	key := localizedKey{lang: loc.Lang(), tz: tz.String()}
+	if tmpl, ok := localizedTemplates[key]; ok {
+		return tmpl
+	}
+
+	tmpl := template.Must(templates.Clone()).Funcs(funcMap(loc, tz))
+	localizedTemplates[key] = tmpl
+	return tmpl
+}
+
+func renderTemplate(w io.Writer, loc *i18n.Localizer, tz *time.Location, name string, data interface{}) error {
+	if err := localized(loc, tz).ExecuteTemplate(w, name, data); err != nil {
+		return fmt.Errorf("execute %s: %w", name, err)
+	}
+
+	return nil
 }
@@ -15,3 +15,11 @@ func EscapeTgMd(in string) string {
 
 	return out.String()
 }
+
+// EscapeTgMdCode escapes backticks and backslashes, which EscapeTgMd leaves as markup: for the text put into
+// a code span or block and for names users choose themselves.
+func EscapeTgMdCode(in string) string {
+	return tgMdCodeReplacer.Replace(in)
+}
+
+var tgMdCodeReplacer = strings.NewReplacer("\\", "\\\\", "`", "\\`")
@@ -0,0 +1,242 @@
+package rules
+
+import (
+	"fmt"
+	"time"
+)
+
+type valueType int
+
+const (
+	typeNumber valueType = iota
+	typeBool
+	typeSeries
+	typeDuration
+)
+
+func (t valueType) String() string {
+	switch t {
+	case typeNumber:
+		return "number"
+	case typeBool:
+		return "boolean"
+	case typeSeries:
+		return "exchange"
+	case typeDuration:
+		return "duration"
+	default:
+		return fmt.Sprintf("type(%d)", int(t))
+	}
+}
+
+type value struct {
+	num    float64
+	b      bool
+	series string
+	dur    time.Duration
+}
+
+type node interface {
+	pos() int
+	typ() valueType
+	eval(env Env) (value, error)
+}
+
+type numberNode struct {
+	at  int
+	num float64
+}
+
+func (n *numberNode) pos() int       { return n.at }
+func (n *numberNode) typ() valueType { return typeNumber }
+func (n *numberNode) eval(Env) (value, error) {
+	return value{num: n.num}, nil
+}
+
+type durationNode struct {
+	at  int
+	dur time.Duration
+}
+
+func (n *durationNode) pos() int       { return n.at }
+func (n *durationNode) typ() valueType { return typeDuration }
+func (n *durationNode) eval(Env) (value, error) {
+	return value{dur: n.dur}, nil
+}
+
+type seriesNode struct {
+	at   int
+	name string
+}
+
+func (n *seriesNode) pos() int       { return n.at }
+func (n *seriesNode) typ() valueType { return typeSeries }
+func (n *seriesNode) eval(Env) (value, error) {
+	return value{series: n.name}, nil
+}
+
+// latestNode converts series into their latest value.
+type latestNode struct {
+	series *seriesNode
+}
+
+func (n *latestNode) pos() int       { return n.series.at }
+func (n *latestNode) typ() valueType { return typeNumber }
+func (n *latestNode) eval(env Env) (value, error) {
+	v, err := env.Latest(n.series.name)
+	if err != nil {
+		return value{}, newError(n.series.at, "%v", err)
+	}
+
+	return value{num: v}, nil
+}
+
+type negNode struct {
+	at      int
+	operand node
+}
+
+func (n *negNode) pos() int       { return n.at }
+func (n *negNode) typ() valueType { return typeNumber }
+func (n *negNode) eval(env Env) (value, error) {
+	v, err := n.operand.eval(env)
+	if err != nil {
+		return value{}, err
+	}
+
+	return value{num: -v.num}, nil
+}
+
+type notNode struct {
+	at      int
+	operand node
+}
+
+func (n *notNode) pos() int       { return n.at }
+func (n *notNode) typ() valueType { return typeBool }
+func (n *notNode) eval(env Env) (value, error) {
+	v, err := n.operand.eval(env)
+	if err != nil {
+		return value{}, err
+	}
+
+	return value{b: !v.b}, nil
+}
+
+type binaryNode struct {
+	at          int
+	op          tokenKind
+	left, right node
+}
+
+func (n *binaryNode) pos() int { return n.at }
+func (n *binaryNode) typ() valueType {
+	switch n.op {
+	case tokenPlus, tokenMinus, tokenMul, tokenDiv:
+		return typeNumber
+	default:
+		return typeBool
+	}
+}
+
+func (n *binaryNode) eval(env Env) (value, error) {
+	l, err := n.left.eval(env)
+	if err != nil {
+		return value{}, err
+	}
+
+	// short-circuit logic operators
+	switch {
+	case n.op == tokenAnd && !l.b:
+		return value{b: false}, nil
+	case n.op == tokenOr && l.b:
+		return value{b: true}, nil
+	}
+
+	r, err := n.right.eval(env)
+	if err != nil {
+		return value{}, err
+	}
+
+	switch n.op {
+	case tokenPlus:
+		return value{num: l.num + r.num}, nil
+	case tokenMinus:
+		return value{num: l.num - r.num}, nil
+	case tokenMul:
+		return value{num: l.num * r.num}, nil
+	case tokenDiv:
+		if r.num == 0 {
+			return value{}, newError(n.right.pos(), "division by zero")
+		}
+		return value{num: l.num / r.num}, nil
+	case tokenLT:
+		return value{b: l.num < r.num}, nil
+	case tokenLE:
+		return value{b: l.num <= r.num}, nil
+	case tokenGT:
+		return value{b: l.num > r.num}, nil
+	case tokenGE:
+		return value{b: l.num >= r.num}, nil
+	case tokenEQ:
+		return value{b: l.num == r.num}, nil
+	case tokenNE:
+		return value{b: l.num != r.num}, nil
+	case tokenAnd, tokenOr:
+		return value{b: r.b}, nil
+	default:
+		return value{}, newError(n.at, "unsupported operator %s", n.op)
+	}
+}
+
+type betweenNode struct {
+	at              int
+	operand, lo, hi node
+}
+
+func (n *betweenNode) pos() int       { return n.at }
+func (n *betweenNode) typ() valueType { return typeBool }
+func (n *betweenNode) eval(env Env) (value, error) {
+	v, err := n.operand.eval(env)
+	if err != nil {
+		return value{}, err
+	}
+
+	lo, err := n.lo.eval(env)
+	if err != nil {
+		return value{}, err
+	}
+
+	hi, err := n.hi.eval(env)
+	if err != nil {
+		return value{}, err
+	}
+
+	return value{b: v.num >= lo.num && v.num <= hi.num}, nil
+}
+
+type callNode struct {
+	at   int
+	fn   *function
+	args []node
+}
+
+func (n *callNode) pos() int       { return n.at }
+func (n *callNode) typ() valueType { return typeNumber }
+func (n *callNode) eval(env Env) (value, error) {
+	args := make([]value, len(n.args))
+	for i, arg := range n.args {
+		v, err := arg.eval(env)
+		if err != nil {
+			return value{}, err
+		}
+		args[i] = v
+	}
+
+	v, err := n.fn.call(env, args)
+	if err != nil {
+		return value{}, newError(n.at, "%s: %v", n.fn.name, err)
+	}
+
+	return value{num: v}, nil
+}
@@ -0,0 +1,86 @@
+package rules
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/buglloc/sowettybot/internal/models"
+)
+
+type Env interface {
+	Now() time.Time
+	Latest(series string) (float64, error)
+	Window(series string, d time.Duration) ([]float64, error)
+}
+
+var _ Env = (*HistoryEnv)(nil)
+
+type HistoryEnv struct {
+	entries []models.History
+	now     time.Time
+}
+
+func NewHistoryEnv(entries []models.History, now time.Time) *HistoryEnv {
+	return &HistoryEnv{
+		entries: entries,
+		now:     now,
+	}
+}
+
+// In returns the env with the current time in the time zone, hour() and weekday() depend on it.
+func (e *HistoryEnv) In(tz *time.Location) *HistoryEnv {
+	return &HistoryEnv{
+		entries: e.entries,
+		now:     e.now.In(tz),
+	}
+}
+
+func (e *HistoryEnv) Now() time.Time {
+	return e.now
+}
+
+func (e *HistoryEnv) Latest(series string) (float64, error) {
+	for i := len(e.entries) - 1; i >= 0; i-- {
+		idx := seriesIndex(e.entries[i], series)
+		if idx < 0 {
+			return 0, fmt.Errorf("no history for %q", series)
+		}
+
+		if v := e.entries[i].Values[idx]; v != 0.0 {
+			return v, nil
+		}
+	}
+
+	return 0, fmt.Errorf("no history for %q", series)
+}
+
+func (e *HistoryEnv) Window(series string, d time.Duration) ([]float64, error) {
+	since := e.now.Add(-d)
+	var out []float64
+	for _, entry := range e.entries {
+		if entry.When.Before(since) {
+			continue
+		}
+
+		idx := seriesIndex(entry, series)
+		if idx < 0 {
+			return nil, fmt.Errorf("no history for %q", series)
+		}
+
+		if v := entry.Values[idx]; v != 0.0 {
+			out = append(out, v)
+		}
+	}
+
+	return out, nil
+}
+
+func seriesIndex(entry models.History, series string) int {
+	for i, name := range entry.Names {
+		if name == series && i < len(entry.Values) {
+			return i
+		}
+	}
+
+	return -1
+}
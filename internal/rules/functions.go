@@ -0,0 +1,168 @@
+package rules
+
+import (
+	"errors"
+	"math"
+	"sort"
+	"strings"
+)
+
+var errNoData = errors.New("no data in the window")
+
+type function struct {
+	name     string
+	args     []valueType
+	variadic bool
+	call     func(env Env, args []value) (float64, error)
+}
+
+var functions = map[string]*function{}
+
+func init() {
+	register := func(fn *function) {
+		functions[fn.name] = fn
+	}
+
+	register(&function{
+		name:     "min",
+		args:     []valueType{typeNumber, typeNumber},
+		variadic: true,
+		call: func(_ Env, args []value) (float64, error) {
+			out := args[0].num
+			for _, arg := range args[1:] {
+				out = math.Min(out, arg.num)
+			}
+			return out, nil
+		},
+	})
+
+	register(&function{
+		name:     "max",
+		args:     []valueType{typeNumber, typeNumber},
+		variadic: true,
+		call: func(_ Env, args []value) (float64, error) {
+			out := args[0].num
+			for _, arg := range args[1:] {
+				out = math.Max(out, arg.num)
+			}
+			return out, nil
+		},
+	})
+
+	register(&function{
+		name: "abs",
+		args: []valueType{typeNumber},
+		call: func(_ Env, args []value) (float64, error) {
+			return math.Abs(args[0].num), nil
+		},
+	})
+
+	register(&function{
+		name: "sma",
+		args: []valueType{typeSeries, typeDuration},
+		call: func(env Env, args []value) (float64, error) {
+			values, err := env.Window(args[0].series, args[1].dur)
+			if err != nil {
+				return 0, err
+			}
+
+			if len(values) == 0 {
+				return 0, errNoData
+			}
+
+			var sum float64
+			for _, v := range values {
+				sum += v
+			}
+			return sum / float64(len(values)), nil
+		},
+	})
+
+	register(&function{
+		name: "lowest",
+		args: []valueType{typeSeries, typeDuration},
+		call: func(env Env, args []value) (float64, error) {
+			values, err := env.Window(args[0].series, args[1].dur)
+			if err != nil {
+				return 0, err
+			}
+
+			if len(values) == 0 {
+				return 0, errNoData
+			}
+
+			out := values[0]
+			for _, v := range values[1:] {
+				out = math.Min(out, v)
+			}
+			return out, nil
+		},
+	})
+
+	register(&function{
+		name: "highest",
+		args: []valueType{typeSeries, typeDuration},
+		call: func(env Env, args []value) (float64, error) {
+			values, err := env.Window(args[0].series, args[1].dur)
+			if err != nil {
+				return 0, err
+			}
+
+			if len(values) == 0 {
+				return 0, errNoData
+			}
+
+			out := values[0]
+			for _, v := range values[1:] {
+				out = math.Max(out, v)
+			}
+			return out, nil
+		},
+	})
+
+	register(&function{
+		name: "change",
+		args: []valueType{typeSeries, typeDuration},
+		call: func(env Env, args []value) (float64, error) {
+			values, err := env.Window(args[0].series, args[1].dur)
+			if err != nil {
+				return 0, err
+			}
+
+			if len(values) == 0 {
+				return 0, errNoData
+			}
+
+			return values[len(values)-1] - values[0], nil
+		},
+	})
+
+	register(&function{
+		name: "hour",
+		call: func(env Env, _ []value) (float64, error) {
+			return float64(env.Now().Hour()), nil
+		},
+	})
+
+	register(&function{
+		name: "weekday",
+		call: func(env Env, _ []value) (float64, error) {
+			// ISO weekday: monday is 1, sunday is 7
+			day := int(env.Now().Weekday())
+			if day == 0 {
+				day = 7
+			}
+			return float64(day), nil
+		},
+	})
+}
+
+func functionNames() string {
+	names := make([]string, 0, len(functions))
+	for name := range functions {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
@@ -0,0 +1,231 @@
+package rules
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenNumber
+	tokenDuration
+	tokenIdent
+	tokenLParen
+	tokenRParen
+	tokenComma
+	tokenPlus
+	tokenMinus
+	tokenMul
+	tokenDiv
+	tokenLT
+	tokenLE
+	tokenGT
+	tokenGE
+	tokenEQ
+	tokenNE
+	tokenAnd
+	tokenOr
+	tokenNot
+	tokenBetween
+)
+
+var keywords = map[string]tokenKind{
+	"and":     tokenAnd,
+	"or":      tokenOr,
+	"not":     tokenNot,
+	"between": tokenBetween,
+}
+
+var tokenNames = map[tokenKind]string{
+	tokenEOF:      "end of rule",
+	tokenNumber:   "number",
+	tokenDuration: "duration",
+	tokenIdent:    "identifier",
+	tokenLParen:   "'('",
+	tokenRParen:   "')'",
+	tokenComma:    "','",
+	tokenPlus:     "'+'",
+	tokenMinus:    "'-'",
+	tokenMul:      "'*'",
+	tokenDiv:      "'/'",
+	tokenLT:       "'<'",
+	tokenLE:       "'<='",
+	tokenGT:       "'>'",
+	tokenGE:       "'>='",
+	tokenEQ:       "'=='",
+	tokenNE:       "'!='",
+	tokenAnd:      "'and'",
+	tokenOr:       "'or'",
+	tokenNot:      "'not'",
+	tokenBetween:  "'between'",
+}
+
+func (k tokenKind) String() string {
+	return tokenNames[k]
+}
+
+type token struct {
+	kind tokenKind
+	pos  int
+	text string
+	num  float64
+	dur  time.Duration
+}
+
+func lex(src string) ([]token, error) {
+	var out []token
+	for i := 0; i < len(src); {
+		c := rune(src[i])
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case c >= '0' && c <= '9' || c == '.':
+			tok, n, err := lexNumber(src, i)
+			if err != nil {
+				return nil, err
+			}
+
+			out = append(out, tok)
+			i += n
+		case c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z':
+			start := i
+			for i < len(src) && isIdentRune(src[i]) {
+				i++
+			}
+
+			text := src[start:i]
+			kind, ok := keywords[strings.ToLower(text)]
+			if !ok {
+				kind = tokenIdent
+			}
+			out = append(out, token{kind: kind, pos: start, text: text})
+		default:
+			kind, n := lexOperator(src[i:])
+			if n == 0 {
+				return nil, newError(i, "unexpected character %q", c)
+			}
+
+			out = append(out, token{kind: kind, pos: i, text: src[i : i+n]})
+			i += n
+		}
+	}
+
+	out = append(out, token{kind: tokenEOF, pos: len(src)})
+	return out, nil
+}
+
+func lexNumber(src string, start int) (token, int, error) {
+	i := start
+	for i < len(src) && (src[i] >= '0' && src[i] <= '9' || src[i] == '.') {
+		i++
+	}
+
+	num, err := strconv.ParseFloat(src[start:i], 64)
+	if err != nil {
+		return token{}, 0, newError(start, "invalid number %q", src[start:i])
+	}
+
+	unitStart := i
+	for i < len(src) && unicode.IsLetter(rune(src[i])) {
+		i++
+	}
+
+	if unitStart == i {
+		return token{kind: tokenNumber, pos: start, text: src[start:i], num: num}, i - start, nil
+	}
+
+	unit, ok := durationUnits[src[unitStart:i]]
+	if !ok {
+		return token{}, 0, newError(unitStart, "unknown duration unit %q (m, h, d or w expected)", src[unitStart:i])
+	}
+
+	return token{
+		kind: tokenDuration,
+		pos:  start,
+		text: src[start:i],
+		dur:  time.Duration(num * float64(unit)),
+	}, i - start, nil
+}
+
+var durationUnits = map[string]time.Duration{
+	"m": time.Minute,
+	"h": time.Hour,
+	"d": 24 * time.Hour,
+	"w": 7 * 24 * time.Hour,
+}
+
+func lexOperator(src string) (tokenKind, int) {
+	if len(src) >= 2 {
+		switch src[:2] {
+		case "<=":
+			return tokenLE, 2
+		case ">=":
+			return tokenGE, 2
+		case "==":
+			return tokenEQ, 2
+		case "!=":
+			return tokenNE, 2
+		case "&&":
+			return tokenAnd, 2
+		case "||":
+			return tokenOr, 2
+		}
+	}
+
+	switch src[0] {
+	case '(':
+		return tokenLParen, 1
+	case ')':
+		return tokenRParen, 1
+	case ',':
+		return tokenComma, 1
+	case '+':
+		return tokenPlus, 1
+	case '-':
+		return tokenMinus, 1
+	case '*':
+		return tokenMul, 1
+	case '/':
+		return tokenDiv, 1
+	case '<':
+		return tokenLT, 1
+	case '>':
+		return tokenGT, 1
+	case '=':
+		return tokenEQ, 1
+	case '!':
+		return tokenNot, 1
+	}
+
+	return tokenEOF, 0
+}
+
+func isIdentRune(c byte) bool {
+	return c == '_' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+type Error struct {
+	Pos int
+	Msg string
+}
+
+func newError(pos int, format string, a ...interface{}) *Error {
+	return &Error{
+		Pos: pos,
+		Msg: fmt.Sprintf(format, a...),
+	}
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("col %d: %s", e.Pos+1, e.Msg)
+}
+
+// Pointer returns the rule source with a caret under the error position.
+func (e *Error) Pointer(src string) string {
+	return fmt.Sprintf("%s\n%s^", src, strings.Repeat(" ", e.Pos))
+}
@@ -0,0 +1,384 @@
+package rules
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+type parser struct {
+	tokens []token
+	cur    int
+	series map[string]struct{}
+}
+
+func parse(src string, series []string) (node, error) {
+	tokens, err := lex(src)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &parser{
+		tokens: tokens,
+		series: make(map[string]struct{}, len(series)),
+	}
+	for _, name := range series {
+		p.series[name] = struct{}{}
+	}
+
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+
+	if tok := p.peek(); tok.kind != tokenEOF {
+		return nil, newError(tok.pos, "unexpected %s", describe(tok))
+	}
+
+	if root.typ() != typeBool {
+		return nil, newError(root.pos(), "rule must be a condition, got %s", root.typ())
+	}
+
+	return root, nil
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.cur]
+}
+
+func (p *parser) next() token {
+	tok := p.tokens[p.cur]
+	if tok.kind != tokenEOF {
+		p.cur++
+	}
+	return tok
+}
+
+func (p *parser) expect(kind tokenKind) (token, error) {
+	tok := p.next()
+	if tok.kind != kind {
+		return tok, newError(tok.pos, "expected %s, got %s", kind, describe(tok))
+	}
+
+	return tok, nil
+}
+
+func (p *parser) parseOr() (node, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.peek().kind == tokenOr {
+		op := p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+
+		left, err = logical(op, left, right)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return left, nil
+}
+
+func (p *parser) parseAnd() (node, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.peek().kind == tokenAnd {
+		op := p.next()
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+
+		left, err = logical(op, left, right)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return left, nil
+}
+
+func (p *parser) parseNot() (node, error) {
+	if p.peek().kind != tokenNot {
+		return p.parseComparison()
+	}
+
+	op := p.next()
+	operand, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+
+	if operand.typ() != typeBool {
+		return nil, newError(operand.pos(), "'not' expects a condition, got %s", operand.typ())
+	}
+
+	return &notNode{at: op.pos, operand: operand}, nil
+}
+
+func (p *parser) parseComparison() (node, error) {
+	left, err := p.parseSum()
+	if err != nil {
+		return nil, err
+	}
+
+	switch op := p.peek(); op.kind {
+	case tokenLT, tokenLE, tokenGT, tokenGE, tokenEQ, tokenNE:
+		p.next()
+		right, err := p.parseSum()
+		if err != nil {
+			return nil, err
+		}
+
+		return arithmetic(op, left, right)
+	case tokenBetween:
+		p.next()
+		lo, err := p.parseSum()
+		if err != nil {
+			return nil, err
+		}
+
+		if _, err := p.expect(tokenAnd); err != nil {
+			return nil, err
+		}
+
+		hi, err := p.parseSum()
+		if err != nil {
+			return nil, err
+		}
+
+		operands := []node{left, lo, hi}
+		for i, operand := range operands {
+			operands[i], err = asNumber(operand)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		return &betweenNode{at: op.pos, operand: operands[0], lo: operands[1], hi: operands[2]}, nil
+	}
+
+	return left, nil
+}
+
+func (p *parser) parseSum() (node, error) {
+	left, err := p.parseProduct()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		op := p.peek()
+		if op.kind != tokenPlus && op.kind != tokenMinus {
+			return left, nil
+		}
+
+		p.next()
+		right, err := p.parseProduct()
+		if err != nil {
+			return nil, err
+		}
+
+		left, err = arithmetic(op, left, right)
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (p *parser) parseProduct() (node, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		op := p.peek()
+		if op.kind != tokenMul && op.kind != tokenDiv {
+			return left, nil
+		}
+
+		p.next()
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+
+		left, err = arithmetic(op, left, right)
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (p *parser) parseUnary() (node, error) {
+	if p.peek().kind != tokenMinus {
+		return p.parsePrimary()
+	}
+
+	op := p.next()
+	operand, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+
+	operand, err = asNumber(operand)
+	if err != nil {
+		return nil, err
+	}
+
+	return &negNode{at: op.pos, operand: operand}, nil
+}
+
+func (p *parser) parsePrimary() (node, error) {
+	tok := p.next()
+	switch tok.kind {
+	case tokenNumber:
+		return &numberNode{at: tok.pos, num: tok.num}, nil
+	case tokenDuration:
+		return &durationNode{at: tok.pos, dur: tok.dur}, nil
+	case tokenLParen:
+		inner, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+
+		if _, err := p.expect(tokenRParen); err != nil {
+			return nil, err
+		}
+		return inner, nil
+	case tokenIdent:
+		if p.peek().kind == tokenLParen {
+			return p.parseCall(tok)
+		}
+
+		if _, ok := p.series[tok.text]; !ok {
+			return nil, newError(tok.pos, "unknown exchange %q (known: %s)", tok.text, p.seriesNames())
+		}
+		return &seriesNode{at: tok.pos, name: tok.text}, nil
+	default:
+		return nil, newError(tok.pos, "unexpected %s", describe(tok))
+	}
+}
+
+func (p *parser) parseCall(name token) (node, error) {
+	fn, ok := functions[strings.ToLower(name.text)]
+	if !ok {
+		return nil, newError(name.pos, "unknown function %q (known: %s)", name.text, functionNames())
+	}
+
+	// skip '('
+	p.next()
+
+	var args []node
+	if p.peek().kind != tokenRParen {
+		for {
+			arg, err := p.parseOr()
+			if err != nil {
+				return nil, err
+			}
+
+			args = append(args, arg)
+			if p.peek().kind != tokenComma {
+				break
+			}
+			p.next()
+		}
+	}
+
+	closing, err := p.expect(tokenRParen)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case fn.variadic && len(args) < len(fn.args):
+		return nil, newError(closing.pos, "%s expects at least %d arguments, got %d", fn.name, len(fn.args), len(args))
+	case !fn.variadic && len(args) != len(fn.args):
+		return nil, newError(closing.pos, "%s expects %d arguments, got %d", fn.name, len(fn.args), len(args))
+	}
+
+	for i, arg := range args {
+		expected := fn.args[len(fn.args)-1]
+		if i < len(fn.args) {
+			expected = fn.args[i]
+		}
+
+		if expected == typeNumber {
+			args[i], err = asNumber(arg)
+			if err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		if arg.typ() != expected {
+			return nil, newError(arg.pos(), "%s argument #%d must be %s, got %s", fn.name, i+1, expected, arg.typ())
+		}
+	}
+
+	return &callNode{at: name.pos, fn: fn, args: args}, nil
+}
+
+func (p *parser) seriesNames() string {
+	names := make([]string, 0, len(p.series))
+	for name := range p.series {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+func arithmetic(op token, left, right node) (node, error) {
+	var err error
+	left, err = asNumber(left)
+	if err != nil {
+		return nil, err
+	}
+
+	right, err = asNumber(right)
+	if err != nil {
+		return nil, err
+	}
+
+	return &binaryNode{at: op.pos, op: op.kind, left: left, right: right}, nil
+}
+
+func logical(op token, left, right node) (node, error) {
+	for _, operand := range []node{left, right} {
+		if operand.typ() != typeBool {
+			return nil, newError(operand.pos(), "%s expects conditions, got %s", op.kind, operand.typ())
+		}
+	}
+
+	return &binaryNode{at: op.pos, op: op.kind, left: left, right: right}, nil
+}
+
+func asNumber(n node) (node, error) {
+	switch n.typ() {
+	case typeNumber:
+		return n, nil
+	case typeSeries:
+		return &latestNode{series: n.(*seriesNode)}, nil
+	default:
+		return nil, newError(n.pos(), "number expected, got %s", n.typ())
+	}
+}
+
+func describe(tok token) string {
+	if tok.kind == tokenEOF {
+		return tok.kind.String()
+	}
+
+	return fmt.Sprintf("%q", tok.text)
+}
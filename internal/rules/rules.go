@@ -0,0 +1,39 @@
+package rules
+
+import "strings"
+
+type Rule struct {
+	src  string
+	root node
+}
+
+// Compile parses the rule and checks it against the known exchange slugs.
+func Compile(src string, series []string) (*Rule, error) {
+	src = strings.TrimSpace(src)
+	if src == "" {
+		return nil, newError(0, "empty rule")
+	}
+
+	root, err := parse(src, series)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Rule{
+		src:  src,
+		root: root,
+	}, nil
+}
+
+func (r *Rule) String() string {
+	return r.src
+}
+
+func (r *Rule) Eval(env Env) (bool, error) {
+	v, err := r.root.eval(env)
+	if err != nil {
+		return false, err
+	}
+
+	return v.b, nil
+}
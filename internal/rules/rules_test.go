@@ -0,0 +1,131 @@
+package rules
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/buglloc/sowettybot/internal/models"
+)
+
+var testSeries = []string{"contact", "korona"}
+
+func testEnv() Env {
+	start := time.Date(2023, 6, 30, 0, 0, 0, 0, time.UTC)
+	var entries []models.History
+	for i := 0; i < 48; i++ {
+		entries = append(entries, models.History{
+			When:   start.Add(time.Duration(i) * time.Hour),
+			Names:  testSeries,
+			Values: []float64{3.0, 3.0 - float64(i)*0.01},
+		})
+	}
+
+	// last korona value is 2.53, contact is 3.0; evaluated at 10:00 on saturday
+	return NewHistoryEnv(entries, start.Add(47*time.Hour+10*time.Minute))
+}
+
+func TestEval(t *testing.T) {
+	cases := []struct {
+		rule     string
+		expected bool
+	}{
+		{rule: "korona < 2.9", expected: true},
+		{rule: "korona < contact - 0.02", expected: true},
+		{rule: "korona > contact", expected: false},
+		{rule: "min(korona, contact) < sma(korona, 24h) * 0.99", expected: true},
+		{rule: "max(korona, contact, 2.5) == 3", expected: true},
+		{rule: "korona < 2.9 and hour() between 9 and 18", expected: false},
+		{rule: "korona < 2.9 and hour() between 20 and 23", expected: true},
+		{rule: "weekday() == 6 or korona > 100", expected: true},
+		{rule: "not (korona < 2.9)", expected: false},
+		{rule: "lowest(korona, 2d) == korona", expected: true},
+		{rule: "highest(contact, 1w) == 3", expected: true},
+		{rule: "change(korona, 10h) < -0.05", expected: true},
+		{rule: "abs(korona - contact) > 0.4", expected: true},
+		{rule: "-korona < -2", expected: true},
+		{rule: "korona <= 2.53 && contact >= 3", expected: true},
+	}
+
+	env := testEnv()
+	for _, tc := range cases {
+		t.Run(tc.rule, func(t *testing.T) {
+			rule, err := Compile(tc.rule, testSeries)
+			require.NoError(t, err)
+
+			actual, err := rule.Eval(env)
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, actual)
+		})
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	cases := []struct {
+		rule string
+		pos  int
+	}{
+		{rule: "", pos: 0},
+		{rule: "korona", pos: 0},
+		{rule: "koronna < 2.9", pos: 0},
+		{rule: "korona < 2.9 and", pos: 16},
+		{rule: "korona < 2.9 2", pos: 13},
+		{rule: "korona < 2.9 and hour() between 9", pos: 33},
+		{rule: "sma(korona) < 2", pos: 10},
+		{rule: "sma(2, 24h) < 2", pos: 4},
+		{rule: "foo(korona) < 2", pos: 0},
+		{rule: "korona < 24x", pos: 11},
+		{rule: "korona < 2.9 + (contact > 1)", pos: 24},
+		{rule: "(korona < 2", pos: 11},
+		{rule: "korona ^ 2", pos: 7},
+		{rule: "korona < 1 < 2", pos: 11},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.rule, func(t *testing.T) {
+			_, err := Compile(tc.rule, testSeries)
+			require.Error(t, err)
+
+			var ruleErr *Error
+			require.True(t, errors.As(err, &ruleErr))
+			require.Equal(t, tc.pos, ruleErr.Pos, ruleErr.Error())
+		})
+	}
+}
+
+func TestEvalLocation(t *testing.T) {
+	tz, err := time.LoadLocation("Asia/Bangkok")
+	require.NoError(t, err)
+
+	// 23:10 on saturday in UTC is 06:10 on sunday in Bangkok
+	env := testEnv().(*HistoryEnv).In(tz)
+	cases := []struct {
+		rule     string
+		expected bool
+	}{
+		{rule: "hour() == 6", expected: true},
+		{rule: "hour() between 20 and 23", expected: false},
+		{rule: "weekday() == 7", expected: true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.rule, func(t *testing.T) {
+			rule, err := Compile(tc.rule, testSeries)
+			require.NoError(t, err)
+
+			actual, err := rule.Eval(env)
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, actual)
+		})
+	}
+}
+
+func TestEvalErrors(t *testing.T) {
+	rule, err := Compile("sma(korona, 1h) < 2", testSeries)
+	require.NoError(t, err)
+
+	env := NewHistoryEnv(nil, time.Now())
+	_, err = rule.Eval(env)
+	require.Error(t, err)
+}
@@ -0,0 +1,38 @@
+package scheduler
+
+import "time"
+
+// NextFunc returns the first run time strictly after now.
+type NextFunc func(now time.Time) time.Time
+
+func Every(period time.Duration) NextFunc {
+	return func(now time.Time) time.Time {
+		return now.Add(period)
+	}
+}
+
+func Daily(hour, minute int, loc *time.Location) NextFunc {
+	return func(now time.Time) time.Time {
+		now = now.In(loc)
+		next := time.Date(now.Year(), now.Month(), now.Day(), hour, minute, 0, 0, loc)
+		if !next.After(now) {
+			// built from the date again, the clock may have moved to skip a DST gap
+			next = time.Date(now.Year(), now.Month(), now.Day()+1, hour, minute, 0, 0, loc)
+		}
+
+		return next
+	}
+}
+
+func Weekly(day time.Weekday, hour, minute int, loc *time.Location) NextFunc {
+	return func(now time.Time) time.Time {
+		now = now.In(loc)
+		days := (int(day) - int(now.Weekday()) + 7) % 7
+		next := time.Date(now.Year(), now.Month(), now.Day()+days, hour, minute, 0, 0, loc)
+		if !next.After(now) {
+			next = time.Date(now.Year(), now.Month(), now.Day()+days+7, hour, minute, 0, 0, loc)
+		}
+
+		return next
+	}
+}
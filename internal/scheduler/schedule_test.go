@@ -0,0 +1,135 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDaily(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	require.NoError(t, err)
+
+	cases := []struct {
+		name   string
+		hour   int
+		minute int
+		now    time.Time
+		next   time.Time
+	}{
+		{
+			name: "later_today",
+			hour: 9,
+			now:  time.Date(2023, 7, 1, 8, 0, 0, 0, berlin),
+			next: time.Date(2023, 7, 1, 9, 0, 0, 0, berlin),
+		},
+		{
+			name: "tomorrow",
+			hour: 9,
+			now:  time.Date(2023, 7, 1, 10, 0, 0, 0, berlin),
+			next: time.Date(2023, 7, 2, 9, 0, 0, 0, berlin),
+		},
+		{
+			name: "strictly_after",
+			hour: 9,
+			now:  time.Date(2023, 7, 1, 9, 0, 0, 0, berlin),
+			next: time.Date(2023, 7, 2, 9, 0, 0, 0, berlin),
+		},
+		{
+			name: "other_zone",
+			hour: 9,
+			now:  time.Date(2023, 7, 1, 7, 30, 0, 0, time.UTC),
+			next: time.Date(2023, 7, 2, 9, 0, 0, 0, berlin),
+		},
+		{
+			name: "month_rollover",
+			hour: 9,
+			now:  time.Date(2023, 6, 30, 10, 0, 0, 0, berlin),
+			next: time.Date(2023, 7, 1, 9, 0, 0, 0, berlin),
+		},
+		{
+			name:   "after_dst_gap",
+			hour:   2,
+			minute: 30,
+			now:    time.Date(2023, 3, 26, 12, 0, 0, 0, berlin),
+			next:   time.Date(2023, 3, 27, 2, 30, 0, 0, berlin),
+		},
+		{
+			name: "dst_end",
+			hour: 9,
+			now:  time.Date(2023, 10, 28, 12, 0, 0, 0, berlin),
+			next: time.Date(2023, 10, 29, 9, 0, 0, 0, berlin),
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			next := Daily(tc.hour, tc.minute, berlin)(tc.now)
+			require.Equal(t, tc.next.Format(time.RFC3339), next.Format(time.RFC3339))
+		})
+	}
+}
+
+func TestWeekly(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	require.NoError(t, err)
+
+	cases := []struct {
+		name   string
+		day    time.Weekday
+		hour   int
+		minute int
+		now    time.Time
+		next   time.Time
+	}{
+		{
+			name: "later_this_week",
+			day:  time.Monday,
+			hour: 9,
+			now:  time.Date(2023, 7, 2, 12, 0, 0, 0, berlin),
+			next: time.Date(2023, 7, 3, 9, 0, 0, 0, berlin),
+		},
+		{
+			name: "later_today",
+			day:  time.Monday,
+			hour: 9,
+			now:  time.Date(2023, 7, 3, 8, 0, 0, 0, berlin),
+			next: time.Date(2023, 7, 3, 9, 0, 0, 0, berlin),
+		},
+		{
+			name: "next_week",
+			day:  time.Monday,
+			hour: 9,
+			now:  time.Date(2023, 7, 3, 9, 0, 0, 0, berlin),
+			next: time.Date(2023, 7, 10, 9, 0, 0, 0, berlin),
+		},
+		{
+			name: "year_rollover",
+			day:  time.Monday,
+			hour: 9,
+			now:  time.Date(2023, 12, 30, 12, 0, 0, 0, berlin),
+			next: time.Date(2024, 1, 1, 9, 0, 0, 0, berlin),
+		},
+		{
+			name:   "after_dst_gap",
+			day:    time.Sunday,
+			hour:   2,
+			minute: 30,
+			now:    time.Date(2023, 3, 26, 12, 0, 0, 0, berlin),
+			next:   time.Date(2023, 4, 2, 2, 30, 0, 0, berlin),
+		},
+		{
+			name: "dst_end",
+			day:  time.Sunday,
+			hour: 9,
+			now:  time.Date(2023, 10, 25, 12, 0, 0, 0, berlin),
+			next: time.Date(2023, 10, 29, 9, 0, 0, 0, berlin),
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			next := Weekly(tc.day, tc.hour, tc.minute, berlin)(tc.now)
+			require.Equal(t, tc.next.Format(time.RFC3339), next.Format(time.RFC3339))
+		})
+	}
+}
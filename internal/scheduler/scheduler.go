@@ -0,0 +1,160 @@
+package scheduler
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+type job struct {
+	name  string
+	next  NextFunc
+	run   func()
+	runAt time.Time
+}
+
+// Scheduler runs every due job in its own goroutine, so a slow one doesn't hold back the others.
+// A job still running when it's due again skips that run.
+type Scheduler struct {
+	mu      sync.Mutex
+	jobs    map[string]*job
+	running map[string]bool
+	wg      sync.WaitGroup
+	wakeup  chan struct{}
+}
+
+func NewScheduler() *Scheduler {
+	return &Scheduler{
+		jobs:    make(map[string]*job),
+		running: make(map[string]bool),
+		wakeup:  make(chan struct{}, 1),
+	}
+}
+
+// Add registers the job or replaces the existing one with the same name.
+func (s *Scheduler) Add(name string, next NextFunc, run func()) {
+	s.mu.Lock()
+	s.jobs[name] = &job{
+		name:  name,
+		next:  next,
+		run:   run,
+		runAt: next(time.Now()),
+	}
+	s.mu.Unlock()
+
+	s.notify()
+}
+
+func (s *Scheduler) Remove(name string) {
+	s.mu.Lock()
+	delete(s.jobs, name)
+	s.mu.Unlock()
+
+	s.notify()
+}
+
+// Len returns the number of scheduled jobs.
+func (s *Scheduler) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return len(s.jobs)
+}
+
+// Run runs the jobs until the context is done, then waits for the running ones.
+func (s *Scheduler) Run(ctx context.Context) {
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	defer s.wg.Wait()
+
+	for {
+		if !timer.Stop() {
+			select {
+			case <-timer.C:
+			default:
+			}
+		}
+		timer.Reset(s.untilNext())
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.wakeup:
+		case <-timer.C:
+			for _, j := range s.dueJobs(time.Now()) {
+				s.wg.Add(1)
+				go s.runJob(j)
+			}
+		}
+	}
+}
+
+func (s *Scheduler) untilNext() time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// no jobs yet, sleep until someone adds one
+	wait := 24 * time.Hour
+	now := time.Now()
+	for _, j := range s.jobs {
+		if d := j.runAt.Sub(now); d < wait {
+			wait = d
+		}
+	}
+
+	if wait < 0 {
+		return 0
+	}
+	return wait
+}
+
+func (s *Scheduler) dueJobs(now time.Time) []*job {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var out []*job
+	for _, j := range s.jobs {
+		if j.runAt.After(now) {
+			continue
+		}
+
+		j.runAt = j.next(now)
+		if s.running[j.name] {
+			log.Warn().Str("job", j.name).Msg("job is still running, skip it")
+			continue
+		}
+
+		s.running[j.name] = true
+		out = append(out, j)
+	}
+
+	return out
+}
+
+func (s *Scheduler) runJob(j *job) {
+	defer s.wg.Done()
+	defer func() {
+		s.mu.Lock()
+		delete(s.running, j.name)
+		s.mu.Unlock()
+	}()
+	defer func() {
+		if err := recover(); err != nil {
+			log.Error().
+				Any("err", err).
+				Str("job", j.name).
+				Msg("panic occurred")
+		}
+	}()
+
+	j.run()
+}
+
+func (s *Scheduler) notify() {
+	select {
+	case s.wakeup <- struct{}{}:
+	default:
+	}
+}
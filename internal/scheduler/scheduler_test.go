@@ -0,0 +1,65 @@
+package scheduler
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSchedulerRun(t *testing.T) {
+	s := NewScheduler()
+
+	// the slow job is due every few milliseconds, but never runs twice at once
+	started, release := make(chan struct{}, 100), make(chan struct{})
+	var slowRuns, slowRunning, overlaps int32
+	s.Add("slow", Every(5*time.Millisecond), func() {
+		if atomic.AddInt32(&slowRunning, 1) > 1 {
+			atomic.AddInt32(&overlaps, 1)
+		}
+		defer atomic.AddInt32(&slowRunning, -1)
+
+		atomic.AddInt32(&slowRuns, 1)
+		started <- struct{}{}
+		<-release
+	})
+
+	fast := make(chan struct{}, 100)
+	s.Add("fast", Every(5*time.Millisecond), func() {
+		fast <- struct{}{}
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		s.Run(ctx)
+	}()
+
+	select {
+	case <-started:
+	case <-time.After(time.Second):
+		t.Fatal("slow job didn't run")
+	}
+
+	// the fast job keeps going while the slow one is stuck
+	for i := 0; i < 3; i++ {
+		select {
+		case <-fast:
+		case <-time.After(time.Second):
+			t.Fatal("fast job is held back by the slow one")
+		}
+	}
+	require.EqualValues(t, 1, atomic.LoadInt32(&slowRuns))
+
+	cancel()
+	close(release)
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("scheduler didn't stop")
+	}
+	require.Zero(t, atomic.LoadInt32(&overlaps))
+}
@@ -0,0 +1,418 @@
+package service
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/buglloc/sowettybot/internal/config"
+	"github.com/buglloc/sowettybot/internal/i18n"
+	"github.com/buglloc/sowettybot/internal/renderer"
+	"github.com/buglloc/sowettybot/internal/storage"
+)
+
+const (
+	accessStateName      = "access"
+	accessCallbackPrefix = "access:"
+)
+
+type AccessRole string
+
+const (
+	AccessRoleUser  AccessRole = "user"
+	AccessRoleAdmin AccessRole = "admin"
+	// AccessRoleBlocked is set for denied requests, so newcomers can't spam admins
+	AccessRoleBlocked AccessRole = "blocked"
+)
+
+// AccessEntry grants a role to a user or a whole chat, chat ids are negative.
+type AccessEntry struct {
+	ID      int        `json:"id"`
+	Name    string     `json:"name"`
+	Role    AccessRole `json:"role"`
+	AddedBy int        `json:"added_by"`
+	AddedAt time.Time  `json:"added_at"`
+	// Config entries come from the config and can't be changed with commands
+	Config bool `json:"-"`
+}
+
+// AccessRequest is a newcomer waiting for approval.
+type AccessRequest struct {
+	ID          int       `json:"id"`
+	Name        string    `json:"name"`
+	ChatID      int       `json:"chat_id"`
+	Lang        i18n.Lang `json:"lang"`
+	RequestedAt time.Time `json:"requested_at"`
+	// Messages are admin chat ids to the request message ids, to update them once resolved
+	Messages map[int]int `json:"messages"`
+}
+
+type accessState struct {
+	Entries []AccessEntry   `json:"entries"`
+	Pending []AccessRequest `json:"pending"`
+}
+
+// Access keeps allowed users and chats, if disabled anyone is allowed.
+type Access struct {
+	bot     Messenger
+	storage *storage.Storage
+	prefs   *PreferenceStore
+	enabled bool
+	config  map[int]AccessEntry
+	mu      sync.Mutex
+	entries map[int]AccessEntry
+	pending map[int]AccessRequest
+}
+
+func NewAccess(cfg config.Access, bot Messenger, store *storage.Storage, prefs *PreferenceStore) *Access {
+	entries := make(map[int]AccessEntry, len(cfg.Admins)+len(cfg.Users)+len(cfg.Chats))
+	add := func(ids []int, role AccessRole) {
+		for _, id := range ids {
+			entries[id] = AccessEntry{ID: id, Role: role, Config: true}
+		}
+	}
+	add(cfg.Users, AccessRoleUser)
+	add(cfg.Chats, AccessRoleUser)
+	add(cfg.Admins, AccessRoleAdmin)
+
+	return &Access{
+		bot:     bot,
+		storage: store,
+		prefs:   prefs,
+		enabled: cfg.Enabled,
+		config:  entries,
+	}
+}
+
+func (a *Access) Initialize() error {
+	var state accessState
+	if err := a.storage.Load(accessStateName, &state); err != nil {
+		return fmt.Errorf("unable to load access: %w", err)
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.entries = make(map[int]AccessEntry, len(state.Entries))
+	for _, entry := range state.Entries {
+		a.entries[entry.ID] = entry
+	}
+
+	a.pending = make(map[int]AccessRequest, len(state.Pending))
+	for _, req := range state.Pending {
+		a.pending[req.ID] = req
+	}
+
+	return nil
+}
+
+func (a *Access) Enabled() bool {
+	return a.enabled
+}
+
+// Role returns the role of the user or chat, config entries win.
+func (a *Access) Role(id int) (AccessRole, bool) {
+	if entry, ok := a.config[id]; ok {
+		return entry.Role, true
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	entry, ok := a.entries[id]
+	return entry.Role, ok
+}
+
+// IsAdmin reports whether the user manages access, it doesn't depend on Enabled.
+func (a *Access) IsAdmin(userID int) bool {
+	role, ok := a.Role(userID)
+	return ok && role == AccessRoleAdmin
+}
+
+// Allowed reports whether the user may use the bot in the chat: neither of them may be blocked
+// and either of them must be allowed.
+func (a *Access) Allowed(chatID int, userID int) bool {
+	if !a.enabled {
+		return true
+	}
+
+	allowed := false
+	for _, id := range []int{userID, chatID} {
+		if id == 0 {
+			continue
+		}
+
+		role, ok := a.Role(id)
+		if !ok {
+			continue
+		}
+
+		if role == AccessRoleBlocked {
+			return false
+		}
+
+		allowed = true
+	}
+
+	return allowed
+}
+
+func (a *Access) Blocked(id int) bool {
+	role, ok := a.Role(id)
+	return ok && role == AccessRoleBlocked
+}
+
+func (a *Access) Pending(id int) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	_, ok := a.pending[id]
+	return ok
+}
+
+// Request asks admins to approve the user or chat, it returns false if there is a pending request already.
+func (a *Access) Request(req AccessRequest) (bool, error) {
+	a.mu.Lock()
+	if _, ok := a.pending[req.ID]; ok {
+		a.mu.Unlock()
+		return false, nil
+	}
+
+	req.RequestedAt = time.Now()
+	// reserve the slot, so concurrent commands don't spam admins
+	a.pending[req.ID] = req
+	admins := a.lockedAdmins()
+	a.mu.Unlock()
+
+	messages := make(map[int]int, len(admins))
+	text := describeAccessRequest(req)
+	for _, adminID := range admins {
+		loc := a.prefs.For(adminID, "")
+		msgID, err := a.bot.PostMdMessageWithButtons(adminID, loc.T("access.request", text), 0, [][]InlineButton{
+			{
+				{Text: loc.T("access.button.approve"), Data: accessCallbackData(true, req.ID)},
+				{Text: loc.T("access.button.deny"), Data: accessCallbackData(false, req.ID)},
+			},
+		})
+		if err != nil {
+			// the admin may have never started the bot
+			log.Error().Err(err).Int("chat_id", adminID).Msg("unable to send access request")
+			continue
+		}
+
+		messages[adminID] = msgID
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if len(messages) == 0 {
+		delete(a.pending, req.ID)
+		return false, fmt.Errorf("no admins to approve %d", req.ID)
+	}
+
+	if _, ok := a.pending[req.ID]; !ok {
+		// resolved with a command meanwhile
+		return true, nil
+	}
+
+	req.Messages = messages
+	a.pending[req.ID] = req
+	return true, a.lockedSave()
+}
+
+// Resolve approves or blocks the pending request and updates admin messages.
+func (a *Access) Resolve(id int, approve bool, by int, byName string) (AccessRequest, bool, error) {
+	a.mu.Lock()
+	req, ok := a.pending[id]
+	if !ok {
+		a.mu.Unlock()
+		return req, false, nil
+	}
+
+	delete(a.pending, id)
+	role := AccessRoleUser
+	resultKey := "access.request.approved"
+	if !approve {
+		role = AccessRoleBlocked
+		resultKey = "access.request.denied"
+	}
+
+	a.entries[id] = AccessEntry{
+		ID:      id,
+		Name:    req.Name,
+		Role:    role,
+		AddedBy: by,
+		AddedAt: time.Now(),
+	}
+	err := a.lockedSave()
+	a.mu.Unlock()
+
+	text := describeAccessRequest(req)
+	for chatID, msgID := range req.Messages {
+		loc := a.prefs.For(chatID, "")
+		err := a.bot.EditMdMessage(chatID, msgID, loc.T(resultKey, loc.T("access.request", text), renderer.EscapeTgMdCode(byName)))
+		if err != nil {
+			log.Error().Err(err).Int("chat_id", chatID).Msg("unable to update access request")
+		}
+	}
+
+	return req, true, err
+}
+
+// Set changes the role of the user or chat with a command.
+func (a *Access) Set(id int, role AccessRole, by int) error {
+	if _, ok := a.config[id]; ok {
+		return i18n.Errorf("access.err.config", id)
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	entry := a.entries[id]
+	entry.ID = id
+	entry.Role = role
+	entry.AddedBy = by
+	entry.AddedAt = time.Now()
+	if req, ok := a.pending[id]; ok {
+		entry.Name = req.Name
+		delete(a.pending, id)
+	}
+
+	a.entries[id] = entry
+	return a.lockedSave()
+}
+
+func (a *Access) Remove(id int) (bool, error) {
+	if _, ok := a.config[id]; ok {
+		return false, i18n.Errorf("access.err.config", id)
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	_, isEntry := a.entries[id]
+	_, isPending := a.pending[id]
+	if !isEntry && !isPending {
+		return false, nil
+	}
+
+	delete(a.entries, id)
+	delete(a.pending, id)
+	return true, a.lockedSave()
+}
+
+// Entries returns all the entries, config ones included, ordered by id.
+func (a *Access) Entries() []AccessEntry {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	out := make([]AccessEntry, 0, len(a.config)+len(a.entries))
+	for _, entry := range a.config {
+		out = append(out, entry)
+	}
+
+	for id, entry := range a.entries {
+		if _, ok := a.config[id]; !ok {
+			out = append(out, entry)
+		}
+	}
+
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].ID < out[j].ID
+	})
+	return out
+}
+
+func (a *Access) PendingRequests() []AccessRequest {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	out := make([]AccessRequest, 0, len(a.pending))
+	for _, req := range a.pending {
+		out = append(out, req)
+	}
+
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].RequestedAt.Before(out[j].RequestedAt)
+	})
+	return out
+}
+
+func (a *Access) lockedAdmins() []int {
+	var out []int
+	for id, entry := range a.config {
+		if entry.Role == AccessRoleAdmin {
+			out = append(out, id)
+		}
+	}
+
+	for id, entry := range a.entries {
+		if _, ok := a.config[id]; !ok && entry.Role == AccessRoleAdmin {
+			out = append(out, id)
+		}
+	}
+
+	sort.Ints(out)
+	return out
+}
+
+func (a *Access) lockedSave() error {
+	state := accessState{
+		Entries: make([]AccessEntry, 0, len(a.entries)),
+		Pending: make([]AccessRequest, 0, len(a.pending)),
+	}
+	for _, entry := range a.entries {
+		state.Entries = append(state.Entries, entry)
+	}
+
+	for _, req := range a.pending {
+		state.Pending = append(state.Pending, req)
+	}
+
+	sort.Slice(state.Entries, func(i, j int) bool {
+		return state.Entries[i].ID < state.Entries[j].ID
+	})
+	sort.Slice(state.Pending, func(i, j int) bool {
+		return state.Pending[i].ID < state.Pending[j].ID
+	})
+
+	if err := a.storage.Save(accessStateName, state); err != nil {
+		return fmt.Errorf("unable to save access: %w", err)
+	}
+
+	return nil
+}
+
+// describeAccessRequest escapes the name, a stray backtick would make Telegram reject the message.
+func describeAccessRequest(req AccessRequest) string {
+	return fmt.Sprintf("%s (`%d`)", renderer.EscapeTgMdCode(req.Name), req.ID)
+}
+
+func accessCallbackData(approve bool, id int) string {
+	action := "deny"
+	if approve {
+		action = "approve"
+	}
+
+	return fmt.Sprintf("%s%s:%d", accessCallbackPrefix, action, id)
+}
+
+func parseAccessCallbackData(data string) (bool, int, error) {
+	action, rawID, ok := strings.Cut(strings.TrimPrefix(data, accessCallbackPrefix), ":")
+	if !ok || (action != "approve" && action != "deny") {
+		return false, 0, fmt.Errorf("invalid access data %q", data)
+	}
+
+	id, err := strconv.Atoi(rawID)
+	if err != nil {
+		return false, 0, fmt.Errorf("invalid access data %q: %w", data, err)
+	}
+
+	return action == "approve", id, nil
+}
@@ -0,0 +1,69 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/buglloc/sowettybot/internal/config"
+	"github.com/buglloc/sowettybot/internal/storage"
+)
+
+func TestAccessAllowed(t *testing.T) {
+	store := storage.NewStorage("")
+	access := NewAccess(config.Access{
+		Enabled: true,
+		Admins:  []int{1},
+		Users:   []int{2},
+		Chats:   []int{-100},
+	}, nil, store, nil)
+	require.NoError(t, access.Initialize())
+	require.NoError(t, access.Set(3, AccessRoleBlocked, 1))
+	require.NoError(t, access.Set(-300, AccessRoleBlocked, 1))
+
+	cases := []struct {
+		name   string
+		chatID int
+		userID int
+		ok     bool
+	}{
+		{name: "admin", chatID: 1, userID: 1, ok: true},
+		{name: "user", chatID: 2, userID: 2, ok: true},
+		{name: "allowed_chat", chatID: -100, userID: 4, ok: true},
+		{name: "user_in_other_chat", chatID: -200, userID: 2, ok: true},
+		{name: "newcomer", chatID: 4, userID: 4, ok: false},
+		{name: "blocked", chatID: 3, userID: 3, ok: false},
+		{name: "blocked_in_allowed_chat", chatID: -100, userID: 3, ok: false},
+		{name: "user_in_blocked_chat", chatID: -300, userID: 2, ok: false},
+		{name: "channel_post", chatID: -200, userID: 0, ok: false},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.ok, access.Allowed(tc.chatID, tc.userID))
+		})
+	}
+
+	require.True(t, access.IsAdmin(1))
+	require.False(t, access.IsAdmin(2))
+	require.Error(t, access.Set(2, AccessRoleBlocked, 1))
+
+	disabled := NewAccess(config.Access{}, nil, store, nil)
+	require.True(t, disabled.Allowed(4, 4))
+}
+
+func TestAccessCallbackData(t *testing.T) {
+	for _, approve := range []bool{true, false} {
+		gotApprove, gotID, err := parseAccessCallbackData(accessCallbackData(approve, -100))
+		require.NoError(t, err)
+		require.Equal(t, approve, gotApprove)
+		require.Equal(t, -100, gotID)
+	}
+
+	_, _, err := parseAccessCallbackData("access:maybe:1")
+	require.Error(t, err)
+}
+
+func TestDescribeAccessRequest(t *testing.T) {
+	require.Equal(t, "Tester (`2`)", describeAccessRequest(AccessRequest{ID: 2, Name: "Tester"}))
+	require.Equal(t, "\\`rm\\\\ (`3`)", describeAccessRequest(AccessRequest{ID: 3, Name: "`rm\\"}))
+}
@@ -0,0 +1,324 @@
+package service
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/buglloc/sowettybot/internal/config"
+	"github.com/buglloc/sowettybot/internal/i18n"
+)
+
+const dateLayout = "2006-01-02"
+
+// TimeRange is either a period back from now or fixed dates.
+type TimeRange struct {
+	Period time.Duration
+	From   time.Time
+	To     time.Time
+}
+
+func (r TimeRange) Bounds(now time.Time) (time.Time, time.Time) {
+	if r.Period != 0 {
+		return now.Add(-r.Period), now
+	}
+
+	return r.From, r.To
+}
+
+// cmdArgs holds command arguments, typed getters consume the matching ones in any order.
+// The first parse error sticks: getters become no-op and Done reports it, as well as
+// anything left unrecognized.
+type cmdArgs struct {
+	args []string
+	err  error
+}
+
+func newCmdArgs(text string) *cmdArgs {
+	fields := strings.Fields(text)
+	if len(fields) > 0 {
+		// skip the command itself
+		fields = fields[1:]
+	}
+
+	return &cmdArgs{
+		args: fields,
+	}
+}
+
+func (a *cmdArgs) Len() int {
+	return len(a.args)
+}
+
+// Peek returns the first argument lowercased, without consuming it.
+func (a *cmdArgs) Peek() string {
+	if a.err != nil || len(a.args) == 0 {
+		return ""
+	}
+
+	return strings.ToLower(a.args[0])
+}
+
+// Shift consumes the first argument, lowercased.
+func (a *cmdArgs) Shift() string {
+	if a.err != nil || len(a.args) == 0 {
+		return ""
+	}
+
+	return strings.ToLower(a.ShiftRaw())
+}
+
+// ShiftRaw consumes the first argument as is.
+func (a *cmdArgs) ShiftRaw() string {
+	if a.err != nil || len(a.args) == 0 {
+		return ""
+	}
+
+	return a.take(0)
+}
+
+// Rest consumes all the remaining arguments as is.
+func (a *cmdArgs) Rest() []string {
+	out := a.args
+	a.args = nil
+	return out
+}
+
+// Keyword consumes the first argument equal to one of words, case-insensitive.
+func (a *cmdArgs) Keyword(words ...string) (string, bool) {
+	if a.err != nil {
+		return "", false
+	}
+
+	for i, arg := range a.args {
+		for _, word := range words {
+			if strings.EqualFold(arg, word) {
+				a.take(i)
+				return word, true
+			}
+		}
+	}
+
+	return "", false
+}
+
+// Count consumes the first bare number, it must be in [1, max].
+func (a *cmdArgs) Count(max int) (int, bool) {
+	if a.err != nil {
+		return 0, false
+	}
+
+	for i, arg := range a.args {
+		if !isDigits(arg) {
+			continue
+		}
+
+		a.take(i)
+		n, err := strconv.Atoi(arg)
+		if err != nil || n < 1 || n > max {
+			a.err = i18n.Errorf("args.err.count", arg, max)
+			return 0, false
+		}
+
+		return n, true
+	}
+
+	return 0, false
+}
+
+// ID consumes the first user or chat id, chat ids are negative.
+func (a *cmdArgs) ID() (int, bool) {
+	if a.err != nil || len(a.args) == 0 {
+		return 0, false
+	}
+
+	for i, arg := range a.args {
+		if id, err := strconv.Atoi(arg); err == nil && id != 0 {
+			a.take(i)
+			return id, true
+		}
+	}
+
+	a.err = i18n.Errorf("args.err.id", a.args[0])
+	return 0, false
+}
+
+// Amount consumes all the remaining arguments as a single amount, e.g. "50 000".
+func (a *cmdArgs) Amount() (float64, bool) {
+	if a.err != nil || len(a.args) == 0 {
+		return 0, false
+	}
+
+	in := strings.Join(a.Rest(), " ")
+	amount, err := parseAmount(in)
+	if err != nil {
+		a.err = i18n.Errorf("args.err.amount", in)
+		return 0, false
+	}
+
+	return amount, true
+}
+
+// TimeRange consumes a period ("12h", "3d", "2w") or a date with an optional end date
+// ("2023-06-01 2023-06-15"), the end date is inclusive.
+func (a *cmdArgs) TimeRange(tz *time.Location) (TimeRange, bool) {
+	if a.err != nil {
+		return TimeRange{}, false
+	}
+
+	for i, arg := range a.args {
+		if !startsWithDigit(arg) {
+			continue
+		}
+
+		a.take(i)
+		if !strings.Contains(arg, "-") {
+			period, err := parsePeriod(arg)
+			if err != nil {
+				a.err = i18n.Errorf("args.err.period", arg)
+				return TimeRange{}, false
+			}
+
+			return TimeRange{Period: period}, true
+		}
+
+		from, err := time.ParseInLocation(dateLayout, arg, tz)
+		if err != nil {
+			a.err = i18n.Errorf("args.err.date", arg)
+			return TimeRange{}, false
+		}
+
+		to := from
+		if i < len(a.args) && startsWithDigit(a.args[i]) && strings.Contains(a.args[i], "-") {
+			endArg := a.take(i)
+			to, err = time.ParseInLocation(dateLayout, endArg, tz)
+			if err != nil {
+				a.err = i18n.Errorf("args.err.date", endArg)
+				return TimeRange{}, false
+			}
+		}
+
+		if to.Before(from) {
+			a.err = i18n.Errorf("args.err.date_order", from.Format(dateLayout), to.Format(dateLayout))
+			return TimeRange{}, false
+		}
+
+		return TimeRange{From: from, To: to.AddDate(0, 0, 1)}, true
+	}
+
+	return TimeRange{}, false
+}
+
+// Exchange consumes an exchange given by its slug or name. Anything else left is treated as
+// a misspelled exchange, so call it after the other getters.
+func (a *cmdArgs) Exchange(exchanges []config.Exchange) (config.Exchange, bool) {
+	if a.err != nil || len(a.args) == 0 {
+		return config.Exchange{}, false
+	}
+
+	for i, arg := range a.args {
+		if ex, ok := findExchange(exchanges, arg); ok {
+			a.take(i)
+			return ex, true
+		}
+	}
+
+	a.err = i18n.Errorf("args.err.exchange", a.args[0], exchangeSlugs(exchanges))
+	return config.Exchange{}, false
+}
+
+// Fail records a command specific error, unless there is one already.
+func (a *cmdArgs) Fail(err error) {
+	if a.err == nil {
+		a.err = err
+	}
+}
+
+// Done returns the first parse error or fails if any argument was not consumed.
+func (a *cmdArgs) Done() error {
+	if a.err != nil {
+		return a.err
+	}
+
+	if len(a.args) > 0 {
+		return i18n.Errorf("args.err.unexpected", strings.Join(a.args, " "))
+	}
+
+	return nil
+}
+
+func (a *cmdArgs) take(i int) string {
+	arg := a.args[i]
+	a.args = append(a.args[:i:i], a.args[i+1:]...)
+	return arg
+}
+
+// parsePeriod accepts "12h", "3d" and "2w".
+func parsePeriod(in string) (time.Duration, error) {
+	if len(in) < 2 {
+		return 0, fmt.Errorf("invalid period: %q", in)
+	}
+
+	var unit time.Duration
+	switch strings.ToLower(in[len(in)-1:]) {
+	case "h":
+		unit = time.Hour
+	case "d":
+		unit = 24 * time.Hour
+	case "w":
+		unit = 7 * 24 * time.Hour
+	default:
+		return 0, fmt.Errorf("invalid period unit: %q", in)
+	}
+
+	n, err := strconv.Atoi(in[:len(in)-1])
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("invalid period: %q", in)
+	}
+
+	return time.Duration(n) * unit, nil
+}
+
+// formatPeriod is the reverse of parsePeriod, whole days are preferred.
+func formatPeriod(period time.Duration) string {
+	if period%(24*time.Hour) == 0 {
+		return fmt.Sprintf("%dd", period/(24*time.Hour))
+	}
+
+	return fmt.Sprintf("%dh", period/time.Hour)
+}
+
+// findExchange looks up an exchange by its slug or name.
+func findExchange(exchanges []config.Exchange, in string) (config.Exchange, bool) {
+	for _, ex := range exchanges {
+		if strings.EqualFold(ex.Slug, in) || strings.EqualFold(ex.Name, in) {
+			return ex, true
+		}
+	}
+
+	return config.Exchange{}, false
+}
+
+func exchangeSlugs(exchanges []config.Exchange) string {
+	slugs := make([]string, len(exchanges))
+	for i, ex := range exchanges {
+		slugs[i] = ex.Slug
+	}
+
+	return strings.Join(slugs, ", ")
+}
+
+func isDigits(in string) bool {
+	for _, c := range in {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+
+	return in != ""
+}
+
+func startsWithDigit(in string) bool {
+	return in != "" && in[0] >= '0' && in[0] <= '9'
+}
@@ -0,0 +1,94 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/buglloc/sowettybot/internal/config"
+	"github.com/buglloc/sowettybot/internal/i18n"
+)
+
+func TestParsePeriod(t *testing.T) {
+	cases := []struct {
+		in     string
+		period time.Duration
+		err    bool
+	}{
+		{in: "12h", period: 12 * time.Hour},
+		{in: "3d", period: 3 * 24 * time.Hour},
+		{in: "3D", period: 3 * 24 * time.Hour},
+		{in: "2w", period: 14 * 24 * time.Hour},
+		{in: "7", err: true},
+		{in: "0d", err: true},
+		{in: "d", err: true},
+		{in: "1y", err: true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.in, func(t *testing.T) {
+			period, err := parsePeriod(tc.in)
+			if tc.err {
+				require.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, tc.period, period)
+		})
+	}
+}
+
+func TestCmdArgs(t *testing.T) {
+	exchanges := []config.Exchange{
+		{Slug: "korona", Name: "Korona"},
+		{Slug: "contact", Name: "Contact"},
+	}
+	day := func(d int) time.Time {
+		return time.Date(2023, time.June, d, 0, 0, 0, 0, time.UTC)
+	}
+
+	cases := []struct {
+		in       string
+		count    int
+		r        TimeRange
+		exchange string
+		err      string
+	}{
+		{in: "/history"},
+		{in: "/history 3d korona", r: TimeRange{Period: 3 * 24 * time.Hour}, exchange: "korona"},
+		{in: "/history Contact 12h", r: TimeRange{Period: 12 * time.Hour}, exchange: "contact"},
+		{in: "/history 2023-06-01 2023-06-15", r: TimeRange{From: day(1), To: day(16)}},
+		{in: "/history 2023-06-01", r: TimeRange{From: day(1), To: day(2)}},
+		{in: "/rawhistory 48", count: 48},
+		{in: "/rawhistory 48 1w", count: 48, r: TimeRange{Period: 7 * 24 * time.Hour}},
+		{in: "/rawhistory 1000", err: "args.err.count"},
+		{in: "/history 3x", err: "args.err.period"},
+		{in: "/history 2023-06-15 2023-06-01", err: "args.err.date_order"},
+		{in: "/history 2023-13-01", err: "args.err.date"},
+		{in: "/history 3d unknown", err: "args.err.exchange"},
+		{in: "/history korona contact", err: "args.err.unexpected"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.in, func(t *testing.T) {
+			args := newCmdArgs(tc.in)
+			count, _ := args.Count(maxRawHistory)
+			r, _ := args.TimeRange(time.UTC)
+			ex, _ := args.Exchange(exchanges)
+			err := args.Done()
+			if tc.err != "" {
+				var locErr *i18n.Error
+				require.ErrorAs(t, err, &locErr)
+				require.Equal(t, tc.err, locErr.Key)
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, tc.count, count)
+			require.Equal(t, tc.r, r)
+			require.Equal(t, tc.exchange, ex.Slug)
+		})
+	}
+}
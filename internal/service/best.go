@@ -0,0 +1,101 @@
+package service
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/buglloc/sowettybot/internal/config"
+	"github.com/buglloc/sowettybot/internal/models"
+)
+
+const (
+	quoteSourceCache   = "cache"
+	quoteSourceHistory = "history"
+)
+
+type quote struct {
+	Name   string
+	Rate   float64
+	When   time.Time
+	Source string
+}
+
+// freshQuotes takes rates from the cache when they are still alive and falls back to the last history entry.
+func (h *CommandsHandler) freshQuotes(exchanges []config.Exchange) ([]quote, error) {
+	var last *models.History
+	quotes := make([]quote, 0, len(exchanges))
+	for _, ex := range exchanges {
+		cached := h.ratesCache.Get(ex.Route)
+		if cached != nil && !cached.IsExpired() && cached.Value().Rate != 0 {
+			quotes = append(quotes, quote{
+				Name:   ex.Name,
+				Rate:   cached.Value().Rate,
+				When:   cached.Value().When,
+				Source: quoteSourceCache,
+			})
+			continue
+		}
+
+		if last == nil {
+			entries, err := h.history.Entries(1)
+			if err != nil {
+				return nil, fmt.Errorf("get entries: %w", err)
+			}
+
+			if len(entries) == 0 {
+				continue
+			}
+			last = &entries[0]
+		}
+
+		for i, name := range last.Names {
+			if name != ex.Slug || last.Values[i] == 0 {
+				continue
+			}
+
+			quotes = append(quotes, quote{
+				Name:   ex.Name,
+				Rate:   last.Values[i],
+				When:   last.When,
+				Source: quoteSourceHistory,
+			})
+		}
+	}
+
+	return quotes, nil
+}
+
+// rankQuotes orders quotes from the cheapest one, amount is what the user wants to buy.
+func rankQuotes(quotes []quote, amount float64, maxAge time.Duration, now time.Time) models.Best {
+	sort.SliceStable(quotes, func(i, j int) bool {
+		return quotes[i].Rate < quotes[j].Rate
+	})
+
+	out := models.Best{
+		Amount:    amount,
+		Exchanges: make([]models.BestExchange, len(quotes)),
+	}
+	if len(quotes) == 0 {
+		return out
+	}
+
+	best, worst := quotes[0].Rate, quotes[len(quotes)-1].Rate
+	for i, q := range quotes {
+		out.Exchanges[i] = models.BestExchange{
+			Position: i + 1,
+			Name:     q.Name,
+			Rate:     q.Rate,
+			When:     q.When,
+			Source:   q.Source,
+			Stale:    maxAge > 0 && now.Sub(q.When) > maxAge,
+			Cost:     amount * q.Rate,
+			Overpay:  amount * (q.Rate - best),
+		}
+	}
+
+	out.Spread = worst - best
+	out.SpreadPct = out.Spread / best * 100
+	out.Savings = amount * out.Spread
+	return out
+}
@@ -0,0 +1,36 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRankQuotes(t *testing.T) {
+	now := time.Now()
+	quotes := []quote{
+		{Name: "contact", Rate: 2.95, When: now.Add(-2 * time.Hour), Source: quoteSourceHistory},
+		{Name: "korona", Rate: 2.90, When: now, Source: quoteSourceCache},
+		{Name: "unistream", Rate: 3.00, When: now, Source: quoteSourceCache},
+	}
+
+	best := rankQuotes(quotes, 1000, time.Hour, now)
+	require.Len(t, best.Exchanges, 3)
+
+	require.Equal(t, "korona", best.Exchanges[0].Name)
+	require.Equal(t, 1, best.Exchanges[0].Position)
+	require.InDelta(t, 0, best.Exchanges[0].Overpay, 1e-9)
+
+	require.Equal(t, "contact", best.Exchanges[1].Name)
+	require.True(t, best.Exchanges[1].Stale)
+	require.InDelta(t, 50, best.Exchanges[1].Overpay, 1e-9)
+
+	require.Equal(t, "unistream", best.Exchanges[2].Name)
+	require.False(t, best.Exchanges[2].Stale)
+	require.InDelta(t, 3000, best.Exchanges[2].Cost, 1e-9)
+
+	require.InDelta(t, 0.1, best.Spread, 1e-9)
+	require.InDelta(t, 0.1/2.9*100, best.SpreadPct, 1e-9)
+	require.InDelta(t, 100, best.Savings, 1e-9)
+}
@@ -0,0 +1,487 @@
+package service
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/buglloc/sowettybot/internal/config"
+	"github.com/buglloc/sowettybot/internal/i18n"
+	"github.com/buglloc/sowettybot/internal/models"
+)
+
+const (
+	chartCallbackPrefix = "chart:"
+	// maxCachedCharts bounds the cache, custom date ranges make the number of views unlimited
+	maxCachedCharts = 512
+)
+
+type ChartRange string
+
+const (
+	ChartRangeShort ChartRange = "short"
+	ChartRangeLong  ChartRange = "long"
+	ChartRangeDay   ChartRange = "1d"
+	ChartRangeWeek  ChartRange = "7d"
+	ChartRangeMonth ChartRange = "30d"
+	ChartRangeAll   ChartRange = "all"
+)
+
+var chartRanges = []ChartRange{ChartRangeDay, ChartRangeWeek, ChartRangeMonth, ChartRangeAll}
+
+// ChartView describes what a chart message shows, it survives round trips through the callback data.
+type ChartView struct {
+	Range ChartRange
+	// Hidden is a bitmask of hidden exchanges, in config order
+	Hidden uint64
+}
+
+func ParseChartView(data string) (ChartView, error) {
+	parts := strings.Split(strings.TrimPrefix(data, chartCallbackPrefix), ":")
+	if len(parts) != 2 {
+		return ChartView{}, fmt.Errorf("invalid chart data %q", data)
+	}
+
+	hidden, err := strconv.ParseUint(parts[1], 16, 64)
+	if err != nil {
+		return ChartView{}, fmt.Errorf("invalid chart data %q: %w", data, err)
+	}
+
+	view := ChartView{
+		Range:  ChartRange(parts[0]),
+		Hidden: hidden,
+	}
+	switch view.Range {
+	case ChartRangeShort, ChartRangeLong, ChartRangeAll:
+	default:
+		if _, ok := view.Range.TimeRange(); !ok {
+			return ChartView{}, fmt.Errorf("invalid chart range %q", parts[0])
+		}
+	}
+
+	return view, nil
+}
+
+// NewChartRange encodes a time range, periods stay relative to the moment the chart is rendered.
+func NewChartRange(r TimeRange) ChartRange {
+	if r.Period != 0 {
+		return ChartRange(formatPeriod(r.Period))
+	}
+
+	return ChartRange(fmt.Sprintf("%x-%x", r.From.Unix(), r.To.Unix()))
+}
+
+// TimeRange decodes the range, it's not ok for ranges counted in entries.
+func (r ChartRange) TimeRange() (TimeRange, bool) {
+	if from, to, ok := strings.Cut(string(r), "-"); ok {
+		fromUnix, err := strconv.ParseInt(from, 16, 64)
+		if err != nil {
+			return TimeRange{}, false
+		}
+
+		toUnix, err := strconv.ParseInt(to, 16, 64)
+		if err != nil {
+			return TimeRange{}, false
+		}
+
+		return TimeRange{From: time.Unix(fromUnix, 0), To: time.Unix(toUnix, 0)}, true
+	}
+
+	period, err := parsePeriod(string(r))
+	if err != nil {
+		return TimeRange{}, false
+	}
+
+	return TimeRange{Period: period}, true
+}
+
+// Describe makes the range human readable, fixed dates are encoded otherwise.
+func (r ChartRange) Describe(loc *i18n.Localizer, tz *time.Location) string {
+	tr, ok := r.TimeRange()
+	if !ok || tr.Period != 0 {
+		return string(r)
+	}
+
+	return fmt.Sprintf("%s - %s", loc.Time(tr.From.In(tz), "layout.datetime"), loc.Time(tr.To.In(tz), "layout.datetime"))
+}
+
+func (v ChartView) String() string {
+	return fmt.Sprintf("%s%s:%x", chartCallbackPrefix, v.Range, v.Hidden)
+}
+
+func (v ChartView) IsHidden(idx int) bool {
+	return v.Hidden&(1<<uint(idx)) != 0
+}
+
+func (v ChartView) Toggle(idx int) ChartView {
+	v.Hidden ^= 1 << uint(idx)
+	return v
+}
+
+func (v ChartView) WithRange(r ChartRange) ChartView {
+	v.Range = r
+	return v
+}
+
+// Only shows the single exchange, the rest are hidden.
+func (v ChartView) Only(exchanges []config.Exchange, slug string) ChartView {
+	v.Hidden = 0
+	for i, ex := range exchanges {
+		if ex.Slug != slug {
+			v.Hidden |= 1 << uint(i)
+		}
+	}
+
+	return v
+}
+
+func (v ChartView) Buttons(exchanges []config.Exchange) [][]InlineButton {
+	rangeRow := make([]InlineButton, len(chartRanges))
+	for i, r := range chartRanges {
+		text := string(r)
+		if r == v.Range {
+			text = "• " + text
+		}
+
+		rangeRow[i] = InlineButton{
+			Text: text,
+			Data: v.WithRange(r).String(),
+		}
+	}
+
+	exRow := make([]InlineButton, len(exchanges))
+	for i, ex := range exchanges {
+		mark := "✓"
+		if v.IsHidden(i) {
+			mark = "✗"
+		}
+
+		exRow[i] = InlineButton{
+			Text: fmt.Sprintf("%s %s", mark, ex.Name),
+			Data: v.Toggle(i).String(),
+		}
+	}
+
+	return [][]InlineButton{rangeRow, exRow}
+}
+
+// ChartImage is a chart to send: a rendered temporary file or a file_id of the same chart sent before.
+// Close removes the file.
+type ChartImage struct {
+	Path    string
+	FileID  string
+	Caption string
+	Until   time.Time
+	key     string
+	version string
+}
+
+func (c *ChartImage) Photo() Photo {
+	return Photo{Path: c.Path, FileID: c.FileID}
+}
+
+func (c *ChartImage) Close() error {
+	if c.Path == "" {
+		return nil
+	}
+
+	return os.RemoveAll(c.Path)
+}
+
+// ChartCache remembers file_ids of sent charts, so a chart is rendered again only when its entries change.
+type ChartCache struct {
+	mediaChatID int
+	mu          sync.Mutex
+	charts      map[string]cachedChart
+}
+
+type cachedChart struct {
+	version string
+	fileID  string
+	caption string
+	until   time.Time
+	checked time.Time
+}
+
+func NewChartCache(mediaChatID int) *ChartCache {
+	return &ChartCache{
+		mediaChatID: mediaChatID,
+		charts:      make(map[string]cachedChart),
+	}
+}
+
+// Enabled reports whether charts can be uploaded for inline results.
+func (c *ChartCache) Enabled() bool {
+	return c.mediaChatID != 0
+}
+
+func (c *ChartCache) Get(key string, version string) (cachedChart, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	chart, ok := c.charts[key]
+	if !ok || chart.version != version {
+		return cachedChart{}, false
+	}
+
+	chart.checked = time.Now()
+	c.charts[key] = chart
+	return chart, true
+}
+
+// Recent returns the chart without checking its entries, if they were checked within maxAge.
+func (c *ChartCache) Recent(key string, maxAge time.Duration) (cachedChart, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	chart, ok := c.charts[key]
+	return chart, ok && time.Since(chart.checked) <= maxAge
+}
+
+func (c *ChartCache) Set(key string, chart cachedChart) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.charts[key]; !ok && len(c.charts) >= maxCachedCharts {
+		for k := range c.charts {
+			delete(c.charts, k)
+			break
+		}
+	}
+
+	chart.checked = time.Now()
+	c.charts[key] = chart
+}
+
+func (c *ChartCache) Forget(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.charts, key)
+}
+
+// chartVersion changes whenever the chart would: entries are only appended, so bounds and count are enough.
+func chartVersion(entries []models.History) string {
+	return fmt.Sprintf(
+		"%d:%d:%d",
+		len(entries),
+		entries[0].When.UnixNano(),
+		entries[len(entries)-1].When.UnixNano(),
+	)
+}
+
+func (h *CommandsHandler) chartEntries(view ChartView) ([]models.History, error) {
+	switch view.Range {
+	case ChartRangeShort:
+		return h.history.Entries(h.limits.History.Short)
+	case ChartRangeLong:
+		return h.history.Entries(h.limits.History.Long)
+	}
+
+	r, ok := view.Range.TimeRange()
+	if !ok {
+		return h.history.Entries(0)
+	}
+
+	from, to := r.Bounds(time.Now())
+	return h.history.Between(from, to)
+}
+
+// parseChartArgs applies "[all|period|dates] [exchange]" arguments to the view.
+func (h *CommandsHandler) parseChartArgs(args *cmdArgs, view ChartView, tz *time.Location) (ChartView, error) {
+	if _, ok := args.Keyword(string(ChartRangeAll)); ok {
+		view.Range = ChartRangeAll
+	} else if r, ok := args.TimeRange(tz); ok {
+		view.Range = NewChartRange(r)
+	}
+
+	if ex, ok := args.Exchange(h.exchanges); ok {
+		view = view.Only(h.exchanges, ex.Slug)
+	}
+
+	return view, args.Done()
+}
+
+func chartKey(loc *i18n.Localizer, tz *time.Location, prefs Preferences, view ChartView) string {
+	return fmt.Sprintf("%s@%s@%s@%s", view, loc.Lang(), tz, prefs.graphKey())
+}
+
+// chart returns the chart of the view, nil if there is nothing to show.
+// Charts sent before with the same entries come as file_ids, others are rendered into temporary files.
+func (h *CommandsHandler) chart(loc *i18n.Localizer, tz *time.Location, prefs Preferences, view ChartView) (*ChartImage, error) {
+	entries, err := h.chartEntries(view)
+	if err != nil {
+		return nil, fmt.Errorf("get entries: %w", err)
+	}
+
+	entries = filterEntries(entries, h.hiddenSeries(view))
+	if len(entries) == 0 || len(entries[0].Names) == 0 {
+		return nil, nil
+	}
+
+	img := &ChartImage{
+		key:     chartKey(loc, tz, prefs, view),
+		version: chartVersion(entries),
+	}
+	if cached, ok := h.charts.Get(img.key, img.version); ok {
+		img.FileID = cached.fileID
+		img.Caption = cached.caption
+		img.Until = cached.until
+		return img, nil
+	}
+
+	if err := h.renderChart(loc, tz, prefs, entries, img); err != nil {
+		return nil, err
+	}
+
+	return img, nil
+}
+
+// sendChart passes the chart to send and caches the returned file_id, it reports whether there was a chart to send.
+// A cached file_id rejected by Telegram is forgotten and the chart is rendered once again.
+func (h *CommandsHandler) sendChart(loc *i18n.Localizer, tz *time.Location, prefs Preferences, view ChartView, send func(img *ChartImage) (string, error)) (bool, error) {
+	for {
+		img, err := h.chart(loc, tz, prefs, view)
+		if err != nil {
+			return false, err
+		}
+
+		if img == nil {
+			return false, nil
+		}
+
+		fileID, err := send(img)
+		_ = img.Close()
+		if err != nil {
+			if img.FileID == "" {
+				return true, err
+			}
+
+			log.Warn().Err(err).Str("chart", img.key).Msg("cached chart rejected, rendering it again")
+			h.charts.Forget(img.key)
+			continue
+		}
+
+		if fileID != "" {
+			h.charts.Set(img.key, cachedChart{
+				version: img.version,
+				fileID:  fileID,
+				caption: img.Caption,
+				until:   img.Until,
+			})
+		}
+
+		return true, nil
+	}
+}
+
+func (h *CommandsHandler) renderChart(loc *i18n.Localizer, tz *time.Location, prefs Preferences, entries []models.History, img *ChartImage) error {
+	release, err := h.acquireRender()
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	graphF, err := os.CreateTemp("", "sowetty-history-*.png")
+	if err != nil {
+		return fmt.Errorf("create temporary file: %w", err)
+	}
+	defer func() { _ = graphF.Close() }()
+
+	startDate, endDate, err := h.renderer.Graph(entries, graphF, prefs.GraphConfig(len(entries)).Location(tz))
+	if err != nil {
+		_ = os.RemoveAll(graphF.Name())
+		return fmt.Errorf("render graph: %w", err)
+	}
+
+	img.Path = graphF.Name()
+	img.Until = endDate
+	img.Caption = fmt.Sprintf(
+		"`%s -> %s`",
+		loc.Time(startDate.In(tz), "layout.datetime"),
+		loc.Time(endDate.In(tz), "layout.datetime"),
+	)
+	return nil
+}
+
+// newRenderSlots limits concurrent chart renders, nil means unlimited.
+func newRenderSlots(n int) chan struct{} {
+	if n <= 0 {
+		return nil
+	}
+
+	return make(chan struct{}, n)
+}
+
+// errRenderBusy is returned when every render slot is taken, it's answered as is rather than as a failure.
+var errRenderBusy = i18n.Errorf("chart.err.busy")
+
+// acquireRender takes a render slot without waiting for one, the returned func frees it.
+func (h *CommandsHandler) acquireRender() (func(), error) {
+	if h.renders == nil {
+		return func() {}, nil
+	}
+
+	select {
+	case h.renders <- struct{}{}:
+		return func() { <-h.renders }, nil
+	default:
+		return nil, errRenderBusy
+	}
+}
+
+func (h *CommandsHandler) hiddenSeries(view ChartView) map[string]struct{} {
+	out := make(map[string]struct{}, len(h.exchanges))
+	for i, ex := range h.exchanges {
+		if view.IsHidden(i) {
+			out[ex.Slug] = struct{}{}
+		}
+	}
+
+	return out
+}
+
+func filterEntries(entries []models.History, hidden map[string]struct{}) []models.History {
+	out := make([]models.History, len(entries))
+	for i, entry := range entries {
+		out[i] = models.History{
+			When: entry.When,
+		}
+
+		for j, name := range entry.Names {
+			if _, ok := hidden[name]; ok {
+				continue
+			}
+
+			out[i].Names = append(out[i].Names, name)
+			out[i].Values = append(out[i].Values, entry.Values[j])
+		}
+	}
+
+	return out
+}
+
+// sampleEntries thins entries out evenly down to n, the last one is always kept.
+func sampleEntries(entries []models.History, n int) []models.History {
+	if len(entries) <= n {
+		return entries
+	}
+
+	if n == 1 {
+		return entries[len(entries)-1:]
+	}
+
+	out := make([]models.History, n)
+	step := float64(len(entries)-1) / float64(n-1)
+	for i := range out {
+		out[i] = entries[int(math.Round(float64(i)*step))]
+	}
+
+	return out
+}
@@ -0,0 +1,307 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/SakoDroid/telego/objects"
+
+	"github.com/buglloc/sowettybot/internal/i18n"
+)
+
+type CommandVisibility string
+
+const (
+	// CommandUser is listed for everyone
+	CommandUser CommandVisibility = "user"
+	// CommandAdmin is listed for private chats and group admins only
+	CommandAdmin CommandVisibility = "admin"
+	// CommandBotAdmin is available to bot admins only and listed in their /help
+	CommandBotAdmin CommandVisibility = "bot_admin"
+	// CommandHidden is routed, but never listed
+	CommandHidden CommandVisibility = "hidden"
+)
+
+// Command describes a bot command, the registry drives routing, /help and the Telegram command menu.
+type Command struct {
+	Name string
+	// Description is the i18n key of a one line description
+	Description string
+	// Usage is the i18n key of the arguments synopsis, one line per form, empty if there are no arguments
+	Usage      string
+	Examples   []string
+	Visibility CommandVisibility
+	// Public commands don't need access approval
+	Public  bool
+	Handler func(u *objects.Update)
+}
+
+func (h *CommandsHandler) registry() []Command {
+	return []Command{
+		{
+			Name:        "start",
+			Description: "cmd.start",
+			Visibility:  CommandHidden,
+			Handler:     h.handleStart,
+		},
+		{
+			Name:        "help",
+			Description: "cmd.help",
+			Usage:       "help.usage",
+			Examples:    []string{"/help history"},
+			Visibility:  CommandUser,
+			Public:      true,
+			Handler:     h.handleHelp,
+		},
+		{
+			Name:        "rates",
+			Description: "cmd.rates",
+			Visibility:  CommandUser,
+			Handler:     h.handleRates,
+		},
+		{
+			Name:        "best",
+			Description: "cmd.best",
+			Usage:       "best.usage",
+			Examples:    []string{"/best", "/best 50 000"},
+			Visibility:  CommandUser,
+			Handler:     h.handleBest,
+		},
+		{
+			Name:        "history",
+			Description: "cmd.history",
+			Usage:       "history.usage",
+			Examples:    []string{"/history 3d korona", "/history 2023-06-01 2023-06-15"},
+			Visibility:  CommandUser,
+			Handler:     h.handleHistoryChart,
+		},
+		{
+			Name:        "longhistory",
+			Description: "cmd.longhistory",
+			Usage:       "longhistory.usage",
+			Examples:    []string{"/longhistory all contact"},
+			Visibility:  CommandUser,
+			Handler:     h.handleLongHistoryChart,
+		},
+		{
+			Name:        "rawhistory",
+			Description: "cmd.rawhistory",
+			Usage:       "rawhistory.usage",
+			Examples:    []string{"/rawhistory 48", "/rawhistory 3d contact"},
+			Visibility:  CommandUser,
+			Handler:     h.handleHistoryText,
+		},
+		{
+			Name:        "stats",
+			Description: "cmd.stats",
+			Usage:       "stats.usage",
+			Examples:    []string{"/stats 7d korona", "/stats 2023-06-01 2023-06-15"},
+			Visibility:  CommandUser,
+			Handler:     h.handleStats,
+		},
+		{
+			Name:        "export",
+			Description: "cmd.export",
+			Usage:       "export.usage",
+			Examples:    []string{"/export 30d", "/export 2023-06-01 2023-06-15 json"},
+			Visibility:  CommandUser,
+			Handler:     h.handleExport,
+		},
+		{
+			Name:        "digest",
+			Description: "cmd.digest",
+			Usage:       "digest.usage",
+			Examples:    []string{"/digest daily 09:00 Europe/Moscow", "/digest weekly mon 10:00"},
+			Visibility:  CommandUser,
+			Handler:     h.handleDigest,
+		},
+		{
+			Name:        "rule",
+			Description: "cmd.rule",
+			Usage:       "rule.usage",
+			Examples:    []string{"/rule add korona < contact - 0.02 and hour() between 9 and 18", "/rule del 1"},
+			Visibility:  CommandUser,
+			Handler:     h.handleRule,
+		},
+		{
+			Name:        "live",
+			Description: "cmd.live",
+			Usage:       "live.usage",
+			Visibility:  CommandAdmin,
+			Handler:     h.handleLive,
+		},
+		{
+			Name:        "lang",
+			Description: "cmd.lang",
+			Usage:       "lang.usage",
+			Examples:    []string{"/lang ru"},
+			Visibility:  CommandUser,
+			Handler:     h.handleLang,
+		},
+		{
+			Name:        "settings",
+			Description: "cmd.settings",
+			Usage:       "settings.usage",
+			Examples:    []string{"/settings", "/settings tz Europe/Moscow"},
+			Visibility:  CommandUser,
+			Handler:     h.handleSettings,
+		},
+		{
+			Name:        "chatid",
+			Description: "cmd.chatid",
+			Visibility:  CommandUser,
+			Public:      true,
+			Handler:     h.handleChatID,
+		},
+		{
+			Name:        "access",
+			Description: "cmd.access",
+			Usage:       "access.usage",
+			Examples:    []string{"/access allow 215566004", "/access block -1001234567890"},
+			Visibility:  CommandBotAdmin,
+			Handler:     h.handleAccess,
+		},
+		{
+			Name:        "status",
+			Description: "cmd.status",
+			Visibility:  CommandBotAdmin,
+			Handler:     h.handleStatus,
+		},
+	}
+}
+
+func (h *CommandsHandler) command(name string) (Command, bool) {
+	name = strings.TrimPrefix(strings.ToLower(name), "/")
+	for _, cmd := range h.commands {
+		if cmd.Name == name {
+			return cmd, true
+		}
+	}
+
+	return Command{}, false
+}
+
+// usage returns the command synopsis followed by examples.
+func (h *CommandsHandler) usage(loc *i18n.Localizer, name string) string {
+	cmd, ok := h.command(name)
+	if !ok {
+		return ""
+	}
+
+	var out strings.Builder
+	if cmd.Usage != "" {
+		out.WriteString(loc.T(cmd.Usage))
+	} else {
+		out.WriteString("/" + cmd.Name)
+	}
+
+	if len(cmd.Examples) > 0 {
+		out.WriteString("\n\n")
+		out.WriteString(loc.T("help.examples"))
+		for _, example := range cmd.Examples {
+			out.WriteString("\n")
+			out.WriteString(example)
+		}
+	}
+
+	return out.String()
+}
+
+func (h *CommandsHandler) invalidArgs(loc *i18n.Localizer, err error, name string) string {
+	return loc.T("args.invalid", loc.Error(err), h.usage(loc, name))
+}
+
+// help lists the commands, admin ones are listed separately.
+func (h *CommandsHandler) help(loc *i18n.Localizer, botAdmin bool) string {
+	sections := []CommandVisibility{CommandUser, CommandAdmin}
+	if botAdmin {
+		sections = append(sections, CommandBotAdmin)
+	}
+
+	var out strings.Builder
+	out.WriteString(loc.T("help.title"))
+	for _, visibility := range sections {
+		switch visibility {
+		case CommandAdmin:
+			out.WriteString("\n\n")
+			out.WriteString(loc.T("help.admin"))
+		case CommandBotAdmin:
+			out.WriteString("\n\n")
+			out.WriteString(loc.T("help.bot_admin"))
+		}
+
+		for _, cmd := range h.commands {
+			if cmd.Visibility == visibility {
+				_, _ = fmt.Fprintf(&out, "\n/%s - %s", cmd.Name, loc.T(cmd.Description))
+			}
+		}
+	}
+
+	out.WriteString("\n\n")
+	out.WriteString(loc.T("help.details"))
+	return out.String()
+}
+
+func (h *CommandsHandler) menu(loc *i18n.Localizer, withAdmin bool) []BotCommand {
+	var out []BotCommand
+	for _, cmd := range h.commands {
+		switch {
+		case cmd.Visibility == CommandUser:
+		case cmd.Visibility == CommandAdmin && withAdmin:
+		default:
+			continue
+		}
+
+		out = append(out, BotCommand{
+			Command:     cmd.Name,
+			Description: loc.T(cmd.Description),
+		})
+	}
+
+	return out
+}
+
+// SyncMenu replaces the Telegram command menu with the registered commands, in every supported language.
+func (h *CommandsHandler) SyncMenu() error {
+	scopes := []struct {
+		name      string
+		withAdmin bool
+	}{
+		{name: "default"},
+		{name: "all_private_chats", withAdmin: true},
+		{name: "all_chat_administrators", withAdmin: true},
+	}
+
+	for _, scope := range scopes {
+		// the fallback for languages we don't have
+		if err := h.bot.SetCommands(scope.name, "", h.menu(h.prefs.For(0, ""), scope.withAdmin)); err != nil {
+			return fmt.Errorf("set %s commands: %w", scope.name, err)
+		}
+
+		for _, lang := range i18n.Langs {
+			err := h.bot.SetCommands(scope.name, string(lang), h.menu(i18n.NewLocalizer(lang), scope.withAdmin))
+			if err != nil {
+				return fmt.Errorf("set %s commands for %s: %w", scope.name, lang, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+func (h *CommandsHandler) handleHelp(u *objects.Update) {
+	loc := h.localizer(u)
+	botAdmin := h.access.IsAdmin(senderID(u))
+	reply := h.help(loc, botAdmin)
+	args := newCmdArgs(u.Message.Text)
+	if name := args.Shift(); name != "" {
+		cmd, ok := h.command(name)
+		if ok && cmd.Visibility != CommandHidden && (cmd.Visibility != CommandBotAdmin || botAdmin) {
+			reply = fmt.Sprintf("/%s - %s\n\n%s", cmd.Name, loc.T(cmd.Description), h.usage(loc, cmd.Name))
+		} else {
+			reply = loc.T("help.unknown", name)
+		}
+	}
+
+	h.reply(u, reply)
+}
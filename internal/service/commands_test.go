@@ -0,0 +1,39 @@
+package service
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/buglloc/sowettybot/internal/i18n"
+)
+
+func TestRegistry(t *testing.T) {
+	h := &CommandsHandler{}
+	h.commands = h.registry()
+
+	// https://core.telegram.org/bots/api#botcommand
+	nameRe := regexp.MustCompile(`^[a-z0-9_]{1,32}$`)
+	seen := make(map[string]struct{})
+	for _, cmd := range h.commands {
+		t.Run(cmd.Name, func(t *testing.T) {
+			require.Regexp(t, nameRe, cmd.Name)
+			require.NotContains(t, seen, cmd.Name)
+			seen[cmd.Name] = struct{}{}
+			require.NotNil(t, cmd.Handler)
+			require.Contains(t, []CommandVisibility{CommandUser, CommandAdmin, CommandBotAdmin, CommandHidden}, cmd.Visibility)
+
+			for _, lang := range i18n.Langs {
+				loc := i18n.NewLocalizer(lang)
+				desc := loc.T(cmd.Description)
+				require.NotEqual(t, cmd.Description, desc, "no description in %s", lang)
+				require.True(t, len(desc) >= 3 && len(desc) <= 256, "invalid description length in %s", lang)
+
+				if cmd.Usage != "" {
+					require.NotEqual(t, cmd.Usage, loc.T(cmd.Usage), "no usage in %s", lang)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,234 @@
+package service
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+)
+
+// Console is a Messenger printing to a terminal, charts are kept in temporary files.
+type Console struct {
+	mu     sync.Mutex
+	out    io.Writer
+	nextID int
+	// keyboard is the last message with buttons, pressed with "!<data>"
+	keyboard int
+}
+
+var _ Messenger = (*Console)(nil)
+
+func NewConsole(out io.Writer) *Console {
+	return &Console{out: out}
+}
+
+func (c *Console) SendMdMessage(chatID int, text string, replyTo int) error {
+	_, err := c.PostMdMessage(chatID, text, replyTo)
+	return err
+}
+
+func (c *Console) PostMdMessage(chatID int, text string, replyTo int) (int, error) {
+	return c.PostMdMessageWithButtons(chatID, text, replyTo, nil)
+}
+
+func (c *Console) PostMdMessageWithButtons(chatID int, text string, _ int, buttons [][]InlineButton) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.nextID++
+	c.printf("[#%d to %d]\n%s\n", c.nextID, chatID, text)
+	c.printButtons(c.nextID, buttons)
+	return c.nextID, nil
+}
+
+func (c *Console) EditMdMessage(chatID int, messageID int, text string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.printf("[#%d to %d, edited]\n%s\n", messageID, chatID, text)
+	return nil
+}
+
+func (c *Console) EditMdMessageWithButtons(chatID int, messageID int, text string, buttons [][]InlineButton) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.printf("[#%d to %d, edited]\n%s\n", messageID, chatID, text)
+	c.printButtons(messageID, buttons)
+	return nil
+}
+
+func (c *Console) PinMessage(chatID int, messageID int) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.printf("[#%d to %d, pinned]\n", messageID, chatID)
+	return nil
+}
+
+func (c *Console) UnpinMessage(chatID int, messageID int) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.printf("[#%d to %d, unpinned]\n", messageID, chatID)
+	return nil
+}
+
+func (c *Console) SendMdPhoto(chatID int, caption string, photo Photo, replyTo int) (string, error) {
+	return c.SendMdPhotoWithButtons(chatID, caption, photo, replyTo, nil)
+}
+
+func (c *Console) SendMdPhotoWithButtons(chatID int, caption string, photo Photo, _ int, buttons [][]InlineButton) (string, error) {
+	path, err := consolePhoto(photo)
+	if err != nil {
+		return "", err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.nextID++
+	c.printf("[#%d to %d, photo %s]\n%s\n", c.nextID, chatID, path, caption)
+	c.printButtons(c.nextID, buttons)
+	return path, nil
+}
+
+func (c *Console) SendMdDocument(chatID int, doc Document, caption string, _ int) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.nextID++
+	c.printf("[#%d to %d, document %s, %d bytes]\n%s\n%s\n", c.nextID, chatID, doc.Name, len(doc.Data), caption, doc.Data)
+	return nil
+}
+
+func (c *Console) EditMdPhoto(chatID int, messageID int, caption string, photo Photo, buttons [][]InlineButton) (string, error) {
+	path, err := consolePhoto(photo)
+	if err != nil {
+		return "", err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.printf("[#%d to %d, edited photo %s]\n%s\n", messageID, chatID, path, caption)
+	c.printButtons(messageID, buttons)
+	return path, nil
+}
+
+func (c *Console) AnswerCallback(_ string, text string) error {
+	if text == "" {
+		return nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.printf("[callback answer] %s\n", text)
+	return nil
+}
+
+func (c *Console) UploadPhoto(_ int, photoPath string) (string, error) {
+	return keepPhoto(photoPath)
+}
+
+func (c *Console) AnswerInline(_ string, _ int, _ bool, results []InlineResult) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, r := range results {
+		c.printf("[inline %s] %s\n", r.ID, r.Title)
+		if r.Description != "" {
+			c.printf("%s\n", r.Description)
+		}
+
+		if r.PhotoFileID != "" {
+			c.printf("photo %s\n", r.PhotoFileID)
+		}
+
+		if r.Text != "" {
+			c.printf("%s\n", r.Text)
+		}
+	}
+
+	return nil
+}
+
+// ChatMemberStatus makes everyone a creator, so admin commands are available.
+func (c *Console) ChatMemberStatus(int, int) (string, error) {
+	return "creator", nil
+}
+
+func (c *Console) ChatAction(chatID int, action string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.printf("[%d is %s]\n", chatID, action)
+	return nil
+}
+
+func (c *Console) Username() (string, error) {
+	return "sowettybot", nil
+}
+
+func (c *Console) SetCommands(string, string, []BotCommand) error {
+	return nil
+}
+
+// Keyboard returns the last message with buttons.
+func (c *Console) Keyboard() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.keyboard
+}
+
+func (c *Console) printButtons(messageID int, buttons [][]InlineButton) {
+	if len(buttons) > 0 {
+		c.keyboard = messageID
+	}
+
+	for _, row := range buttons {
+		labels := make([]string, len(row))
+		for i, b := range row {
+			labels[i] = fmt.Sprintf("[%s](!%s)", b.Text, b.Data)
+		}
+
+		c.printf("%s\n", strings.Join(labels, " "))
+	}
+}
+
+func (c *Console) printf(format string, args ...interface{}) {
+	_, _ = fmt.Fprintf(c.out, format, args...)
+}
+
+// consolePhoto uses kept copies as file_ids, so cached charts keep pointing at an existing file.
+func consolePhoto(photo Photo) (string, error) {
+	if photo.FileID != "" {
+		return photo.FileID, nil
+	}
+
+	// the caller removes the photo once sent
+	return keepPhoto(photo.Path)
+}
+
+// keepPhoto copies the photo into a temporary file that outlives the handler.
+func keepPhoto(photoPath string) (string, error) {
+	data, err := os.ReadFile(photoPath)
+	if err != nil {
+		return "", fmt.Errorf("read photo: %w", err)
+	}
+
+	f, err := os.CreateTemp("", "sowettybot-chart-*.png")
+	if err != nil {
+		return "", fmt.Errorf("create temporary file: %w", err)
+	}
+	defer func() { _ = f.Close() }()
+
+	if _, err := f.Write(data); err != nil {
+		return "", fmt.Errorf("write photo: %w", err)
+	}
+
+	return f.Name(), nil
+}
@@ -0,0 +1,361 @@
+package service
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/buglloc/sowettybot/internal/config"
+	"github.com/buglloc/sowettybot/internal/history"
+	"github.com/buglloc/sowettybot/internal/i18n"
+	"github.com/buglloc/sowettybot/internal/models"
+	"github.com/buglloc/sowettybot/internal/renderer"
+	"github.com/buglloc/sowettybot/internal/scheduler"
+	"github.com/buglloc/sowettybot/internal/stats"
+	"github.com/buglloc/sowettybot/internal/storage"
+)
+
+const (
+	digestStateName = "digests"
+)
+
+type DigestPeriod string
+
+const (
+	DigestPeriodDaily  DigestPeriod = "daily"
+	DigestPeriodWeekly DigestPeriod = "weekly"
+)
+
+type DigestSubscription struct {
+	ChatID   int          `json:"chat_id"`
+	Period   DigestPeriod `json:"period"`
+	Weekday  time.Weekday `json:"weekday"`
+	Hour     int          `json:"hour"`
+	Minute   int          `json:"minute"`
+	Timezone string       `json:"timezone"`
+	Lang     i18n.Lang    `json:"lang"`
+}
+
+func ParseDigestSubscription(args *cmdArgs, defaultTZ string) (DigestSubscription, error) {
+	out := DigestSubscription{
+		Timezone: defaultTZ,
+	}
+
+	if args.Len() == 0 {
+		return out, i18n.Errorf("digest.err.period_required")
+	}
+
+	period := args.Shift()
+	out.Period = DigestPeriod(period)
+	switch out.Period {
+	case DigestPeriodDaily:
+	case DigestPeriodWeekly:
+		if args.Len() == 0 {
+			return out, i18n.Errorf("digest.err.weekday_required")
+		}
+
+		day, err := parseWeekday(args.Shift())
+		if err != nil {
+			return out, err
+		}
+
+		out.Weekday = day
+	default:
+		return out, i18n.Errorf("digest.err.unsupported_period", period)
+	}
+
+	if args.Len() == 0 {
+		return out, i18n.Errorf("digest.err.time_required")
+	}
+
+	rawAt := args.Shift()
+	at, err := time.Parse("15:04", rawAt)
+	if err != nil {
+		return out, i18n.Errorf("digest.err.invalid_time", rawAt)
+	}
+	out.Hour, out.Minute = at.Hour(), at.Minute()
+
+	if args.Len() > 0 {
+		// time zone names are case sensitive
+		tz := args.ShiftRaw()
+		if _, err := time.LoadLocation(tz); err != nil {
+			return out, i18n.Errorf("digest.err.invalid_tz", tz)
+		}
+
+		out.Timezone = tz
+	}
+
+	return out, args.Done()
+}
+
+func (s DigestSubscription) Location() *time.Location {
+	if s.Timezone == "" {
+		return time.Local
+	}
+
+	loc, err := time.LoadLocation(s.Timezone)
+	if err != nil {
+		return time.Local
+	}
+
+	return loc
+}
+
+func (s DigestSubscription) Window() time.Duration {
+	if s.Period == DigestPeriodWeekly {
+		return 7 * 24 * time.Hour
+	}
+
+	return 24 * time.Hour
+}
+
+func (s DigestSubscription) Schedule() scheduler.NextFunc {
+	if s.Period == DigestPeriodWeekly {
+		return scheduler.Weekly(s.Weekday, s.Hour, s.Minute, s.Location())
+	}
+
+	return scheduler.Daily(s.Hour, s.Minute, s.Location())
+}
+
+func (s DigestSubscription) String() string {
+	return s.Describe(i18n.NewLocalizer(i18n.LangEN))
+}
+
+func (s DigestSubscription) Describe(loc *i18n.Localizer) string {
+	if s.Period == DigestPeriodWeekly {
+		return loc.T("digest.schedule.weekly", loc.T(fmt.Sprintf("weekday.%d", s.Weekday)), s.Hour, s.Minute, s.Location())
+	}
+
+	return loc.T("digest.schedule.daily", s.Hour, s.Minute, s.Location())
+}
+
+type Digester struct {
+	bot       Messenger
+	history   *history.History
+	renderer  *renderer.HistoryRenderer
+	storage   *storage.Storage
+	scheduler *scheduler.Scheduler
+	exchanges []config.Exchange
+	prefs     *PreferenceStore
+	defaultTZ string
+	mu        sync.Mutex
+	subs      map[int]DigestSubscription
+}
+
+func (d *Digester) Initialize() error {
+	var subs []DigestSubscription
+	if err := d.storage.Load(digestStateName, &subs); err != nil {
+		return fmt.Errorf("unable to load digest subscriptions: %w", err)
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.subs = make(map[int]DigestSubscription, len(subs))
+	for _, sub := range subs {
+		d.subs[sub.ChatID] = sub
+		d.schedule(sub)
+	}
+
+	return nil
+}
+
+func (d *Digester) Subscription(chatID int) (DigestSubscription, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	sub, ok := d.subs[chatID]
+	return sub, ok
+}
+
+// Location returns the time zone of the chat digest, the default one for chats without it.
+func (d *Digester) Location(chatID int) *time.Location {
+	sub, ok := d.Subscription(chatID)
+	if !ok {
+		sub = DigestSubscription{Timezone: d.defaultTZ}
+	}
+
+	return sub.Location()
+}
+
+func (d *Digester) Subscribe(sub DigestSubscription) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.subs[sub.ChatID] = sub
+	d.schedule(sub)
+	return d.lockedSave()
+}
+
+func (d *Digester) Unsubscribe(chatID int) (bool, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if _, ok := d.subs[chatID]; !ok {
+		return false, nil
+	}
+
+	delete(d.subs, chatID)
+	d.scheduler.Remove(digestJobName(chatID))
+	return true, d.lockedSave()
+}
+
+func (d *Digester) Send(sub DigestSubscription) error {
+	now := time.Now().In(sub.Location())
+	digest, entries, err := d.Build(sub, now)
+	if err != nil {
+		return err
+	}
+
+	loc := d.prefs.For(sub.ChatID, string(sub.Lang))
+	if len(entries) == 0 {
+		return d.bot.SendMdMessage(sub.ChatID, loc.T("history.unavailable"), 0)
+	}
+
+	msg, err := d.renderer.For(loc, now.Location()).Digest(digest)
+	if err != nil {
+		return err
+	}
+
+	if err := d.bot.SendMdMessage(sub.ChatID, msg, 0); err != nil {
+		return fmt.Errorf("send digest: %w", err)
+	}
+
+	graphF, err := os.CreateTemp("", "sowetty-digest-*.png")
+	if err != nil {
+		return fmt.Errorf("create temporary file: %w", err)
+	}
+	defer func() {
+		_ = graphF.Close()
+		_ = os.RemoveAll(graphF.Name())
+	}()
+
+	cfg := d.prefs.Get(sub.ChatID).GraphConfig(len(entries)).Location(now.Location())
+	startDate, endDate, err := d.renderer.Graph(entries, graphF, cfg)
+	if err != nil {
+		return fmt.Errorf("render graph: %w", err)
+	}
+
+	_, err = d.bot.SendMdPhoto(
+		sub.ChatID,
+		fmt.Sprintf(
+			"`%s -> %s`",
+			loc.Time(startDate.In(now.Location()), "layout.datetime"),
+			loc.Time(endDate.In(now.Location()), "layout.datetime"),
+		),
+		Photo{Path: graphF.Name()},
+		0,
+	)
+	return err
+}
+
+// Build summarizes the period before now, changes are against the period before it.
+// Both of them must fit into limits.history.overall, there are no changes otherwise.
+func (d *Digester) Build(sub DigestSubscription, now time.Time) (models.Digest, []models.History, error) {
+	window := sub.Window()
+	out := models.Digest{
+		Period: string(sub.Period),
+		From:   now.Add(-window),
+		To:     now,
+	}
+
+	entries, err := d.history.Between(out.From, out.To)
+	if err != nil {
+		return out, nil, fmt.Errorf("get entries: %w", err)
+	}
+
+	if len(entries) == 0 {
+		return out, nil, nil
+	}
+
+	prevEntries, err := d.history.Between(out.From.Add(-window), out.From)
+	if err != nil {
+		return out, nil, fmt.Errorf("get previous entries: %w", err)
+	}
+
+	prev := make(map[string]stats.Summary)
+	for _, s := range stats.Summarize(prevEntries) {
+		prev[s.Name] = s
+	}
+
+	for _, s := range stats.Summarize(entries) {
+		if s.Count == 0 {
+			continue
+		}
+
+		ex := models.DigestExchange{
+			Name:   exchangeName(d.exchanges, s.Name),
+			Latest: s.Last,
+			Min:    s.Min,
+			Max:    s.Max,
+			Avg:    s.Mean,
+		}
+
+		if p, ok := prev[s.Name]; ok && p.Count > 0 {
+			ex.Change = s.Mean - p.Mean
+			ex.ChangePct = ex.Change / p.Mean * 100
+		}
+
+		out.Exchanges = append(out.Exchanges, ex)
+	}
+
+	return out, entries, nil
+}
+
+func (d *Digester) schedule(sub DigestSubscription) {
+	d.scheduler.Add(digestJobName(sub.ChatID), sub.Schedule(), func() {
+		if err := d.Send(sub); err != nil {
+			log.Error().Err(err).Int("chat_id", sub.ChatID).Msg("unable to send digest")
+		}
+	})
+}
+
+func (d *Digester) lockedSave() error {
+	subs := make([]DigestSubscription, 0, len(d.subs))
+	for _, sub := range d.subs {
+		subs = append(subs, sub)
+	}
+
+	sort.Slice(subs, func(i, j int) bool {
+		return subs[i].ChatID < subs[j].ChatID
+	})
+
+	if err := d.storage.Save(digestStateName, subs); err != nil {
+		return fmt.Errorf("unable to save digest subscriptions: %w", err)
+	}
+
+	return nil
+}
+
+func digestJobName(chatID int) string {
+	return "digest:" + strconv.Itoa(chatID)
+}
+
+func parseWeekday(in string) (time.Weekday, error) {
+	in = strings.ToLower(in)
+	if len(in) >= 3 {
+		for d := time.Sunday; d <= time.Saturday; d++ {
+			if strings.HasPrefix(strings.ToLower(d.String()), in) {
+				return d, nil
+			}
+		}
+	}
+
+	return time.Sunday, i18n.Errorf("digest.err.invalid_weekday", in)
+}
+
+func exchangeName(exchanges []config.Exchange, slug string) string {
+	for _, ex := range exchanges {
+		if ex.Slug == slug {
+			return ex.Name
+		}
+	}
+
+	return slug
+}
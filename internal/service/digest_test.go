@@ -0,0 +1,146 @@
+package service
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/buglloc/sowettybot/internal/config"
+	"github.com/buglloc/sowettybot/internal/history"
+	"github.com/buglloc/sowettybot/internal/i18n"
+)
+
+func TestParseDigestSubscription(t *testing.T) {
+	cases := []struct {
+		in  string
+		sub DigestSubscription
+		err string
+	}{
+		{
+			in:  "/digest daily 09:30",
+			sub: DigestSubscription{Period: DigestPeriodDaily, Hour: 9, Minute: 30, Timezone: "UTC"},
+		},
+		{
+			in:  "/digest weekly mon 18:00 Asia/Bangkok",
+			sub: DigestSubscription{Period: DigestPeriodWeekly, Weekday: time.Monday, Hour: 18, Timezone: "Asia/Bangkok"},
+		},
+		{
+			in:  "/digest weekly Sunday 07:05",
+			sub: DigestSubscription{Period: DigestPeriodWeekly, Weekday: time.Sunday, Hour: 7, Minute: 5, Timezone: "UTC"},
+		},
+		{in: "/digest", err: "digest.err.period_required"},
+		{in: "/digest monthly 09:00", err: "digest.err.unsupported_period"},
+		{in: "/digest weekly", err: "digest.err.weekday_required"},
+		{in: "/digest weekly mo 09:00", err: "digest.err.invalid_weekday"},
+		{in: "/digest daily", err: "digest.err.time_required"},
+		{in: "/digest daily 25:00", err: "digest.err.invalid_time"},
+		{in: "/digest daily 09:00 Mars/Olympus", err: "digest.err.invalid_tz"},
+		{in: "/digest daily 09:00 UTC now", err: "args.err.unexpected"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.in, func(t *testing.T) {
+			sub, err := ParseDigestSubscription(newCmdArgs(tc.in), "UTC")
+			if tc.err != "" {
+				var locErr *i18n.Error
+				require.ErrorAs(t, err, &locErr)
+				require.Equal(t, tc.err, locErr.Key)
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, tc.sub, sub)
+		})
+	}
+}
+
+func TestDigesterBuild(t *testing.T) {
+	// a week of 2.5 and a week of 2.6 with the very last rate of 2.7, korona is down the second week
+	start := time.Date(2023, 6, 1, 0, 0, 0, 0, time.UTC)
+	histFile := filepath.Join(t.TempDir(), "rates.txt")
+	f, err := os.Create(histFile)
+	require.NoError(t, err)
+	const hours = 14 * 24
+	for i := 0; i < hours; i++ {
+		contact, korona := 2.5, 2.6
+		if i >= hours/2 {
+			contact, korona = 2.6, 0
+		}
+		if i == hours-1 {
+			contact = 2.7
+		}
+
+		when := start.Add(time.Duration(i) * time.Hour)
+		_, err := fmt.Fprintf(f, "%s contact=%.2f korona=%.2f\n", when.Format(time.RFC822), contact, korona)
+		require.NoError(t, err)
+	}
+	require.NoError(t, f.Close())
+
+	d := &Digester{
+		history: history.NewHistory(histFile, 1000),
+		exchanges: []config.Exchange{
+			{Name: "Contact", Slug: "contact"},
+		},
+	}
+
+	now := start.Add(hours * time.Hour)
+	cases := []struct {
+		name    string
+		sub     DigestSubscription
+		now     time.Time
+		entries int
+		avg     float64
+		change  float64
+	}{
+		{
+			name:    "daily",
+			sub:     DigestSubscription{Period: DigestPeriodDaily},
+			now:     now,
+			entries: 24,
+			avg:     (23*2.6 + 2.7) / 24,
+			change:  (23*2.6+2.7)/24 - 2.6,
+		},
+		{
+			name:    "weekly",
+			sub:     DigestSubscription{Period: DigestPeriodWeekly},
+			now:     now,
+			entries: 7 * 24,
+			avg:     (167*2.6 + 2.7) / 168,
+			change:  (167*2.6+2.7)/168 - 2.5,
+		},
+		{
+			name: "no_history",
+			sub:  DigestSubscription{Period: DigestPeriodDaily},
+			now:  now.Add(7 * 24 * time.Hour),
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			digest, entries, err := d.Build(tc.sub, tc.now)
+			require.NoError(t, err)
+			require.Len(t, entries, tc.entries)
+			require.Equal(t, string(tc.sub.Period), digest.Period)
+			require.Equal(t, tc.now.Add(-tc.sub.Window()), digest.From)
+			if tc.entries == 0 {
+				require.Empty(t, digest.Exchanges)
+				return
+			}
+
+			// korona has no rates for the period
+			require.Len(t, digest.Exchanges, 1)
+			ex := digest.Exchanges[0]
+			require.Equal(t, "Contact", ex.Name)
+			require.Equal(t, 2.7, ex.Latest)
+			require.Equal(t, 2.6, ex.Min)
+			require.Equal(t, 2.7, ex.Max)
+			require.InDelta(t, tc.avg, ex.Avg, 1e-9)
+			require.InDelta(t, tc.change, ex.Change, 1e-9)
+			require.InDelta(t, tc.change/(tc.avg-tc.change)*100, ex.ChangePct, 1e-9)
+		})
+	}
+}
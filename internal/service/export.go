@@ -0,0 +1,83 @@
+package service
+
+import (
+	"bytes"
+	"fmt"
+	"time"
+
+	"github.com/SakoDroid/telego/objects"
+	"github.com/rs/zerolog/log"
+
+	"github.com/buglloc/sowettybot/internal/export"
+)
+
+const defaultExportPeriod = 7 * 24 * time.Hour
+
+func (h *CommandsHandler) handleExport(u *objects.Update) {
+	loc := h.localizer(u)
+	chatID := u.Message.Chat.Id
+	reply, err := func() (string, error) {
+		args := newCmdArgs(u.Message.Text)
+		tz := h.location(prefsKey(u), chatID)
+		r, ok := args.TimeRange(tz)
+		if !ok {
+			r = TimeRange{Period: defaultExportPeriod}
+		}
+
+		format := export.FormatCSV
+		if f, ok := args.Keyword(string(export.FormatCSV), string(export.FormatJSON)); ok {
+			format = export.Format(f)
+		}
+
+		if err := args.Done(); err != nil {
+			return h.invalidArgs(loc, err, "export"), nil
+		}
+
+		now := time.Now()
+		from, to := r.Bounds(now)
+		entries, err := h.history.Between(from, to)
+		if err != nil {
+			return "", fmt.Errorf("get entries: %w", err)
+		}
+
+		if len(entries) == 0 {
+			return loc.T("history.unavailable"), nil
+		}
+
+		meta := export.Meta{
+			Exchanges: h.exchanges,
+			Location:  tz,
+			From:      from,
+			To:        to,
+			Generated: now,
+		}
+
+		var data bytes.Buffer
+		if err := export.Write(&data, format, meta, entries); err != nil {
+			return "", fmt.Errorf("export history: %w", err)
+		}
+
+		doc := Document{
+			Name: export.FileName(meta, format),
+			Data: data.Bytes(),
+		}
+		caption := loc.T(
+			"export.caption",
+			loc.N("export.entries", len(entries)),
+			loc.Time(from.In(tz), "layout.datetime"),
+			loc.Time(to.In(tz), "layout.datetime"),
+		)
+		return "", h.bot.SendMdDocument(chatID, doc, caption, u.Message.MessageId)
+	}()
+
+	if err != nil {
+		log.Error().Err(err).Int("chat_id", chatID).Msg("failed to export history")
+		reply = loc.T("error.generic", err)
+	}
+
+	if reply == "" {
+		return
+	}
+
+	h.reply(u, reply)
+}
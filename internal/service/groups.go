@@ -0,0 +1,184 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/SakoDroid/telego/objects"
+	"github.com/rs/zerolog/log"
+
+	"github.com/buglloc/sowettybot/internal/storage"
+)
+
+const (
+	groupsStateName = "groups"
+)
+
+type Group struct {
+	ChatID  int       `json:"chat_id"`
+	Type    string    `json:"type"`
+	Title   string    `json:"title"`
+	AddedBy int       `json:"added_by"`
+	AddedAt time.Time `json:"added_at"`
+	// Muted is set while the bot may not send messages to the chat
+	Muted bool `json:"muted,omitempty"`
+}
+
+// Groups tracks group chats and channels the bot is a member of.
+type Groups struct {
+	bot     Messenger
+	storage *storage.Storage
+	prefs   *PreferenceStore
+	// onLeave is called when the bot is removed from a chat or blocked by a user
+	onLeave []func(chatID int)
+	mu      sync.Mutex
+	groups  map[int]Group
+}
+
+func (g *Groups) Initialize() error {
+	var groups []Group
+	if err := g.storage.Load(groupsStateName, &groups); err != nil {
+		return fmt.Errorf("unable to load groups: %w", err)
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.groups = make(map[int]Group, len(groups))
+	for _, group := range groups {
+		g.groups[group.ChatID] = group
+	}
+
+	return nil
+}
+
+func (g *Groups) OnLeave(fn func(chatID int)) {
+	g.onLeave = append(g.onLeave, fn)
+}
+
+// Muted reports whether the bot is restricted from sending messages to the chat.
+func (g *Groups) Muted(chatID int) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	return g.groups[chatID].Muted
+}
+
+// HandleMyChatMember processes changes of the bot own membership.
+func (g *Groups) HandleMyChatMember(u *objects.Update) {
+	upd := u.MyChatMember
+	var member struct {
+		Status string `json:"status"`
+		// IsMember and CanSendMessages are set for the restricted status only
+		IsMember        bool `json:"is_member"`
+		CanSendMessages bool `json:"can_send_messages"`
+	}
+	if err := json.Unmarshal(upd.NewChatMember, &member); err != nil {
+		log.Error().Err(err).Int("chat_id", upd.Chat.Id).Msg("unable to parse chat member")
+		return
+	}
+
+	status := member.Status
+	if status == "restricted" {
+		status = "member"
+		if !member.IsMember {
+			status = "left"
+		}
+	}
+
+	switch status {
+	case "member", "administrator":
+		if upd.Chat.Type == "private" {
+			return
+		}
+
+		// muting is reversible, so the chat settings are kept and only sending is paused
+		muted := member.Status == "restricted" && !member.CanSendMessages
+		added, err := g.add(upd, muted)
+		if err != nil {
+			log.Error().Err(err).Int("chat_id", upd.Chat.Id).Msg("unable to save group")
+		}
+
+		if !added || muted || upd.Chat.Type == "channel" {
+			return
+		}
+
+		code := ""
+		if upd.From != nil {
+			code = upd.From.LanguageCode
+		}
+
+		log.Info().Int("chat_id", upd.Chat.Id).Str("title", upd.Chat.Title).Msg("bot was added to the group")
+		err = g.bot.SendMdMessage(upd.Chat.Id, g.prefs.For(upd.Chat.Id, code).T("groups.greeting"), 0)
+		if err != nil {
+			log.Error().Err(err).Int("chat_id", upd.Chat.Id).Msg("unable to send greeting")
+		}
+	case "left", "kicked":
+		log.Info().Int("chat_id", upd.Chat.Id).Str("status", member.Status).Msg("bot was removed from the chat")
+		if err := g.remove(upd.Chat.Id); err != nil {
+			log.Error().Err(err).Int("chat_id", upd.Chat.Id).Msg("unable to save groups")
+		}
+
+		for _, fn := range g.onLeave {
+			fn(upd.Chat.Id)
+		}
+	}
+}
+
+func (g *Groups) add(upd *objects.ChatMemberUpdated, muted bool) (bool, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	_, exists := g.groups[upd.Chat.Id]
+	group := Group{
+		ChatID:  upd.Chat.Id,
+		Type:    upd.Chat.Type,
+		Title:   upd.Chat.Title,
+		AddedAt: time.Unix(int64(upd.Date), 0),
+		Muted:   muted,
+	}
+	if upd.From != nil {
+		group.AddedBy = upd.From.Id
+	}
+
+	if exists {
+		// e.g. promoted to admin, keep the original info
+		group.AddedBy = g.groups[upd.Chat.Id].AddedBy
+		group.AddedAt = g.groups[upd.Chat.Id].AddedAt
+	}
+
+	g.groups[upd.Chat.Id] = group
+	return !exists, g.lockedSave()
+}
+
+func (g *Groups) remove(chatID int) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if _, ok := g.groups[chatID]; !ok {
+		return nil
+	}
+
+	delete(g.groups, chatID)
+	return g.lockedSave()
+}
+
+func (g *Groups) lockedSave() error {
+	groups := make([]Group, 0, len(g.groups))
+	for _, group := range g.groups {
+		groups = append(groups, group)
+	}
+
+	sort.Slice(groups, func(i, j int) bool {
+		return groups[i].ChatID < groups[j].ChatID
+	})
+
+	if err := g.storage.Save(groupsStateName, groups); err != nil {
+		return fmt.Errorf("unable to save groups: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,72 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/SakoDroid/telego/objects"
+	"github.com/stretchr/testify/require"
+
+	"github.com/buglloc/sowettybot/internal/storage"
+)
+
+func TestGroupsMyChatMember(t *testing.T) {
+	cases := []struct {
+		name   string
+		member string
+		joined bool
+		muted  bool
+	}{
+		{name: "member", member: `{"status": "member"}`, joined: true},
+		{name: "administrator", member: `{"status": "administrator"}`, joined: true},
+		{name: "restricted", member: `{"status": "restricted", "is_member": true, "can_send_messages": true}`, joined: true},
+		{name: "muted", member: `{"status": "restricted", "is_member": true, "can_send_messages": false}`, joined: true, muted: true},
+		{name: "restricted_left", member: `{"status": "restricted", "is_member": false, "can_send_messages": true}`},
+		{name: "left", member: `{"status": "left"}`},
+		{name: "kicked", member: `{"status": "kicked"}`},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			store := storage.NewStorage("")
+			fake := newFakeMessenger()
+			groups := &Groups{bot: fake, storage: store, prefs: NewPreferenceStore(store, "en")}
+			require.NoError(t, groups.Initialize())
+
+			var left []int
+			groups.OnLeave(func(chatID int) {
+				left = append(left, chatID)
+			})
+
+			update := func(member string) {
+				groups.HandleMyChatMember(&objects.Update{
+					MyChatMember: &objects.ChatMemberUpdated{
+						Chat:          &objects.Chat{Id: -100, Type: "group"},
+						From:          &objects.User{Id: 1},
+						NewChatMember: []byte(member),
+					},
+				})
+			}
+
+			update(`{"status": "member"}`)
+			require.Len(t, fake.take(), 1, "greeting")
+
+			update(tc.member)
+			groups.mu.Lock()
+			_, joined := groups.groups[-100]
+			groups.mu.Unlock()
+			require.Equal(t, tc.joined, joined)
+			require.Equal(t, tc.muted, groups.Muted(-100))
+			if tc.joined {
+				require.Empty(t, left)
+				require.Empty(t, fake.take(), "greeted once")
+
+				// unmuted again
+				update(`{"status": "member"}`)
+				require.False(t, groups.Muted(-100))
+				require.Empty(t, left)
+			} else {
+				require.Equal(t, []int{-100}, left)
+			}
+		})
+	}
+}
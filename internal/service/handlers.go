@@ -2,10 +2,13 @@ package service
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"math"
-	"os"
+	"strconv"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/SakoDroid/telego/objects"
 	"github.com/jellydator/ttlcache/v3"
@@ -13,49 +16,204 @@ import (
 
 	"github.com/buglloc/sowettybot/internal/config"
 	"github.com/buglloc/sowettybot/internal/history"
+	"github.com/buglloc/sowettybot/internal/i18n"
 	"github.com/buglloc/sowettybot/internal/models"
 	"github.com/buglloc/sowettybot/internal/rateit"
+	"github.com/buglloc/sowettybot/internal/ratelimit"
 	"github.com/buglloc/sowettybot/internal/renderer"
+	"github.com/buglloc/sowettybot/internal/rules"
+)
+
+const (
+	defaultRawHistory = 24
+	// maxRawHistory bounds the reply, long ones are split or sent as a document
+	maxRawHistory = 500
 )
 
 type CommandsHandler struct {
-	bot        *BotWrapper
+	bot        Messenger
 	rtc        *rateit.Client
 	history    *history.History
 	renderer   *renderer.HistoryRenderer
 	exchanges  []config.Exchange
 	limits     config.Limits
+	best       config.Best
 	ratesCache *ttlcache.Cache[string, models.Rate]
+	digester   *Digester
+	notifier   *Notifier
+	live       *LiveBoards
+	charts     *ChartCache
+	prefs      *PreferenceStore
+	access     *Access
+	userLimits *ratelimit.Limiter
+	chatLimits *ratelimit.Limiter
+	renders    chan struct{}
+	outbox     *Outbox
+	startedAt  time.Time
+	botName    string
+	commands   []Command
+	routes     map[string]func(u *objects.Update)
 }
 
 func (h *CommandsHandler) Initialize() error {
-	toRegister := map[string]func(u *objects.Update){
-		"/start":       h.handleStart,
-		"/chatid":      h.handleChatID,
-		"/rates":       h.handleRates,
-		"/history":     h.handleHistoryChart,
-		"/longhistory": h.handleLongHistoryChart,
-		"/rawhistory":  h.handleHistoryText,
+	botName, err := h.bot.Username()
+	if err != nil {
+		return fmt.Errorf("unable to get bot username: %w", err)
 	}
+	h.botName = botName
 
-	for pattern, handler := range toRegister {
-		wrappedHandler := h.panicMiddleware(pattern, handler)
-		if err := h.bot.AddHandler(pattern, wrappedHandler, "private"); err != nil {
-			return fmt.Errorf("unable to register handler %q: %w", pattern, err)
-		}
+	h.commands = h.registry()
+	h.routes = make(map[string]func(u *objects.Update), len(h.commands))
+	for _, cmd := range h.commands {
+		pattern := "/" + cmd.Name
+		h.routes[pattern] = h.panicMiddleware(pattern, h.rateLimitMiddleware(h.accessMiddleware(cmd, cmd.Handler)))
+	}
+
+	if err := h.SyncMenu(); err != nil {
+		// the bot is still usable without the menu
+		log.Error().Err(err).Msg("unable to sync commands menu")
 	}
 
 	return nil
 }
 
+// HandleMessage routes a message or a channel post to the command handler.
+func (h *CommandsHandler) HandleMessage(u *objects.Update) {
+	if u.Message == nil {
+		// channel posts are handled the same way, only admins can post there anyway
+		u.Message = u.ChannelPost
+	}
+
+	if u.Message == nil || u.Message.Chat == nil {
+		return
+	}
+
+	cmd, mention, ok := parseCommand(u.Message.Text)
+	if !ok {
+		if u.Message.Chat.Type == "private" {
+			h.replyUnsupported(u)
+		}
+		return
+	}
+
+	if mention != "" && !strings.EqualFold(mention, h.botName) {
+		// addressed to another bot in the group
+		return
+	}
+
+	handler, ok := h.routes[cmd]
+	if !ok {
+		if u.Message.Chat.Type == "private" || mention != "" {
+			h.replyUnsupported(u)
+		}
+		return
+	}
+
+	handler(u)
+}
+
+// localizer returns the localizer for the chat of the update.
+func (h *CommandsHandler) localizer(u *objects.Update) *i18n.Localizer {
+	code := ""
+	if u.Message.From != nil {
+		code = u.Message.From.LanguageCode
+	}
+
+	return h.prefs.For(prefsKey(u), code)
+}
+
+// prefsKey picks whose preferences apply: the sender ones, or the chat ones for channel posts having no sender.
+func prefsKey(u *objects.Update) int {
+	if u.Message.From != nil {
+		return u.Message.From.Id
+	}
+
+	return u.Message.Chat.Id
+}
+
+// callbackPrefsKey is prefsKey for button presses, in channels they change the channel preferences.
+func callbackPrefsKey(q *objects.CallbackQuery) int {
+	if q.Message.Chat != nil && q.Message.Chat.Type == "channel" {
+		return q.Message.Chat.Id
+	}
+
+	return q.From.Id
+}
+
+func (h *CommandsHandler) replyUnsupported(u *objects.Update) {
+	err := h.bot.SendMdMessage(u.Message.Chat.Id, h.localizer(u).T("error.unsupported"), u.Message.MessageId)
+	if err != nil {
+		log.Error().Err(err).Int("chat_id", u.Message.Chat.Id).Msg("unable to send reply")
+	}
+}
+
+// isAdmin reports whether the sender may change the chat settings: always true in private chats and channels.
+func (h *CommandsHandler) isAdmin(u *objects.Update) bool {
+	if u.Message.Chat.Type == "private" {
+		return true
+	}
+
+	if u.Message.SenderChat != nil && u.Message.SenderChat.Id == u.Message.Chat.Id {
+		// a channel post or an anonymous group admin, only admins can post on behalf of the chat
+		return true
+	}
+
+	if u.Message.From == nil {
+		return false
+	}
+
+	return h.isChatAdmin(u.Message.Chat, u.Message.From.Id)
+}
+
+// isChatAdmin reports whether the user is an admin of the chat, private chats are administered by their users.
+// Anyone may press buttons under channel posts, so channels are checked as well.
+func (h *CommandsHandler) isChatAdmin(chat *objects.Chat, userID int) bool {
+	if chat.Type == "private" {
+		return true
+	}
+
+	status, err := h.bot.ChatMemberStatus(chat.Id, userID)
+	if err != nil {
+		log.Error().Err(err).Int("chat_id", chat.Id).Int("user_id", userID).Msg("unable to get chat member")
+		return false
+	}
+
+	return status == "creator" || status == "administrator"
+}
+
+// requireAdmin replies with an error and returns false if the sender isn't a chat admin.
+func (h *CommandsHandler) requireAdmin(u *objects.Update) bool {
+	if h.isAdmin(u) {
+		return true
+	}
+
+	err := h.bot.SendMdMessage(u.Message.Chat.Id, h.localizer(u).T("error.admin_only"), u.Message.MessageId)
+	if err != nil {
+		log.Error().Err(err).Int("chat_id", u.Message.Chat.Id).Msg("unable to send reply")
+	}
+	return false
+}
+
+// parseCommand splits "/cmd@botname args" into the command and the mentioned bot name.
+func parseCommand(text string) (cmd string, mention string, ok bool) {
+	fields := strings.Fields(text)
+	if len(fields) == 0 || !strings.HasPrefix(fields[0], "/") {
+		return "", "", false
+	}
+
+	cmd, mention, _ = strings.Cut(fields[0], "@")
+	return strings.ToLower(cmd), mention, true
+}
+
 func (h *CommandsHandler) Tick() {
 	h.ratesCache.DeleteExpired()
 }
 
 func (h *CommandsHandler) handleStart(u *objects.Update) {
+	loc := h.localizer(u)
 	err := h.bot.SendMdMessage(
 		u.Message.Chat.Id,
-		"Nice to see you, type /history to get exchange rates history ;)",
+		loc.T("start.greeting")+"\n\n"+h.help(loc, h.access.IsAdmin(senderID(u))),
 		u.Message.MessageId,
 	)
 	if err != nil {
@@ -66,7 +224,7 @@ func (h *CommandsHandler) handleStart(u *objects.Update) {
 func (h *CommandsHandler) handleChatID(u *objects.Update) {
 	err := h.bot.SendMdMessage(
 		u.Message.Chat.Id,
-		fmt.Sprintf("chat id: `%d`", u.Message.Chat.Id),
+		h.localizer(u).T("chatid", u.Message.Chat.Id),
 		u.Message.MessageId,
 	)
 	if err != nil {
@@ -75,36 +233,90 @@ func (h *CommandsHandler) handleChatID(u *objects.Update) {
 }
 
 func (h *CommandsHandler) handleRates(u *objects.Update) {
-	_, _ = h.bot.SendMessage(u.Message.Chat.Id, "I'll check exchange rates...please be patient...", "", u.Message.MessageId, true, false)
+	loc := h.localizer(u)
+	exchanges := h.favourites(h.prefs.Get(prefsKey(u)))
+	var mu sync.Mutex
+	marks := make([]string, len(exchanges))
+	for i := range marks {
+		marks[i] = "⏳"
+	}
+	progressText := func() string {
+		var out strings.Builder
+		out.WriteString(loc.T("rates.patient"))
+		for i, ex := range exchanges {
+			_, _ = fmt.Fprintf(&out, "\n%s %s", marks[i], ex.Name)
+		}
+
+		return out.String()
+	}
+
+	progress := NewProgress(h.bot, u.Message.Chat.Id, u.Message.MessageId, progressText())
+	rates := h.fetchRates(exchanges, func(i int, err error) {
+		mu.Lock()
+		defer mu.Unlock()
 
-	reply, err := h.renderRates()
+		marks[i] = "✓"
+		if err != nil {
+			marks[i] = "✗"
+		}
+		progress.Update(progressText())
+	})
+
+	reply, err := h.renderer.For(loc, h.location(prefsKey(u), u.Message.Chat.Id)).Rates(rates)
 	if err != nil {
 		log.Error().Err(err).Int("chat_id", u.Message.Chat.Id).Msg("failed to generate rates")
-		reply = fmt.Sprintf("shit happens: %v", err)
+		reply = loc.T("error.generic", err)
 	}
 
-	err = h.bot.SendMdMessage(u.Message.Chat.Id, reply, 0)
-	if err != nil {
+	if err := progress.Finish(reply); err != nil {
 		log.Error().Err(err).Int("chat_id", u.Message.Chat.Id).Msg("unable to send reply")
 	}
 }
 
 func (h *CommandsHandler) handleHistoryText(u *objects.Update) {
+	loc := h.localizer(u)
 	reply, err := func() (string, error) {
-		entries, err := h.history.Entries(24)
+		args := newCmdArgs(u.Message.Text)
+		count, hasCount := args.Count(maxRawHistory)
+		tz := h.location(prefsKey(u), u.Message.Chat.Id)
+		r, hasRange := args.TimeRange(tz)
+		ex, hasExchange := args.Exchange(h.exchanges)
+		if err := args.Done(); err != nil {
+			return h.invalidArgs(loc, err, "rawhistory"), nil
+		}
+
+		if !hasCount {
+			count = defaultRawHistory
+			if hasRange {
+				count = maxRawHistory
+			}
+		}
+
+		var entries []models.History
+		var err error
+		if hasRange {
+			entries, err = h.history.Between(r.Bounds(time.Now()))
+			entries = sampleEntries(entries, count)
+		} else {
+			entries, err = h.history.Entries(count)
+		}
 		if err != nil {
 			return "", fmt.Errorf("get entries: %w", err)
 		}
 
+		if hasExchange {
+			entries = filterEntries(entries, h.hiddenSeries(ChartView{}.Only(h.exchanges, ex.Slug)))
+		}
+
 		if len(entries) == 0 {
-			return "Sorry, history is unavailable so far", nil
+			return loc.T("history.unavailable"), nil
 		}
 
-		return h.renderer.Log(entries)
+		return h.renderer.For(loc, tz).Log(entries)
 	}()
 
 	if err != nil {
-		reply = fmt.Sprintf("ooops, shit happens: %v", err)
+		reply = loc.T("error.generic", err)
 		log.Error().Err(err).Int("chat_id", u.Message.Chat.Id).Msg("failed to get history")
 	}
 
@@ -115,90 +327,781 @@ func (h *CommandsHandler) handleHistoryText(u *objects.Update) {
 }
 
 func (h *CommandsHandler) handleHistoryChart(u *objects.Update) {
-	h.sendHistoryChart(u, h.limits.History.Short)
+	view := h.prefs.Get(prefsKey(u)).ChartView(h.exchanges, ChartRangeShort)
+	h.sendHistoryChart(u, "history", view)
 }
 
 func (h *CommandsHandler) handleLongHistoryChart(u *objects.Update) {
-	h.sendHistoryChart(u, h.limits.History.Long)
+	view := h.prefs.Get(prefsKey(u)).ChartView(h.exchanges, ChartRangeLong)
+	h.sendHistoryChart(u, "longhistory", view.WithRange(ChartRangeLong))
 }
 
-func (h *CommandsHandler) sendHistoryChart(u *objects.Update, limit int) {
+func (h *CommandsHandler) sendHistoryChart(u *objects.Update, name string, view ChartView) {
+	loc := h.localizer(u)
+	prefs := h.prefs.Get(prefsKey(u))
+	tz := h.location(prefsKey(u), u.Message.Chat.Id)
 	sendHistory := func() error {
-		entries, err := h.history.Entries(limit)
+		view, err := h.parseChartArgs(newCmdArgs(u.Message.Text), view, tz)
 		if err != nil {
-			return fmt.Errorf("get entries: %w", err)
-		}
-
-		if len(entries) == 0 {
-			_ = h.bot.SendMdMessage(
+			return h.bot.SendMdMessage(
 				u.Message.Chat.Id,
-				"Sorry, history is unavailable so far",
+				h.invalidArgs(loc, err, name),
 				u.Message.MessageId,
 			)
-			return nil
 		}
 
-		graphF, err := os.CreateTemp("", "sowetty-history-*.png")
-		if err != nil {
-			return fmt.Errorf("create temporary file: %w", err)
+		stop := keepChatAction(h.bot, u.Message.Chat.Id, ChatActionUploadPhoto)
+		defer stop()
+
+		sent, err := h.sendChart(loc, tz, prefs, view, func(img *ChartImage) (string, error) {
+			return h.bot.SendMdPhotoWithButtons(
+				u.Message.Chat.Id,
+				img.Caption,
+				img.Photo(),
+				u.Message.MessageId,
+				view.Buttons(h.exchanges),
+			)
+		})
+		if errors.Is(err, errRenderBusy) {
+			return h.bot.SendMdMessage(u.Message.Chat.Id, loc.Error(err), u.Message.MessageId)
 		}
-		defer func() {
-			_ = graphF.Close()
-			_ = os.RemoveAll(graphF.Name())
-		}()
 
-		width := math.Ceil(float64(len(entries))/192) * 512
-		cfg := renderer.NewGraphConfig().
-			Width(int(width)).
-			Height(512).
-			WithSMA(true)
-		startDate, endDate, err := h.renderer.Graph(entries, graphF, cfg)
-		if err != nil {
+		if err != nil || sent {
 			return err
 		}
 
-		ms := h.bot.SendPhoto(
+		return h.bot.SendMdMessage(
 			u.Message.Chat.Id,
+			loc.T("history.unavailable"),
 			u.Message.MessageId,
-			fmt.Sprintf(
-				"`%s -> %s`",
-				startDate.Format("02 Jan 15:04 MST"),
-				endDate.Format("02 Jan 15:04 MST"),
-			),
-			tgMdMode,
 		)
-
-		graphF, err = os.Open(graphF.Name())
-		if err != nil {
-			return fmt.Errorf("open temporary file: %w", err)
-		}
-		defer func() { _ = graphF.Close() }()
-
-		_, err = ms.SendByFile(graphF, false, false)
-		return err
 	}
 
 	if err := sendHistory(); err != nil {
 		log.Error().Err(err).Int("chat_id", u.Message.Chat.Id).Msg("failed to send history")
 		_ = h.bot.SendMdMessage(
 			u.Message.Chat.Id,
-			fmt.Sprintf("ooops, shit happens: %v", err),
+			loc.T("error.generic", err),
 			u.Message.MessageId,
 		)
 	}
 }
 
-func (h *CommandsHandler) renderRates() (string, error) {
+// HandleCallback processes inline keyboard presses, it reports whether the query was recognized.
+func (h *CommandsHandler) HandleCallback(u *objects.Update) bool {
+	q := u.CallbackQuery
+	if q.Message.Chat == nil {
+		return false
+	}
+
+	switch {
+	case strings.HasPrefix(q.Data, chartCallbackPrefix):
+		h.handleChartCallback(u)
+	case strings.HasPrefix(q.Data, accessCallbackPrefix):
+		h.handleAccessCallback(u)
+	case strings.HasPrefix(q.Data, settingsCallbackPrefix):
+		h.handleSettingsCallback(u)
+	default:
+		return false
+	}
+
+	return true
+}
+
+func (h *CommandsHandler) handleChartCallback(u *objects.Update) {
+	q := u.CallbackQuery
+	loc := h.prefs.For(callbackPrefsKey(q), q.From.LanguageCode)
+
+	answer, err := func() (string, error) {
+		if !h.access.Allowed(q.Message.Chat.Id, q.From.Id) {
+			return loc.T("access.denied"), nil
+		}
+
+		if v := h.allow(q.Message.Chat.Id, q.From.Id); !v.Allowed {
+			return h.slowDown(loc, v), nil
+		}
+
+		view, err := ParseChartView(q.Data)
+		if err != nil {
+			return loc.T("chart.outdated"), nil
+		}
+
+		stop := keepChatAction(h.bot, q.Message.Chat.Id, ChatActionUploadPhoto)
+		defer stop()
+
+		prefs := h.prefs.Get(callbackPrefsKey(q))
+		tz := h.location(callbackPrefsKey(q), q.Message.Chat.Id)
+		sent, err := h.sendChart(loc, tz, prefs, view, func(img *ChartImage) (string, error) {
+			return h.bot.EditMdPhoto(q.Message.Chat.Id, q.Message.MessageId, img.Caption, img.Photo(), view.Buttons(h.exchanges))
+		})
+		if errors.Is(err, errRenderBusy) {
+			return loc.Error(err), nil
+		}
+
+		if err != nil || sent {
+			return "", err
+		}
+
+		return loc.T("chart.empty"), nil
+	}()
+
+	if err != nil {
+		log.Error().Err(err).Int("chat_id", q.Message.Chat.Id).Msg("failed to update history chart")
+		answer = loc.T("error.generic", err)
+	}
+
+	if err := h.bot.AnswerCallback(q.Id, answer); err != nil {
+		log.Error().Err(err).Int("chat_id", q.Message.Chat.Id).Msg("unable to answer callback query")
+	}
+}
+
+func (h *CommandsHandler) handleAccessCallback(u *objects.Update) {
+	q := u.CallbackQuery
+	loc := h.prefs.For(callbackPrefsKey(q), q.From.LanguageCode)
+
+	answer, err := func() (string, error) {
+		if !h.access.IsAdmin(q.From.Id) {
+			return loc.T("access.admin_only"), nil
+		}
+
+		approve, id, err := parseAccessCallbackData(q.Data)
+		if err != nil {
+			return loc.T("access.request.outdated"), nil
+		}
+
+		req, ok, err := h.access.Resolve(id, approve, q.From.Id, userName(&q.From))
+		if err != nil {
+			return "", err
+		}
+
+		if !ok {
+			return loc.T("access.request.outdated"), nil
+		}
+
+		reqLoc := i18n.NewLocalizer(req.Lang)
+		result := reqLoc.T("access.granted")
+		if !approve {
+			result = reqLoc.T("access.rejected")
+		}
+
+		if err := h.bot.SendMdMessage(req.ChatID, result, 0); err != nil {
+			log.Error().Err(err).Int("chat_id", req.ChatID).Msg("unable to notify about access")
+		}
+
+		return loc.T("access.resolved"), nil
+	}()
+
+	if err != nil {
+		log.Error().Err(err).Int("chat_id", q.Message.Chat.Id).Msg("failed to resolve access request")
+		answer = loc.T("error.generic", err)
+	}
+
+	if err := h.bot.AnswerCallback(q.Id, answer); err != nil {
+		log.Error().Err(err).Int("chat_id", q.Message.Chat.Id).Msg("unable to answer callback query")
+	}
+}
+
+func (h *CommandsHandler) handleDigest(u *objects.Update) {
+	loc := h.localizer(u)
+	reply, err := func() (string, error) {
+		args := newCmdArgs(u.Message.Text)
+		if args.Len() == 0 {
+			sub, ok := h.digester.Subscription(u.Message.Chat.Id)
+			if !ok {
+				return loc.T("digest.disabled", h.usage(loc, "digest")), nil
+			}
+
+			return loc.T("digest.scheduled", sub.Describe(loc)), nil
+		}
+
+		cmd := args.Peek()
+		if cmd != "now" && !h.requireAdmin(u) {
+			return "", nil
+		}
+
+		if cmd == "off" || cmd == "now" {
+			args.Shift()
+			if err := args.Done(); err != nil {
+				return h.invalidArgs(loc, err, "digest"), nil
+			}
+		}
+
+		switch cmd {
+		case "off":
+			ok, err := h.digester.Unsubscribe(u.Message.Chat.Id)
+			if err != nil {
+				return "", err
+			}
+
+			if !ok {
+				return loc.T("digest.already_disabled"), nil
+			}
+			return loc.T("digest.off"), nil
+		case "now":
+			sub, ok := h.digester.Subscription(u.Message.Chat.Id)
+			if !ok {
+				// the preferred time zone, the default one otherwise
+				sub = DigestSubscription{
+					ChatID:   u.Message.Chat.Id,
+					Period:   DigestPeriodDaily,
+					Timezone: h.location(prefsKey(u), u.Message.Chat.Id).String(),
+					Lang:     loc.Lang(),
+				}
+			}
+
+			return "", h.digester.Send(sub)
+		}
+
+		sub, err := ParseDigestSubscription(args, h.digester.defaultTZ)
+		if err != nil {
+			return h.invalidArgs(loc, err, "digest"), nil
+		}
+
+		sub.ChatID = u.Message.Chat.Id
+		sub.Lang = loc.Lang()
+		if err := h.digester.Subscribe(sub); err != nil {
+			return "", err
+		}
+
+		return loc.T("digest.scheduled", sub.Describe(loc)), nil
+	}()
+
+	if err != nil {
+		reply = loc.T("error.generic", err)
+		log.Error().Err(err).Int("chat_id", u.Message.Chat.Id).Msg("failed to handle digest")
+	}
+
+	if reply == "" {
+		return
+	}
+
+	err = h.bot.SendMdMessage(u.Message.Chat.Id, reply, u.Message.MessageId)
+	if err != nil {
+		log.Error().Err(err).Int("chat_id", u.Message.Chat.Id).Msg("unable to send reply")
+	}
+}
+
+func (h *CommandsHandler) handleRule(u *objects.Update) {
+	loc := h.localizer(u)
+	reply, err := func() (string, error) {
+		args := newCmdArgs(u.Message.Text)
+		cmd := args.Shift()
+		if cmd == "" {
+			cmd = "list"
+		}
+
+		chatID := u.Message.Chat.Id
+		switch cmd {
+		case "list":
+			if err := args.Done(); err != nil {
+				return h.invalidArgs(loc, err, "rule"), nil
+			}
+
+			chatRules := h.notifier.ChatRules(chatID)
+			if len(chatRules) == 0 {
+				return loc.T("rule.empty", h.usage(loc, "rule")), nil
+			}
+
+			var out strings.Builder
+			out.WriteString(loc.N("rule.count", len(chatRules)))
+			out.WriteString("\n```\n")
+			for i, rule := range chatRules {
+				_, _ = fmt.Fprintf(&out, "%d. %s\n", i+1, renderer.EscapeTgMdCode(rule))
+			}
+			out.WriteString("```")
+			return out.String(), nil
+		case "add":
+			if !h.requireAdmin(u) {
+				return "", nil
+			}
+
+			expr := strings.Join(args.Rest(), " ")
+			err := h.notifier.AddChatRule(chatID, expr)
+			var ruleErr *rules.Error
+			if errors.As(err, &ruleErr) {
+				return loc.T(
+					"rule.invalid",
+					renderer.EscapeTgMdCode(ruleErr.Pointer(expr)),
+					renderer.EscapeTgMdCode(ruleErr.Error()),
+				), nil
+			}
+
+			if errors.Is(err, errTooManyRules) {
+				return loc.Error(err), nil
+			}
+
+			if err != nil {
+				return "", err
+			}
+			return loc.T("rule.added", renderer.EscapeTgMdCode(expr)), nil
+		case "del", "rm":
+			if !h.requireAdmin(u) {
+				return "", nil
+			}
+
+			if args.Len() == 0 {
+				return loc.T("rule.number_required", h.usage(loc, "rule")), nil
+			}
+
+			arg := args.Shift()
+			idx, err := strconv.Atoi(arg)
+			if err != nil || idx < 1 {
+				args.Fail(i18n.Errorf("rule.invalid_number", arg))
+			}
+
+			if err := args.Done(); err != nil {
+				return h.invalidArgs(loc, err, "rule"), nil
+			}
+
+			ok, err := h.notifier.RemoveChatRule(chatID, idx-1)
+			if err != nil {
+				return "", err
+			}
+
+			if !ok {
+				return loc.T("rule.not_found", idx), nil
+			}
+			return loc.T("rule.removed", idx), nil
+		default:
+			return loc.T("rule.unknown", h.usage(loc, "rule")), nil
+		}
+	}()
+
+	if err != nil {
+		reply = loc.T("error.generic", err)
+		log.Error().Err(err).Int("chat_id", u.Message.Chat.Id).Msg("failed to handle rule")
+	}
+
+	if reply == "" {
+		return
+	}
+
+	err = h.bot.SendMdMessage(u.Message.Chat.Id, reply, u.Message.MessageId)
+	if err != nil {
+		log.Error().Err(err).Int("chat_id", u.Message.Chat.Id).Msg("unable to send reply")
+	}
+}
+
+func (h *CommandsHandler) handleLive(u *objects.Update) {
+	loc := h.localizer(u)
+	reply, err := func() (string, error) {
+		if !h.requireAdmin(u) {
+			return "", nil
+		}
+
+		args := newCmdArgs(u.Message.Text)
+		_, off := args.Keyword("off")
+		if err := args.Done(); err != nil {
+			return h.invalidArgs(loc, err, "live"), nil
+		}
+
+		if !off {
+			return "", h.live.Start(u.Message.Chat.Id, loc.Lang())
+		}
+
+		ok, err := h.live.Stop(u.Message.Chat.Id)
+		if err != nil {
+			return "", err
+		}
+
+		if !ok {
+			return loc.T("live.already_disabled"), nil
+		}
+		return loc.T("live.off"), nil
+	}()
+
+	if err != nil {
+		reply = loc.T("error.generic", err)
+		log.Error().Err(err).Int("chat_id", u.Message.Chat.Id).Msg("failed to handle live")
+	}
+
+	if reply == "" {
+		return
+	}
+
+	err = h.bot.SendMdMessage(u.Message.Chat.Id, reply, u.Message.MessageId)
+	if err != nil {
+		log.Error().Err(err).Int("chat_id", u.Message.Chat.Id).Msg("unable to send reply")
+	}
+}
+
+func (h *CommandsHandler) handleBest(u *objects.Update) {
+	loc := h.localizer(u)
+	reply, err := func() (string, error) {
+		args := newCmdArgs(u.Message.Text)
+		amount, ok := args.Amount()
+		if err := args.Done(); err != nil {
+			return h.invalidArgs(loc, err, "best"), nil
+		}
+
+		if !ok {
+			amount = h.best.ReferenceAmount
+		}
+
+		quotes, err := h.freshQuotes(h.favourites(h.prefs.Get(prefsKey(u))))
+		if err != nil {
+			return "", err
+		}
+
+		if len(quotes) == 0 {
+			return loc.T("best.unavailable"), nil
+		}
+
+		return h.renderer.For(loc, h.location(prefsKey(u), u.Message.Chat.Id)).Best(rankQuotes(quotes, amount, h.best.MaxAge, time.Now()))
+	}()
+
+	if err != nil {
+		reply = loc.T("error.generic", err)
+		log.Error().Err(err).Int("chat_id", u.Message.Chat.Id).Msg("failed to rank exchanges")
+	}
+
+	err = h.bot.SendMdMessage(u.Message.Chat.Id, reply, u.Message.MessageId)
+	if err != nil {
+		log.Error().Err(err).Int("chat_id", u.Message.Chat.Id).Msg("unable to send reply")
+	}
+}
+
+func (h *CommandsHandler) handleStats(u *objects.Update) {
+	loc := h.localizer(u)
+	reply, err := func() (string, error) {
+		args := newCmdArgs(u.Message.Text)
+		tz := h.location(prefsKey(u), u.Message.Chat.Id)
+		r, ok := args.TimeRange(tz)
+		if !ok {
+			r = TimeRange{Period: defaultStatsPeriod}
+		}
+
+		ex, _ := args.Exchange(h.exchanges)
+		if err := args.Done(); err != nil {
+			return h.invalidArgs(loc, err, "stats"), nil
+		}
+
+		from, to := r.Bounds(time.Now())
+		out, err := h.buildStats(from, to, ex.Slug)
+		if err != nil {
+			return "", err
+		}
+
+		if len(out.Exchanges) == 0 {
+			return loc.T("history.unavailable"), nil
+		}
+
+		return h.renderer.For(loc, tz).Stats(out)
+	}()
+
+	if err != nil {
+		reply = loc.T("error.generic", err)
+		log.Error().Err(err).Int("chat_id", u.Message.Chat.Id).Msg("failed to calculate stats")
+	}
+
+	err = h.bot.SendMdMessage(u.Message.Chat.Id, reply, u.Message.MessageId)
+	if err != nil {
+		log.Error().Err(err).Int("chat_id", u.Message.Chat.Id).Msg("unable to send reply")
+	}
+}
+
+func (h *CommandsHandler) handleLang(u *objects.Update) {
+	reply, err := func() (string, error) {
+		args := newCmdArgs(u.Message.Text)
+		if args.Len() == 0 {
+			loc := h.localizer(u)
+			return loc.T("lang.current", loc.T("lang.name")) + "\n" + loc.T("args.usage", h.usage(loc, "lang")), nil
+		}
+
+		code := args.Shift()
+		if err := args.Done(); err != nil {
+			return h.invalidArgs(h.localizer(u), err, "lang"), nil
+		}
+
+		if code == "auto" {
+			_, err := h.prefs.Update(prefsKey(u), func(p *Preferences) { p.Lang = "" })
+			if err != nil {
+				return "", err
+			}
+
+			return h.localizer(u).T("lang.auto"), nil
+		}
+
+		lang, ok := i18n.ParseLang(code)
+		if !ok {
+			available := make([]string, len(i18n.Langs))
+			for i, l := range i18n.Langs {
+				available[i] = string(l)
+			}
+
+			return h.localizer(u).T("lang.unknown", code, strings.Join(available, ", ")), nil
+		}
+
+		_, err := h.prefs.Update(prefsKey(u), func(p *Preferences) { p.Lang = lang })
+		if err != nil {
+			return "", err
+		}
+
+		loc := i18n.NewLocalizer(lang)
+		return loc.T("lang.set", loc.T("lang.name")), nil
+	}()
+
+	if err != nil {
+		reply = h.localizer(u).T("error.generic", err)
+		log.Error().Err(err).Int("chat_id", u.Message.Chat.Id).Msg("failed to handle lang")
+	}
+
+	if reply == "" {
+		return
+	}
+
+	err = h.bot.SendMdMessage(u.Message.Chat.Id, reply, u.Message.MessageId)
+	if err != nil {
+		log.Error().Err(err).Int("chat_id", u.Message.Chat.Id).Msg("unable to send reply")
+	}
+}
+
+func (h *CommandsHandler) handleAccess(u *objects.Update) {
+	loc := h.localizer(u)
+	reply, err := func() (string, error) {
+		args := newCmdArgs(u.Message.Text)
+		cmd := args.Shift()
+		if cmd == "" || cmd == "list" {
+			if err := args.Done(); err != nil {
+				return h.invalidArgs(loc, err, "access"), nil
+			}
+
+			return h.describeAccess(loc), nil
+		}
+
+		roles := map[string]AccessRole{
+			"allow": AccessRoleUser,
+			"admin": AccessRoleAdmin,
+			"block": AccessRoleBlocked,
+		}
+		role, isSet := roles[cmd]
+		if !isSet && cmd != "remove" {
+			args.Fail(i18n.Errorf("access.err.action", cmd))
+		}
+
+		id, ok := args.ID()
+		if !ok {
+			args.Fail(i18n.Errorf("access.err.id_required"))
+		}
+
+		if err := args.Done(); err != nil {
+			return h.invalidArgs(loc, err, "access"), nil
+		}
+
+		if !isSet {
+			ok, err := h.access.Remove(id)
+			if err != nil {
+				return loc.Error(err), nil
+			}
+
+			if !ok {
+				return loc.T("access.not_found", id), nil
+			}
+			return loc.T("access.removed", id), nil
+		}
+
+		if err := h.access.Set(id, role, senderID(u)); err != nil {
+			return loc.Error(err), nil
+		}
+
+		return loc.T("access.set", id, loc.T("access.role."+string(role))), nil
+	}()
+
+	if err != nil {
+		reply = loc.T("error.generic", err)
+		log.Error().Err(err).Int("chat_id", u.Message.Chat.Id).Msg("failed to handle access")
+	}
+
+	err = h.bot.SendMdMessage(u.Message.Chat.Id, reply, u.Message.MessageId)
+	if err != nil {
+		log.Error().Err(err).Int("chat_id", u.Message.Chat.Id).Msg("unable to send reply")
+	}
+}
+
+func (h *CommandsHandler) describeAccess(loc *i18n.Localizer) string {
+	var out strings.Builder
+	if h.access.Enabled() {
+		out.WriteString(loc.T("access.status.enabled"))
+	} else {
+		out.WriteString(loc.T("access.status.disabled"))
+	}
+
+	out.WriteString("\n\n")
+	out.WriteString(loc.T("access.entries"))
+	for _, entry := range h.access.Entries() {
+		_, _ = fmt.Fprintf(&out, "\n`%d` %s", entry.ID, loc.T("access.role."+string(entry.Role)))
+		if entry.Name != "" {
+			_, _ = fmt.Fprintf(&out, " - %s", renderer.EscapeTgMdCode(entry.Name))
+		}
+
+		if entry.Config {
+			_, _ = fmt.Fprintf(&out, " (%s)", loc.T("access.config"))
+		}
+	}
+
+	if pending := h.access.PendingRequests(); len(pending) > 0 {
+		out.WriteString("\n\n")
+		out.WriteString(loc.T("access.pending_list"))
+		for _, req := range pending {
+			_, _ = fmt.Fprintf(&out, "\n`%d` %s, %s", req.ID, renderer.EscapeTgMdCode(req.Name), loc.Time(req.RequestedAt, "layout.datetime"))
+		}
+	}
+
+	return out.String()
+}
+
+// rateLimitMiddleware asks users to slow down once they run out of tokens, further commands are ignored silently.
+func (h *CommandsHandler) rateLimitMiddleware(next func(*objects.Update)) func(*objects.Update) {
+	return func(u *objects.Update) {
+		v := h.allow(u.Message.Chat.Id, senderID(u))
+		if v.Allowed {
+			next(u)
+			return
+		}
+
+		log.Warn().Int("chat_id", u.Message.Chat.Id).Int("user_id", senderID(u)).Msg("command rate limited")
+		if v.First {
+			h.reply(u, h.slowDown(h.localizer(u), v))
+		}
+	}
+}
+
+// allow takes a token for the user and the chat, the user bucket goes first.
+func (h *CommandsHandler) allow(chatID int, userID int) ratelimit.Verdict {
+	if userID != 0 {
+		if v := h.userLimits.Allow(userID); !v.Allowed {
+			return v
+		}
+	}
+
+	if chatID == userID {
+		// private chats are limited per user already
+		return ratelimit.Verdict{Allowed: true}
+	}
+
+	return h.chatLimits.Allow(chatID)
+}
+
+func (h *CommandsHandler) slowDown(loc *i18n.Localizer, v ratelimit.Verdict) string {
+	return loc.N("ratelimit.slow_down", int(math.Ceil(v.RetryAfter.Seconds())))
+}
+
+// accessMiddleware lets allowed users and chats through, newcomers are asked to wait for approval.
+func (h *CommandsHandler) accessMiddleware(cmd Command, next func(*objects.Update)) func(*objects.Update) {
+	return func(u *objects.Update) {
+		switch {
+		case cmd.Visibility == CommandBotAdmin:
+			if !h.access.IsAdmin(senderID(u)) {
+				h.reply(u, h.localizer(u).T("access.admin_only"))
+				return
+			}
+		case cmd.Public, h.access.Allowed(u.Message.Chat.Id, senderID(u)):
+		default:
+			h.requestAccess(u)
+			return
+		}
+
+		next(u)
+	}
+}
+
+// requestAccess asks bot admins to let the user in for private chats, or the whole chat otherwise.
+func (h *CommandsHandler) requestAccess(u *objects.Update) {
+	loc := h.localizer(u)
+	req := AccessRequest{
+		ID:     u.Message.Chat.Id,
+		Name:   u.Message.Chat.Title,
+		ChatID: u.Message.Chat.Id,
+		Lang:   loc.Lang(),
+	}
+	if u.Message.Chat.Type == "private" && u.Message.From != nil {
+		req.ID = u.Message.From.Id
+		req.Name = userName(u.Message.From)
+	}
+
+	reply := func() string {
+		if h.access.Blocked(req.ID) {
+			return loc.T("access.denied")
+		}
+
+		created, err := h.access.Request(req)
+		if err != nil {
+			log.Error().Err(err).Int("chat_id", u.Message.Chat.Id).Msg("unable to request access")
+			return loc.T("access.denied")
+		}
+
+		if !created {
+			return loc.T("access.pending")
+		}
+
+		log.Info().Int("id", req.ID).Str("name", req.Name).Msg("access requested")
+		return loc.T("access.requested")
+	}()
+
+	h.reply(u, reply)
+}
+
+func (h *CommandsHandler) reply(u *objects.Update, text string) {
+	err := h.bot.SendMdMessage(u.Message.Chat.Id, text, u.Message.MessageId)
+	if err != nil {
+		log.Error().Err(err).Int("chat_id", u.Message.Chat.Id).Msg("unable to send reply")
+	}
+}
+
+// senderID returns the message author, zero for channel posts.
+func senderID(u *objects.Update) int {
+	if u.Message.From == nil {
+		return 0
+	}
+
+	return u.Message.From.Id
+}
+
+func userName(user *objects.User) string {
+	name := strings.TrimSpace(user.FirstName + " " + user.Lastname)
+	if user.Username != "" {
+		name += " @" + user.Username
+	}
+
+	return name
+}
+
+// location is the time zone to read dates in: the preferred one, the chat digest one or the default.
+func (h *CommandsHandler) location(prefsKey int, chatID int) *time.Location {
+	if loc := h.prefs.Location(prefsKey); loc != nil {
+		return loc
+	}
+
+	return h.digester.Location(chatID)
+}
+
+// favourites returns exchanges the chat prefers, in config order.
+func (h *CommandsHandler) favourites(prefs Preferences) []config.Exchange {
+	out := make([]config.Exchange, 0, len(h.exchanges))
+	for _, ex := range h.exchanges {
+		if prefs.IsFavourite(ex.Slug) {
+			out = append(out, ex)
+		}
+	}
+
+	return out
+}
+
+// fetchRates gets rates of the exchanges at once, onRate is called as each of them arrives.
+func (h *CommandsHandler) fetchRates(exchanges []config.Exchange, onRate func(idx int, err error)) models.Rates {
 	var wg sync.WaitGroup
-	wg.Add(len(h.exchanges))
-	rates := make(models.Rates, len(h.exchanges))
-	for i, ex := range h.exchanges {
+	wg.Add(len(exchanges))
+	rates := make(models.Rates, len(exchanges))
+	for i, ex := range exchanges {
 		go func(i int, ex config.Exchange) {
 			defer wg.Done()
 
 			cached := h.ratesCache.Get(ex.Route)
 			if cached != nil && !cached.IsExpired() {
 				rates[i] = cached.Value()
+				if onRate != nil {
+					onRate(i, nil)
+				}
 				return
 			}
 
@@ -207,6 +1110,10 @@ func (h *CommandsHandler) renderRates() (string, error) {
 				log.Error().Err(err).Str("route", ex.Route).Msg("unable to fetch rates")
 			}
 
+			if onRate != nil {
+				defer onRate(i, err)
+			}
+
 			rate.Name = ex.Name
 
 			h.ratesCache.Set(ex.Route, rate, ttlcache.DefaultTTL)
@@ -215,12 +1122,7 @@ func (h *CommandsHandler) renderRates() (string, error) {
 	}
 	wg.Wait()
 
-	reply, err := h.renderer.Rates(rates)
-	if err != nil {
-		return fmt.Sprintf("Sotty, shit happens: %v", err), nil
-	}
-
-	return reply, nil
+	return rates
 }
 
 func (h *CommandsHandler) panicMiddleware(name string, next func(*objects.Update)) func(*objects.Update) {
@@ -234,7 +1136,7 @@ func (h *CommandsHandler) panicMiddleware(name string, next func(*objects.Update
 
 				err := h.bot.SendMdMessage(
 					u.Message.Chat.Id,
-					fmt.Sprintf("ooops:\n```\n%+v\n```", err),
+					h.localizer(u).T("error.panic", err),
 					u.Message.MessageId,
 				)
 				if err != nil {
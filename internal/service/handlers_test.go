@@ -0,0 +1,31 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseCommand(t *testing.T) {
+	cases := []struct {
+		in      string
+		cmd     string
+		mention string
+		ok      bool
+	}{
+		{in: "/rates", cmd: "/rates", ok: true},
+		{in: "/Rates@SowettyBot 3d", cmd: "/rates", mention: "SowettyBot", ok: true},
+		{in: "  /rule add korona < 3", cmd: "/rule", ok: true},
+		{in: "hello", ok: false},
+		{in: "", ok: false},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.in, func(t *testing.T) {
+			cmd, mention, ok := parseCommand(tc.in)
+			require.Equal(t, tc.ok, ok)
+			require.Equal(t, tc.cmd, cmd)
+			require.Equal(t, tc.mention, mention)
+		})
+	}
+}
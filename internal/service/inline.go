@@ -0,0 +1,267 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/SakoDroid/telego/objects"
+	"github.com/rs/zerolog/log"
+
+	"github.com/buglloc/sowettybot/internal/i18n"
+	"github.com/buglloc/sowettybot/internal/models"
+	"github.com/buglloc/sowettybot/internal/ratelimit"
+)
+
+const inlineCacheTime = 60
+
+// HandleInline answers inline queries: "rates", an amount to convert or "chart [all|period|dates] [exchange]".
+func (h *CommandsHandler) HandleInline(u *objects.Update) {
+	q := u.InlineQuery
+	loc := i18n.NewLocalizer(i18n.LangEN)
+	tz := time.Local
+	var userID int
+	if q.From != nil {
+		userID = q.From.Id
+		loc = h.prefs.For(userID, q.From.LanguageCode)
+		tz = h.location(userID, userID)
+	}
+
+	results := []InlineResult{
+		{
+			ID:    "access",
+			Title: loc.T("access.inline.title"),
+			Text:  loc.T("access.denied"),
+		},
+	}
+	var err error
+	if h.access.Allowed(0, userID) {
+		results, err = h.inlineResults(loc, userID, h.prefs.Get(userID), tz, &cmdArgs{args: strings.Fields(q.Query)})
+	}
+
+	cacheTime := inlineCacheTime
+	var limited *inlineLimitedError
+	switch {
+	case errors.As(err, &limited):
+		// not cached, the same query is answered once the user has tokens again
+		log.Warn().Int("user_id", userID).Msg("inline chart rate limited")
+		cacheTime = 0
+		results = []InlineResult{
+			{
+				ID:    "ratelimit",
+				Title: loc.T("inline.error.title"),
+				Text:  h.slowDown(loc, limited.verdict),
+			},
+		}
+	case err != nil:
+		log.Error().Err(err).Str("query", q.Query).Msg("failed to build inline results")
+		results = []InlineResult{
+			{
+				ID:    "error",
+				Title: loc.T("inline.error.title"),
+				Text:  loc.T("error.generic", loc.Error(err)),
+			},
+		}
+	}
+
+	if err := h.bot.AnswerInline(q.Id, cacheTime, h.access.Enabled(), results); err != nil {
+		log.Error().Err(err).Str("query", q.Query).Msg("unable to answer inline query")
+	}
+}
+
+// inlineLimitedError is returned when the user is out of tokens for a new inline chart.
+type inlineLimitedError struct {
+	verdict ratelimit.Verdict
+}
+
+func (e *inlineLimitedError) Error() string {
+	return "inline chart rate limited"
+}
+
+func (h *CommandsHandler) inlineResults(loc *i18n.Localizer, userID int, prefs Preferences, tz *time.Location, args *cmdArgs) ([]InlineResult, error) {
+	switch cmd, _ := args.Keyword("rates", "chart", "history"); cmd {
+	case "rates":
+		return h.inlineRates(loc, tz, prefs)
+	case "chart", "history":
+		view, err := h.parseChartArgs(args, prefs.ChartView(h.exchanges, ChartRangeWeek), tz)
+		if err != nil {
+			return nil, err
+		}
+
+		return h.inlineChart(loc, userID, tz, prefs, view)
+	}
+
+	amount, ok := args.Amount()
+	if !ok || args.Done() != nil {
+		return h.inlineRates(loc, tz, prefs)
+	}
+
+	return h.inlineConversion(loc, tz, prefs, amount)
+}
+
+// cachedRates takes the rates from the cache or the last history entry, unlike fetchRates it never asks
+// the exchanges: queries come on every keystroke.
+func (h *CommandsHandler) cachedRates(prefs Preferences) (models.Rates, error) {
+	quotes, err := h.freshQuotes(h.favourites(prefs))
+	if err != nil {
+		return nil, err
+	}
+
+	if len(quotes) == 0 {
+		return nil, i18n.Errorf("inline.convert.err.no_rates")
+	}
+
+	rates := make(models.Rates, len(quotes))
+	for i, q := range quotes {
+		rates[i] = models.Rate{
+			Name: q.Name,
+			When: q.When,
+			Rate: q.Rate,
+		}
+	}
+
+	return rates, nil
+}
+
+func (h *CommandsHandler) inlineRates(loc *i18n.Localizer, tz *time.Location, prefs Preferences) ([]InlineResult, error) {
+	rates, err := h.cachedRates(prefs)
+	if err != nil {
+		return nil, err
+	}
+
+	text, err := h.renderer.For(loc, tz).Rates(rates)
+	if err != nil {
+		return nil, err
+	}
+
+	return []InlineResult{
+		{
+			ID:          "rates",
+			Title:       loc.T("inline.rates.title"),
+			Description: loc.T("inline.rates.description"),
+			Text:        text,
+		},
+	}, nil
+}
+
+func (h *CommandsHandler) inlineConversion(loc *i18n.Localizer, tz *time.Location, prefs Preferences, amount float64) ([]InlineResult, error) {
+	rates, err := h.cachedRates(prefs)
+	if err != nil {
+		return nil, err
+	}
+
+	conv := models.Conversion{
+		Amount: amount,
+	}
+
+	for _, rate := range rates {
+		if rate.Rate == 0 {
+			continue
+		}
+
+		conv.Exchanges = append(conv.Exchanges, models.ConversionExchange{
+			Name: rate.Name,
+			Rate: rate.Rate,
+			Pay:  amount * rate.Rate,
+			Get:  amount / rate.Rate,
+		})
+	}
+
+	if len(conv.Exchanges) == 0 {
+		return nil, i18n.Errorf("inline.convert.err.no_rates")
+	}
+
+	text, err := h.renderer.For(loc, tz).Conversion(conv)
+	if err != nil {
+		return nil, err
+	}
+
+	return []InlineResult{
+		{
+			ID:          "convert:" + strconv.FormatFloat(amount, 'f', -1, 64),
+			Title:       loc.T("inline.convert.title", loc.Float(amount, -1)),
+			Description: loc.T("inline.convert.description"),
+			Text:        text,
+		},
+	}, nil
+}
+
+func (h *CommandsHandler) inlineChart(loc *i18n.Localizer, userID int, tz *time.Location, prefs Preferences, view ChartView) ([]InlineResult, error) {
+	if !h.charts.Enabled() {
+		return []InlineResult{
+			{
+				ID:          "chart:disabled",
+				Title:       loc.T("inline.chart.disabled.title"),
+				Description: loc.T("inline.chart.disabled.describe"),
+				Text:        loc.T("inline.chart.disabled.text"),
+			},
+		}, nil
+	}
+
+	result := func(fileID string, until time.Time) []InlineResult {
+		return []InlineResult{
+			{
+				ID:          view.String(),
+				Title:       loc.T("inline.chart.title", view.Range.Describe(loc, tz)),
+				Description: loc.T("inline.chart.description", loc.Time(until.In(tz), "layout.datetime")),
+				PhotoFileID: fileID,
+			},
+		}
+	}
+
+	// Telegram keeps the answers for inlineCacheTime anyway, a chart checked that recently is taken as is
+	if cached, ok := h.charts.Recent(chartKey(loc, tz, prefs, view), inlineCacheTime*time.Second); ok {
+		return result(cached.fileID, cached.until), nil
+	}
+
+	// queries come on every keystroke, the rest is served from the caches,
+	// so only the charts that may need a render take the user's tokens
+	if v := h.userLimits.Allow(userID); !v.Allowed {
+		return nil, &inlineLimitedError{verdict: v}
+	}
+
+	// inline results take file_ids only, so new charts go through the media chat
+	var fileID string
+	var until time.Time
+	sent, err := h.sendChart(loc, tz, prefs, view, func(img *ChartImage) (string, error) {
+		fileID, until = img.FileID, img.Until
+		if fileID != "" {
+			return fileID, nil
+		}
+
+		var err error
+		fileID, err = h.bot.UploadPhoto(h.charts.mediaChatID, img.Path)
+		if err != nil {
+			return "", fmt.Errorf("upload chart: %w", err)
+		}
+
+		return fileID, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if !sent {
+		return nil, i18n.Errorf("inline.chart.err.no_history")
+	}
+
+	return result(fileID, until), nil
+}
+
+// parseAmount accepts "50000", "50 000", "1,5" and "1.5"
+func parseAmount(in string) (float64, error) {
+	in = strings.ReplaceAll(in, " ", "")
+	in = strings.ReplaceAll(in, ",", ".")
+	amount, err := strconv.ParseFloat(in, 64)
+	if err != nil {
+		return 0, err
+	}
+
+	if amount <= 0 {
+		return 0, i18n.Errorf("inline.convert.err.non_positive")
+	}
+
+	return amount, nil
+}
@@ -0,0 +1,296 @@
+package service
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/buglloc/sowettybot/internal/config"
+	"github.com/buglloc/sowettybot/internal/history"
+	"github.com/buglloc/sowettybot/internal/i18n"
+	"github.com/buglloc/sowettybot/internal/models"
+	"github.com/buglloc/sowettybot/internal/renderer"
+	"github.com/buglloc/sowettybot/internal/storage"
+)
+
+const (
+	liveStateName = "live"
+	// liveRefreshPeriod forces an edit even without new entries, so the "updated ago" stays fresh.
+	liveRefreshPeriod = 5 * time.Minute
+)
+
+type LiveBoard struct {
+	ChatID    int       `json:"chat_id"`
+	MessageID int       `json:"message_id"`
+	LastEntry time.Time `json:"last_entry"`
+	LastEdit  time.Time `json:"last_edit"`
+	// Lang is the language of the user who started the board, the chat /lang override wins
+	Lang i18n.Lang `json:"lang"`
+}
+
+type LiveBoards struct {
+	bot       Messenger
+	history   *history.History
+	renderer  *renderer.HistoryRenderer
+	storage   *storage.Storage
+	exchanges []config.Exchange
+	prefs     *PreferenceStore
+	digester  *Digester
+	mu        sync.Mutex
+	boards    map[int]LiveBoard
+}
+
+func (l *LiveBoards) Initialize() error {
+	var boards []LiveBoard
+	if err := l.storage.Load(liveStateName, &boards); err != nil {
+		return fmt.Errorf("unable to load live boards: %w", err)
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.boards = make(map[int]LiveBoard, len(boards))
+	for _, board := range boards {
+		l.boards[board.ChatID] = board
+	}
+
+	return nil
+}
+
+func (l *LiveBoards) Board(chatID int) (LiveBoard, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	board, ok := l.boards[chatID]
+	return board, ok
+}
+
+// Start posts a new live board into the chat and pins it, replacing the previous one.
+func (l *LiveBoards) Start(chatID int, lang i18n.Lang) error {
+	now := time.Now()
+	text, lastEntry, err := l.render(l.prefs.For(chatID, string(lang)), chatLocation(l.prefs, l.digester, chatID), now)
+	if err != nil {
+		return err
+	}
+
+	msgID, err := l.bot.PostMdMessage(chatID, text, 0)
+	if err != nil {
+		return fmt.Errorf("send live board: %w", err)
+	}
+
+	if err := l.bot.PinMessage(chatID, msgID); err != nil {
+		log.Warn().Err(err).Int("chat_id", chatID).Msg("unable to pin live board")
+	}
+
+	// sends may wait for the send rate, so the previous board is unpinned without holding the lock
+	l.mu.Lock()
+	prev, hasPrev := l.boards[chatID]
+	l.boards[chatID] = LiveBoard{
+		ChatID:    chatID,
+		MessageID: msgID,
+		LastEntry: lastEntry,
+		LastEdit:  now,
+		Lang:      lang,
+	}
+	err = l.lockedSave()
+	l.mu.Unlock()
+
+	if hasPrev {
+		if err := l.bot.UnpinMessage(chatID, prev.MessageID); err != nil {
+			log.Warn().Err(err).Int("chat_id", chatID).Msg("unable to unpin previous live board")
+		}
+	}
+
+	return err
+}
+
+func (l *LiveBoards) Stop(chatID int) (bool, error) {
+	l.mu.Lock()
+	board, ok := l.boards[chatID]
+	if !ok {
+		l.mu.Unlock()
+		return false, nil
+	}
+
+	delete(l.boards, chatID)
+	err := l.lockedSave()
+	l.mu.Unlock()
+
+	if err := l.bot.UnpinMessage(chatID, board.MessageID); err != nil {
+		log.Warn().Err(err).Int("chat_id", chatID).Msg("unable to unpin live board")
+	}
+
+	return true, err
+}
+
+// Forget drops the chat board without touching the message, e.g. when the bot left the chat.
+func (l *LiveBoards) Forget(chatID int) (bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if _, ok := l.boards[chatID]; !ok {
+		return false, nil
+	}
+
+	delete(l.boards, chatID)
+	return true, l.lockedSave()
+}
+
+// Tick edits every board that has a newer history entry or hasn't been touched for a while.
+// Boards are edited without holding the lock, boards replaced or stopped meanwhile are left as is.
+func (l *LiveBoards) Tick() {
+	l.mu.Lock()
+	boards := make([]LiveBoard, 0, len(l.boards))
+	for _, board := range l.boards {
+		boards = append(boards, board)
+	}
+	l.mu.Unlock()
+
+	if len(boards) == 0 {
+		return
+	}
+
+	type rendered struct {
+		text      string
+		lastEntry time.Time
+	}
+
+	type renderKey struct {
+		lang i18n.Lang
+		tz   string
+	}
+
+	now := time.Now()
+	texts := make(map[renderKey]rendered)
+	var edited, gone []LiveBoard
+	for _, board := range boards {
+		loc := l.prefs.For(board.ChatID, string(board.Lang))
+		tz := chatLocation(l.prefs, l.digester, board.ChatID)
+		key := renderKey{lang: loc.Lang(), tz: tz.String()}
+		r, ok := texts[key]
+		if !ok {
+			text, lastEntry, err := l.render(loc, tz, now)
+			if err != nil {
+				log.Error().Err(err).Msg("unable to render live board")
+				return
+			}
+
+			r = rendered{text: text, lastEntry: lastEntry}
+			texts[key] = r
+		}
+
+		if !r.lastEntry.After(board.LastEntry) && now.Sub(board.LastEdit) < liveRefreshPeriod {
+			continue
+		}
+
+		err := l.bot.EditMdMessage(board.ChatID, board.MessageID, r.text)
+		switch {
+		case isMessageGone(err):
+			log.Info().Err(err).Int("chat_id", board.ChatID).Msg("live board is gone, stop editing")
+			gone = append(gone, board)
+		case err != nil:
+			log.Error().Err(err).Int("chat_id", board.ChatID).Msg("unable to edit live board")
+		default:
+			board.LastEntry = r.lastEntry
+			board.LastEdit = now
+			edited = append(edited, board)
+		}
+	}
+
+	if len(edited) == 0 && len(gone) == 0 {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	changed := false
+	for _, board := range edited {
+		if cur, ok := l.boards[board.ChatID]; ok && cur.MessageID == board.MessageID {
+			l.boards[board.ChatID] = board
+			changed = true
+		}
+	}
+
+	for _, board := range gone {
+		if cur, ok := l.boards[board.ChatID]; ok && cur.MessageID == board.MessageID {
+			delete(l.boards, board.ChatID)
+			changed = true
+		}
+	}
+
+	if !changed {
+		return
+	}
+
+	if err := l.lockedSave(); err != nil {
+		log.Error().Err(err).Msg("unable to save live boards")
+	}
+}
+
+func (l *LiveBoards) render(loc *i18n.Localizer, tz *time.Location, now time.Time) (string, time.Time, error) {
+	entries, err := l.history.Entries(2)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("get entries: %w", err)
+	}
+
+	if len(entries) == 0 {
+		return loc.T("history.unavailable"), time.Time{}, nil
+	}
+
+	last := entries[len(entries)-1]
+	prev := make(map[string]float64)
+	if len(entries) > 1 {
+		p := entries[len(entries)-2]
+		for i, name := range p.Names {
+			prev[name] = p.Values[i]
+		}
+	}
+
+	board := models.LiveBoard{
+		When: last.When,
+		Age:  now.Sub(last.When),
+	}
+	for i, name := range last.Names {
+		if last.Values[i] == 0 {
+			continue
+		}
+
+		ex := models.LiveExchange{
+			Name: exchangeName(l.exchanges, name),
+			Rate: last.Values[i],
+		}
+		if p, ok := prev[name]; ok && p != 0 {
+			ex.Change = last.Values[i] - p
+		}
+
+		board.Exchanges = append(board.Exchanges, ex)
+	}
+
+	text, err := l.renderer.For(loc, tz).Live(board)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("render live board: %w", err)
+	}
+
+	return text, last.When, nil
+}
+
+func (l *LiveBoards) lockedSave() error {
+	boards := make([]LiveBoard, 0, len(l.boards))
+	for _, board := range l.boards {
+		boards = append(boards, board)
+	}
+
+	sort.Slice(boards, func(i, j int) bool {
+		return boards[i].ChatID < boards[j].ChatID
+	})
+
+	if err := l.storage.Save(liveStateName, boards); err != nil {
+		return fmt.Errorf("unable to save live boards: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,811 @@
+package service
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/SakoDroid/telego/objects"
+	"github.com/jellydator/ttlcache/v3"
+	"github.com/stretchr/testify/require"
+
+	"github.com/buglloc/sowettybot/internal/config"
+	"github.com/buglloc/sowettybot/internal/history"
+	"github.com/buglloc/sowettybot/internal/i18n"
+	"github.com/buglloc/sowettybot/internal/models"
+	"github.com/buglloc/sowettybot/internal/rateit"
+	"github.com/buglloc/sowettybot/internal/ratelimit"
+	"github.com/buglloc/sowettybot/internal/renderer"
+	"github.com/buglloc/sowettybot/internal/scheduler"
+	"github.com/buglloc/sowettybot/internal/storage"
+)
+
+// sentMessage is a message or a photo recorded by the fake messenger, edits are recorded as new entries.
+type sentMessage struct {
+	ChatID    int
+	MessageID int
+	ReplyTo   int
+	Text      string
+	Photo     bool
+	FileID    string
+	Document  *Document
+	Edited    bool
+	Buttons   [][]InlineButton
+}
+
+type fakeMessenger struct {
+	mu       sync.Mutex
+	nextID   int
+	uploads  int
+	messages []sentMessage
+	answers  []string
+	inline   [][]InlineResult
+	actions  []string
+	menus    map[string][]BotCommand
+	statuses map[[2]int]string
+}
+
+func newFakeMessenger() *fakeMessenger {
+	return &fakeMessenger{
+		menus:    make(map[string][]BotCommand),
+		statuses: make(map[[2]int]string),
+	}
+}
+
+func (f *fakeMessenger) record(msg sentMessage) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if msg.MessageID == 0 {
+		f.nextID++
+		msg.MessageID = f.nextID
+	}
+
+	f.messages = append(f.messages, msg)
+	return msg.MessageID
+}
+
+// take returns the messages recorded since the previous call.
+func (f *fakeMessenger) take() []sentMessage {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	out := f.messages
+	f.messages = nil
+	return out
+}
+
+func (f *fakeMessenger) SendMdMessage(chatID int, text string, replyTo int) error {
+	_, err := f.PostMdMessage(chatID, text, replyTo)
+	return err
+}
+
+func (f *fakeMessenger) PostMdMessage(chatID int, text string, replyTo int) (int, error) {
+	return f.PostMdMessageWithButtons(chatID, text, replyTo, nil)
+}
+
+func (f *fakeMessenger) PostMdMessageWithButtons(chatID int, text string, replyTo int, buttons [][]InlineButton) (int, error) {
+	return f.record(sentMessage{ChatID: chatID, ReplyTo: replyTo, Text: text, Buttons: buttons}), nil
+}
+
+func (f *fakeMessenger) EditMdMessage(chatID int, messageID int, text string) error {
+	f.record(sentMessage{ChatID: chatID, MessageID: messageID, Text: text, Edited: true})
+	return nil
+}
+
+func (f *fakeMessenger) EditMdMessageWithButtons(chatID int, messageID int, text string, buttons [][]InlineButton) error {
+	f.record(sentMessage{ChatID: chatID, MessageID: messageID, Text: text, Edited: true, Buttons: buttons})
+	return nil
+}
+
+func (f *fakeMessenger) PinMessage(int, int) error {
+	return nil
+}
+
+func (f *fakeMessenger) UnpinMessage(int, int) error {
+	return nil
+}
+
+func (f *fakeMessenger) SendMdPhoto(chatID int, caption string, photo Photo, replyTo int) (string, error) {
+	return f.SendMdPhotoWithButtons(chatID, caption, photo, replyTo, nil)
+}
+
+func (f *fakeMessenger) SendMdPhotoWithButtons(chatID int, caption string, photo Photo, replyTo int, buttons [][]InlineButton) (string, error) {
+	if err := checkPhoto(photo); err != nil {
+		return "", err
+	}
+
+	f.record(sentMessage{ChatID: chatID, ReplyTo: replyTo, Text: caption, Photo: true, FileID: photo.FileID, Buttons: buttons})
+	return f.fileID(photo), nil
+}
+
+func (f *fakeMessenger) SendMdDocument(chatID int, doc Document, caption string, replyTo int) error {
+	f.record(sentMessage{ChatID: chatID, ReplyTo: replyTo, Text: caption, Document: &doc})
+	return nil
+}
+
+func (f *fakeMessenger) EditMdPhoto(chatID int, messageID int, caption string, photo Photo, buttons [][]InlineButton) (string, error) {
+	if err := checkPhoto(photo); err != nil {
+		return "", err
+	}
+
+	f.record(sentMessage{ChatID: chatID, MessageID: messageID, Text: caption, Photo: true, FileID: photo.FileID, Edited: true, Buttons: buttons})
+	return f.fileID(photo), nil
+}
+
+func (f *fakeMessenger) AnswerCallback(_ string, text string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.answers = append(f.answers, text)
+	return nil
+}
+
+func (f *fakeMessenger) UploadPhoto(chatID int, photoPath string) (string, error) {
+	if err := checkPhoto(Photo{Path: photoPath}); err != nil {
+		return "", err
+	}
+
+	f.record(sentMessage{ChatID: chatID, Photo: true})
+	return f.fileID(Photo{Path: photoPath}), nil
+}
+
+func (f *fakeMessenger) AnswerInline(_ string, _ int, _ bool, results []InlineResult) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.inline = append(f.inline, results)
+	return nil
+}
+
+func (f *fakeMessenger) ChatMemberStatus(chatID int, userID int) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if status, ok := f.statuses[[2]int{chatID, userID}]; ok {
+		return status, nil
+	}
+
+	return "member", nil
+}
+
+func (f *fakeMessenger) ChatAction(_ int, action string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.actions = append(f.actions, action)
+	return nil
+}
+
+func (f *fakeMessenger) Username() (string, error) {
+	return "SowettyBot", nil
+}
+
+func (f *fakeMessenger) SetCommands(scope string, lang string, commands []BotCommand) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.menus[scope+"/"+lang] = commands
+	return nil
+}
+
+// fileID mimics Telegram: a file_id is returned as is, uploads get a new one.
+func (f *fakeMessenger) fileID(photo Photo) string {
+	if photo.FileID != "" {
+		return photo.FileID
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.uploads++
+	return fmt.Sprintf("file-%d", f.uploads)
+}
+
+func checkPhoto(photo Photo) error {
+	if photo.FileID != "" {
+		return nil
+	}
+
+	fi, err := os.Stat(photo.Path)
+	if err != nil {
+		return err
+	}
+
+	if fi.Size() == 0 {
+		return fmt.Errorf("empty photo %s", photo.Path)
+	}
+
+	return nil
+}
+
+// testBot runs the handlers against the fake messenger and a history file.
+type testBot struct {
+	t      *testing.T
+	h      *CommandsHandler
+	fake   *fakeMessenger
+	nextID int
+	// chats and senders let press fill in the chat and the message the bot replied to
+	chats   map[int]*objects.Chat
+	senders map[int]int
+}
+
+func newTestBot(t *testing.T, access config.Access) *testBot {
+	dir := t.TempDir()
+	histFile := filepath.Join(dir, "rates.txt")
+	f, err := os.Create(histFile)
+	require.NoError(t, err)
+	start := time.Now().UTC().Add(-48 * time.Hour).Truncate(time.Hour)
+	for i := 0; i < 48; i++ {
+		when := start.Add(time.Duration(i) * time.Hour)
+		_, err := fmt.Fprintf(f, "%s contact=%.2f korona=%.2f\n", when.Format(time.RFC822), 2.8+float64(i%5)/100, 2.85-float64(i%3)/100)
+		require.NoError(t, err)
+	}
+	require.NoError(t, f.Close())
+
+	// contact works, korona is down
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Path == "/api/v1/rate/contact/ru-th" {
+			_, _ = w.Write([]byte(`{"rate": 2.81}`))
+			return
+		}
+
+		w.WriteHeader(http.StatusBadGateway)
+		_, _ = w.Write([]byte(`{"err_code": 1, "err_message": "upstream is down"}`))
+	}))
+	t.Cleanup(upstream.Close)
+	rtc, err := rateit.NewClient(rateit.WithUpstream(upstream.URL))
+	require.NoError(t, err)
+
+	fake := newFakeMessenger()
+	store := storage.NewStorage(dir)
+	hist := history.NewHistory(histFile, 1000)
+	hr := renderer.NewHistoryRenderer()
+	exchanges := []config.Exchange{
+		{Name: "Contact", Slug: "contact", Route: "contact/ru-th"},
+		{Name: "Korona", Slug: "korona", Route: "korona/ru-th"},
+	}
+
+	prefs := NewPreferenceStore(store, "en")
+	require.NoError(t, prefs.Initialize())
+	acc := NewAccess(access, fake, store, prefs)
+	require.NoError(t, acc.Initialize())
+
+	digester := &Digester{
+		bot:       fake,
+		history:   hist,
+		renderer:  hr,
+		storage:   store,
+		scheduler: scheduler.NewScheduler(),
+		exchanges: exchanges,
+		prefs:     prefs,
+		defaultTZ: "UTC",
+	}
+	require.NoError(t, digester.Initialize())
+
+	notifier := &Notifier{
+		alerts:      fake,
+		history:     hist,
+		storage:     store,
+		series:      []string{"contact", "korona"},
+		prefs:       prefs,
+		digester:    digester,
+		checkPeriod: time.Minute,
+	}
+	require.NoError(t, notifier.Initialize())
+
+	live := &LiveBoards{
+		bot:       fake,
+		history:   hist,
+		renderer:  hr,
+		storage:   store,
+		exchanges: exchanges,
+		prefs:     prefs,
+		digester:  digester,
+	}
+	require.NoError(t, live.Initialize())
+
+	h := &CommandsHandler{
+		bot:        fake,
+		rtc:        rtc,
+		history:    hist,
+		renderer:   hr,
+		exchanges:  exchanges,
+		limits:     config.Limits{History: config.HistoryLimits{Overall: 1000, Short: 24}},
+		digester:   digester,
+		notifier:   notifier,
+		live:       live,
+		charts:     NewChartCache(0),
+		prefs:      prefs,
+		access:     acc,
+		ratesCache: ttlcache.New[string, models.Rate](),
+		userLimits: ratelimit.NewLimiter(0, 0),
+		chatLimits: ratelimit.NewLimiter(0, 0),
+		outbox:     NewOutbox(fake, store, config.SendLimits{}),
+		startedAt:  time.Now(),
+	}
+	require.NoError(t, h.Initialize())
+	fake.take()
+
+	return &testBot{
+		t:       t,
+		h:       h,
+		fake:    fake,
+		chats:   make(map[int]*objects.Chat),
+		senders: make(map[int]int),
+	}
+}
+
+// send delivers a private chat message and returns everything the bot sent in response.
+func (b *testBot) send(userID int, text string) []sentMessage {
+	return b.sendTo(&objects.Chat{Id: userID, Type: "private"}, userID, text)
+}
+
+func (b *testBot) sendTo(chat *objects.Chat, userID int, text string) []sentMessage {
+	b.nextID++
+	b.chats[chat.Id] = chat
+	b.senders[b.nextID] = userID
+	b.h.HandleMessage(&objects.Update{
+		Message: &objects.Message{
+			MessageId: b.nextID,
+			From:      &objects.User{Id: userID, FirstName: "Tester"},
+			Chat:      chat,
+			Text:      text,
+		},
+	})
+
+	return b.fake.take()
+}
+
+// press presses the inline button of the message and returns the callback answer.
+func (b *testBot) press(msg sentMessage, userID int, data string) string {
+	chat, ok := b.chats[msg.ChatID]
+	if !ok {
+		chat = &objects.Chat{Id: msg.ChatID, Type: "private"}
+	}
+
+	message := objects.Message{MessageId: msg.MessageID, Chat: chat}
+	if sender, ok := b.senders[msg.ReplyTo]; ok {
+		message.ReplyToMessage = &objects.Message{MessageId: msg.ReplyTo, From: &objects.User{Id: sender}}
+	}
+
+	require.True(b.t, b.h.HandleCallback(&objects.Update{
+		CallbackQuery: &objects.CallbackQuery{
+			Id:      "query",
+			From:    objects.User{Id: userID},
+			Message: message,
+			Data:    data,
+		},
+	}))
+
+	b.fake.mu.Lock()
+	defer b.fake.mu.Unlock()
+	return b.fake.answers[len(b.fake.answers)-1]
+}
+
+func TestFlowBasics(t *testing.T) {
+	bot := newTestBot(t, config.Access{})
+	en := i18n.NewLocalizer(i18n.LangEN)
+	ru := i18n.NewLocalizer(i18n.LangRU)
+
+	sent := bot.send(1, "/chatid")
+	require.Len(t, sent, 1)
+	require.Equal(t, en.T("chatid", 1), sent[0].Text)
+	require.Equal(t, 1, sent[0].ReplyTo)
+
+	sent = bot.send(1, "/help")
+	require.Len(t, sent, 1)
+	require.Equal(t, bot.h.help(en, false), sent[0].Text)
+
+	sent = bot.send(1, "hello")
+	require.Len(t, sent, 1)
+	require.Equal(t, en.T("error.unsupported"), sent[0].Text)
+
+	// other bots' commands are ignored in groups
+	require.Empty(t, bot.sendTo(&objects.Chat{Id: -100, Type: "group"}, 1, "/rates@OtherBot"))
+
+	sent = bot.send(1, "/lang ru")
+	require.Len(t, sent, 1)
+	require.Equal(t, ru.T("lang.set", ru.T("lang.name")), sent[0].Text)
+
+	sent = bot.send(1, "/help")
+	require.Equal(t, bot.h.help(ru, false), sent[0].Text)
+
+	require.NotEmpty(t, bot.fake.menus["default/ru"])
+}
+
+func TestFlowHistory(t *testing.T) {
+	bot := newTestBot(t, config.Access{})
+	en := i18n.NewLocalizer(i18n.LangEN)
+
+	sent := bot.send(1, "/history 1d korona")
+	require.Len(t, sent, 1)
+	require.True(t, sent[0].Photo)
+	require.Empty(t, sent[0].FileID)
+	require.NotEmpty(t, sent[0].Buttons)
+
+	// the history hasn't changed, so the chart goes by file_id
+	again := bot.send(1, "/history 1d korona")
+	require.Len(t, again, 1)
+	require.NotEmpty(t, again[0].FileID)
+	require.Equal(t, sent[0].Text, again[0].Text)
+
+	// switch the range with the button
+	button := sent[0].Buttons[0][1]
+	require.Empty(t, bot.press(sent[0], 1, button.Data))
+	edited := bot.fake.take()
+	require.Len(t, edited, 1)
+	require.True(t, edited[0].Edited)
+	require.Equal(t, sent[0].MessageID, edited[0].MessageID)
+
+	require.Equal(t, en.T("chart.outdated"), bot.press(sent[0], 1, "chart:nope"))
+
+	sent = bot.send(1, "/history 1d wise")
+	require.Len(t, sent, 1)
+	require.False(t, sent[0].Photo)
+	require.Contains(t, sent[0].Text, en.T("history.usage"))
+
+	sent = bot.send(1, "/rawhistory 3 contact")
+	require.Len(t, sent, 1)
+	require.Contains(t, sent[0].Text, "(c)")
+	require.NotContains(t, sent[0].Text, "(k)")
+}
+
+func TestFlowRules(t *testing.T) {
+	bot := newTestBot(t, config.Access{})
+	en := i18n.NewLocalizer(i18n.LangEN)
+
+	sent := bot.send(1, "/rule add korona < contact - 0.02")
+	require.Len(t, sent, 1)
+	require.Equal(t, en.T("rule.added", "korona < contact - 0.02"), sent[0].Text)
+
+	sent = bot.send(1, "/rule list")
+	require.Len(t, sent, 1)
+	require.Contains(t, sent[0].Text, "korona < contact - 0.02")
+
+	sent = bot.send(1, "/rule del 1")
+	require.Equal(t, en.T("rule.removed", 1), sent[0].Text)
+
+	// backticks would close the code block
+	sent = bot.send(1, "/rule add korona < `3`")
+	require.Contains(t, sent[0].Text, "korona < \\`3\\`")
+
+	for i := 0; i < maxChatRules; i++ {
+		require.NoError(t, bot.h.notifier.AddChatRule(1, "korona < 3"))
+	}
+	sent = bot.send(1, "/rule add korona < 3")
+	require.Equal(t, en.T("rule.too_many", maxChatRules), sent[0].Text)
+
+	// group settings are for admins only
+	group := &objects.Chat{Id: -100, Type: "group"}
+	sent = bot.sendTo(group, 2, "/rule add korona < 3")
+	require.Equal(t, en.T("error.admin_only"), sent[0].Text)
+
+	bot.fake.statuses[[2]int{-100, 2}] = "administrator"
+	sent = bot.sendTo(group, 2, "/rule add korona < 3")
+	require.Equal(t, en.T("rule.added", "korona < 3"), sent[0].Text)
+}
+
+func TestFlowAccess(t *testing.T) {
+	bot := newTestBot(t, config.Access{Enabled: true, Admins: []int{1}})
+	en := i18n.NewLocalizer(i18n.LangEN)
+
+	// public commands work for everyone
+	sent := bot.send(2, "/chatid")
+	require.Equal(t, en.T("chatid", 2), sent[0].Text)
+
+	sent = bot.send(2, "/rawhistory")
+	require.Len(t, sent, 2)
+	request, reply := sent[0], sent[1]
+	require.Equal(t, 1, request.ChatID)
+	require.Len(t, request.Buttons, 1)
+	require.Equal(t, 2, reply.ChatID)
+	require.Equal(t, en.T("access.requested"), reply.Text)
+
+	sent = bot.send(2, "/rawhistory")
+	require.Equal(t, en.T("access.pending"), sent[0].Text)
+
+	require.Equal(t, en.T("access.admin_only"), bot.press(request, 2, request.Buttons[0][0].Data))
+	require.Equal(t, en.T("access.resolved"), bot.press(request, 1, request.Buttons[0][0].Data))
+	sent = bot.fake.take()
+	require.Len(t, sent, 2)
+	require.True(t, sent[0].Edited)
+	require.Equal(t, sent[1], sentMessage{ChatID: 2, MessageID: sent[1].MessageID, Text: en.T("access.granted")})
+	require.Equal(t, en.T("access.request.outdated"), bot.press(request, 1, request.Buttons[0][0].Data))
+
+	sent = bot.send(2, "/rawhistory 1")
+	require.Contains(t, sent[0].Text, "(k)")
+
+	sent = bot.send(2, "/access block 3")
+	require.Equal(t, en.T("access.admin_only"), sent[0].Text)
+
+	sent = bot.send(1, "/access block 3")
+	require.Equal(t, en.T("access.set", 3, en.T("access.role.blocked")), sent[0].Text)
+
+	sent = bot.send(3, "/rawhistory")
+	require.Len(t, sent, 1)
+	require.Equal(t, en.T("access.denied"), sent[0].Text)
+}
+
+func TestFlowRateLimit(t *testing.T) {
+	bot := newTestBot(t, config.Access{})
+	bot.h.userLimits = ratelimit.NewLimiter(2, time.Hour)
+	en := i18n.NewLocalizer(i18n.LangEN)
+
+	require.Len(t, bot.send(1, "/chatid"), 1)
+	require.Len(t, bot.send(1, "/chatid"), 1)
+
+	sent := bot.send(1, "/chatid")
+	require.Len(t, sent, 1)
+	require.Equal(t, en.N("ratelimit.slow_down", 3600), sent[0].Text)
+
+	// complains once
+	require.Empty(t, bot.send(1, "/chatid"))
+	require.Len(t, bot.send(2, "/chatid"), 1)
+}
+
+func TestFlowRates(t *testing.T) {
+	bot := newTestBot(t, config.Access{})
+	en := i18n.NewLocalizer(i18n.LangEN)
+
+	sent := bot.send(1, "/rates")
+	require.GreaterOrEqual(t, len(sent), 2)
+	placeholder, final := sent[0], sent[len(sent)-1]
+	require.Equal(t, en.T("rates.patient")+"\n⏳ Contact\n⏳ Korona", placeholder.Text)
+	require.Equal(t, 1, placeholder.ReplyTo)
+	require.True(t, final.Edited)
+	require.Equal(t, placeholder.MessageID, final.MessageID)
+	require.Contains(t, final.Text, "2.810")
+
+	sent = bot.send(1, "/history")
+	require.True(t, sent[0].Photo)
+	bot.fake.mu.Lock()
+	defer bot.fake.mu.Unlock()
+	// the action is sent before the photo, once as the chart is quick
+	require.Equal(t, []string{ChatActionUploadPhoto}, bot.fake.actions)
+}
+
+func TestFlowInline(t *testing.T) {
+	bot := newTestBot(t, config.Access{})
+	inline := func(query string) []InlineResult {
+		bot.h.HandleInline(&objects.Update{
+			InlineQuery: &objects.InlineQuery{Id: "query", From: &objects.User{Id: 1}, Query: query},
+		})
+
+		bot.fake.mu.Lock()
+		defer bot.fake.mu.Unlock()
+		return bot.fake.inline[len(bot.fake.inline)-1]
+	}
+
+	// nothing is fetched, the rates come from the last history entry
+	results := inline("rates")
+	require.Len(t, results, 1)
+	require.Contains(t, results[0].Text, "2.820")
+	require.NotContains(t, results[0].Text, "2.810")
+
+	// then from the cache filled by /rates
+	bot.send(1, "/rates")
+	results = inline("rates")
+	require.Contains(t, results[0].Text, "2.810")
+
+	// typing a query takes no tokens, new charts do
+	bot.h.userLimits = ratelimit.NewLimiter(1, time.Hour)
+	for _, query := range []string{"5", "50", "500", "5000"} {
+		require.Len(t, inline(query), 1)
+	}
+
+	bot.h.charts = NewChartCache(-200)
+	results = inline("chart")
+	require.Len(t, results, 1)
+	require.NotEmpty(t, results[0].PhotoFileID)
+	require.Len(t, bot.fake.take(), 1, "chart upload")
+
+	// the chart is reused without rendering or uploading it again
+	require.Equal(t, results, inline("chart"))
+	require.Empty(t, bot.fake.take())
+
+	results = inline("chart all")
+	require.Len(t, results, 1)
+	require.Equal(t, "ratelimit", results[0].ID)
+	require.Empty(t, bot.fake.take())
+}
+
+func TestFlowSettings(t *testing.T) {
+	bot := newTestBot(t, config.Access{})
+	en := i18n.NewLocalizer(i18n.LangEN)
+	ru := i18n.NewLocalizer(i18n.LangRU)
+
+	sent := bot.send(1, "/settings")
+	require.Len(t, sent, 1)
+	require.Contains(t, sent[0].Text, "exchanges: Contact, Korona")
+	require.NotEmpty(t, sent[0].Buttons)
+	settings := sent[0]
+
+	// korona is gone from rates and charts
+	require.Empty(t, bot.press(settings, 1, "settings:ex:korona"))
+	edited := bot.fake.take()
+	require.Len(t, edited, 1)
+	require.True(t, edited[0].Edited)
+	require.Contains(t, edited[0].Text, "exchanges: Contact\n")
+
+	sent = bot.send(1, "/rates")
+	require.Equal(t, en.T("rates.patient")+"\n⏳ Contact", sent[0].Text)
+
+	sent = bot.send(1, "/history")
+	require.True(t, sent[0].Photo)
+	require.Empty(t, sent[0].FileID)
+	view, err := ParseChartView(sent[0].Buttons[0][0].Data)
+	require.NoError(t, err)
+	require.True(t, view.IsHidden(1))
+
+	// another look needs another render
+	require.Empty(t, bot.press(settings, 1, "settings:dark"))
+	bot.fake.take()
+	sent = bot.send(1, "/history")
+	require.Empty(t, sent[0].FileID)
+	sent = bot.send(1, "/history")
+	require.NotEmpty(t, sent[0].FileID)
+
+	require.Equal(t, en.T("settings.outdated"), bot.press(settings, 1, "settings:ex:wise"))
+
+	sent = bot.send(1, "/settings tz Mars/Olympus")
+	require.Contains(t, sent[0].Text, en.T("settings.err.timezone", "Mars/Olympus"))
+
+	sent = bot.send(1, "/settings tz Asia/Bangkok")
+	require.Equal(t, en.T("settings.tz.set", "Asia/Bangkok"), sent[0].Text)
+	require.Equal(t, "Asia/Bangkok", bot.h.location(1, 1).String())
+
+	// times are shown in the preferred time zone
+	last, err := bot.h.history.Entries(1)
+	require.NoError(t, err)
+	sent = bot.send(1, "/rawhistory 1")
+	require.Contains(t, sent[0].Text, en.Time(last[0].When.In(bot.h.location(1, 1)), "layout.time"))
+
+	// auto -> en -> ru
+	bot.press(settings, 1, "settings:lang")
+	bot.press(settings, 1, "settings:lang")
+	edited = bot.fake.take()
+	require.Contains(t, edited[len(edited)-1].Text, ru.T("settings.on"))
+	require.Equal(t, i18n.LangRU, bot.h.prefs.Get(1).Lang)
+
+	sent = bot.send(1, "/settings reset")
+	require.Equal(t, en.T("settings.reset"), sent[0].Text)
+	require.True(t, bot.h.prefs.Get(1).IsZero())
+
+	// in groups everyone has their own preferences
+	group := &objects.Chat{Id: -100, Type: "group"}
+	sent = bot.sendTo(group, 2, "/settings")
+	require.Len(t, sent, 1)
+	require.Equal(t, en.T("settings.not_yours"), bot.press(sent[0], 3, "settings:dark"))
+	require.Empty(t, bot.press(sent[0], 2, "settings:dark"))
+	require.True(t, bot.h.prefs.Get(2).Dark)
+	require.False(t, bot.h.prefs.Get(3).Dark)
+	require.False(t, bot.h.prefs.Get(-100).Dark)
+
+	// anyone may press buttons under channel posts
+	channel := &objects.Chat{Id: -200, Type: "channel"}
+	require.False(t, bot.h.isChatAdmin(channel, 2))
+	bot.fake.statuses[[2]int{-200, 2}] = "administrator"
+	require.True(t, bot.h.isChatAdmin(channel, 2))
+}
+
+func TestFlowLive(t *testing.T) {
+	bot := newTestBot(t, config.Access{})
+	en := i18n.NewLocalizer(i18n.LangEN)
+
+	sent := bot.send(1, "/live")
+	require.Len(t, sent, 1)
+	board, ok := bot.h.live.Board(1)
+	require.True(t, ok)
+	require.Equal(t, sent[0].MessageID, board.MessageID)
+
+	// nothing new yet
+	bot.h.live.Tick()
+	require.Empty(t, bot.fake.take())
+
+	board.LastEdit = board.LastEdit.Add(-liveRefreshPeriod)
+	bot.h.live.mu.Lock()
+	bot.h.live.boards[1] = board
+	bot.h.live.mu.Unlock()
+
+	bot.h.live.Tick()
+	edited := bot.fake.take()
+	require.Len(t, edited, 1)
+	require.True(t, edited[0].Edited)
+	require.Equal(t, board.MessageID, edited[0].MessageID)
+
+	updated, ok := bot.h.live.Board(1)
+	require.True(t, ok)
+	require.True(t, updated.LastEdit.After(board.LastEdit))
+
+	// the boards aren't locked while unpinning, Board would wait for them otherwise
+	unpinner := &unpinMessenger{Messenger: bot.fake, live: bot.h.live}
+	bot.h.live.bot = unpinner
+	sent = bot.send(1, "/live")
+	require.Len(t, sent, 1)
+	restarted, ok := bot.h.live.Board(1)
+	require.True(t, ok)
+
+	sent = bot.send(1, "/live off")
+	require.Equal(t, en.T("live.off"), sent[0].Text)
+	_, ok = bot.h.live.Board(1)
+	require.False(t, ok)
+	require.Equal(t, []int{updated.MessageID, restarted.MessageID}, unpinner.unpinned)
+}
+
+type unpinMessenger struct {
+	Messenger
+	live     *LiveBoards
+	unpinned []int
+}
+
+func (m *unpinMessenger) UnpinMessage(chatID int, messageID int) error {
+	m.live.Board(chatID)
+	m.unpinned = append(m.unpinned, messageID)
+	return nil
+}
+
+func TestFlowDigestNow(t *testing.T) {
+	bot := newTestBot(t, config.Access{})
+	en := i18n.NewLocalizer(i18n.LangEN)
+
+	sent := bot.send(1, "/settings tz Asia/Bangkok")
+	require.Equal(t, en.T("settings.tz.set", "Asia/Bangkok"), sent[0].Text)
+
+	// without a subscription the digest comes in the preferred time zone
+	sent = bot.send(1, "/digest now")
+	require.Len(t, sent, 2)
+	require.True(t, sent[1].Photo)
+
+	last, err := bot.h.history.Entries(1)
+	require.NoError(t, err)
+	tz := bot.h.location(1, 1)
+	require.Contains(t, sent[1].Text, en.Time(last[0].When.In(tz), "layout.datetime"))
+}
+
+func TestFlowStatus(t *testing.T) {
+	bot := newTestBot(t, config.Access{Enabled: true, Admins: []int{1}})
+	en := i18n.NewLocalizer(i18n.LangEN)
+
+	sent := bot.send(2, "/status")
+	require.Equal(t, en.T("access.admin_only"), sent[0].Text)
+
+	require.NoError(t, bot.h.notifier.AddChatRule(1, "korona < 3"))
+	bot.send(1, "/rates")
+	bot.send(1, "/rates")
+
+	sent = bot.send(1, "/status")
+	require.Len(t, sent, 1)
+	require.Contains(t, sent[0].Text, "✓ Contact (contact/ru-th)")
+	require.Contains(t, sent[0].Text, "✗ Korona (korona/ru-th)")
+	require.Contains(t, sent[0].Text, "upstream is down")
+	require.Contains(t, sent[0].Text, "hits: 2, misses: 2")
+	require.Contains(t, sent[0].Text, "1: korona < 3\n  "+en.T("tmpl.status.rule.never"))
+	require.Contains(t, sent[0].Text, en.T("status.queue.access")+": 0")
+}
+
+func TestFlowExport(t *testing.T) {
+	bot := newTestBot(t, config.Access{})
+
+	sent := bot.send(1, "/export 1d")
+	require.Len(t, sent, 1)
+	require.NotNil(t, sent[0].Document)
+	require.Equal(t, ".csv", filepath.Ext(sent[0].Document.Name))
+	require.Contains(t, string(sent[0].Document.Data), "\ntime,Contact,Korona\n")
+	require.Contains(t, sent[0].Text, "entries from")
+
+	sent = bot.send(1, "/export json 1d")
+	require.Len(t, sent, 1)
+	require.Equal(t, ".json", filepath.Ext(sent[0].Document.Name))
+	require.Contains(t, string(sent[0].Document.Data), `"route": "korona/ru-th"`)
+
+	sent = bot.send(1, "/export xml")
+	require.Nil(t, sent[0].Document)
+	require.Contains(t, sent[0].Text, "/export [period|dates] [csv|json]")
+}
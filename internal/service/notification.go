@@ -5,53 +5,189 @@ import (
 	"time"
 
 	"github.com/buglloc/sowettybot/internal/config"
+	"github.com/buglloc/sowettybot/internal/rules"
+	"github.com/buglloc/sowettybot/internal/sinks"
 )
 
 const (
-	notifyThreshold       = 60 * time.Minute
-	rateEqualityThreshold = 0.001
+	defaultCooldown      = 60 * time.Minute
+	defaultRatePrecision = 0.001
+)
+
+type Decision int
+
+const (
+	DecisionSkip Decision = iota
+	DecisionNotify
+	DecisionEscalate
 )
 
 type Notification struct {
 	config.Notification
-	lastRate float64
-	lastSend time.Time
+	rule            *rules.Rule
+	chatRule        bool
+	sinks           []sinks.Sink
+	escalationSinks []sinks.Sink
+	lastRate        float64
+	lastSend        time.Time
+	lastLevel       int
+	disarmed        bool
+	day             time.Time
+	dayCount        int
 }
 
-func NewNotification(cfg config.Notification) *Notification {
+func NewNotification(cfg config.Notification, targets ...sinks.Sink) *Notification {
 	return &Notification{
 		Notification: cfg,
+		sinks:        targets,
+	}
+}
+
+func NewRuleNotification(cfg config.Notification, series []string, targets ...sinks.Sink) (*Notification, error) {
+	rule, err := rules.Compile(cfg.Rule, series)
+	if err != nil {
+		return nil, err
+	}
+
+	n := NewNotification(cfg, targets...)
+	n.rule = rule
+	return n, nil
+}
+
+// Observe must be called with every checked rate, it re-arms the notification
+// as soon as the rate leaves the hysteresis band.
+func (n *Notification) Observe(rate float64) {
+	if n.Policy.RearmDelta <= 0 || n.compareRate(rate, 0.0) == 0 {
+		return
+	}
+
+	if n.compareRate(rate, n.Threshold+n.Policy.RearmDelta) != 1 {
+		return
+	}
+
+	n.rearm()
+}
+
+// ObserveRule is the Observe counterpart for rule notifications: with hysteresis enabled
+// the notification re-arms as soon as the rule stops matching.
+func (n *Notification) ObserveRule(active bool) {
+	if n.Policy.RearmDelta <= 0 || active {
+		return
 	}
+
+	n.rearm()
 }
 
 func (n *Notification) ShouldNotify(rate float64) bool {
-	if compareRate(rate, 0.0) == 0 {
-		return false
+	return n.Check(rate, time.Now()) != DecisionSkip
+}
+
+func (n *Notification) Check(rate float64, now time.Time) Decision {
+	if n.compareRate(rate, 0.0) == 0 {
+		return DecisionSkip
+	}
+
+	if n.compareRate(rate, n.Threshold) == 1 {
+		return DecisionSkip
 	}
 
-	if compareRate(rate, n.Threshold) == 1 {
-		return false
+	return n.decide(rate, now)
+}
+
+func (n *Notification) CheckRule(active bool, rate float64, now time.Time) Decision {
+	if !active || n.compareRate(rate, 0.0) == 0 {
+		return DecisionSkip
+	}
+
+	return n.decide(rate, now)
+}
+
+func (n *Notification) decide(rate float64, now time.Time) Decision {
+	if n.Policy.MaxPerDay > 0 && n.sameDay(now) && n.dayCount >= n.Policy.MaxPerDay {
+		return DecisionSkip
 	}
 
-	if compareRate(rate, n.lastRate) == -1 {
-		return true
+	if n.Policy.Escalation.Step > 0 && !n.lastSend.IsZero() && n.level(rate) > n.lastLevel {
+		return DecisionEscalate
 	}
 
-	if time.Since(n.lastSend) > notifyThreshold {
-		return true
+	if n.disarmed {
+		return DecisionSkip
 	}
 
-	return false
+	if n.lastRate == 0.0 && n.Policy.NewLowOnly {
+		return DecisionNotify
+	}
+
+	if n.compareRate(rate, n.lastRate) == -1 {
+		return DecisionNotify
+	}
+
+	if n.Policy.NewLowOnly {
+		return DecisionSkip
+	}
+
+	if now.Sub(n.lastSend) > n.cooldown() {
+		return DecisionNotify
+	}
+
+	return DecisionSkip
 }
 
-func (n *Notification) Notified(rate float64) {
+func (n *Notification) Notified(rate float64, now time.Time) {
 	n.lastRate = rate
-	n.lastSend = time.Now()
+	n.lastSend = now
+	n.lastLevel = n.level(rate)
+	n.disarmed = n.Policy.RearmDelta > 0
+
+	if !n.sameDay(now) {
+		y, m, d := now.Date()
+		n.day = time.Date(y, m, d, 0, 0, 0, 0, now.Location())
+		n.dayCount = 0
+	}
+	n.dayCount++
+}
+
+func (n *Notification) rearm() {
+	n.disarmed = false
+	n.lastRate = 0
+	n.lastLevel = 0
+}
+
+func (n *Notification) level(rate float64) int {
+	if n.Policy.Escalation.Step <= 0 || n.Threshold <= 0 {
+		return 0
+	}
+
+	// precision keeps "exactly one step below" on the next level
+	return int(math.Floor((n.Threshold - rate + n.precision()) / n.Policy.Escalation.Step))
+}
+
+func (n *Notification) sameDay(now time.Time) bool {
+	y, m, d := now.Date()
+	dy, dm, dd := n.day.In(now.Location()).Date()
+	return y == dy && m == dm && d == dd
+}
+
+func (n *Notification) cooldown() time.Duration {
+	if n.Policy.Cooldown > 0 {
+		return n.Policy.Cooldown
+	}
+
+	return defaultCooldown
+}
+
+func (n *Notification) precision() float64 {
+	if n.Policy.Precision > 0 {
+		return n.Policy.Precision
+	}
+
+	return defaultRatePrecision
 }
 
-func compareRate(a, b float64) int {
+func (n *Notification) compareRate(a, b float64) int {
 	switch {
-	case math.Abs(a-b) <= rateEqualityThreshold:
+	case math.Abs(a-b) <= n.precision():
 		return 0
 	case a < b:
 		return -1
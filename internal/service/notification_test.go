@@ -180,3 +180,147 @@ func TestShouldNotify(t *testing.T) {
 		})
 	}
 }
+
+func TestPolicies(t *testing.T) {
+	type step struct {
+		rate     float64
+		after    time.Duration
+		expected Decision
+	}
+
+	cases := []struct {
+		name   string
+		policy config.Policy
+		steps  []step
+	}{
+		{
+			name:   "default",
+			policy: config.Policy{},
+			steps: []step{
+				{rate: 2.99, expected: DecisionNotify},
+				{rate: 3.01, after: 10 * time.Minute, expected: DecisionSkip},
+				{rate: 2.99, after: 20 * time.Minute, expected: DecisionSkip},
+				{rate: 2.95, after: 30 * time.Minute, expected: DecisionNotify},
+				{rate: 2.99, after: 40 * time.Minute, expected: DecisionSkip},
+				{rate: 2.99, after: 100 * time.Minute, expected: DecisionNotify},
+			},
+		},
+		{
+			name: "cooldown",
+			policy: config.Policy{
+				Cooldown: 3 * time.Hour,
+			},
+			steps: []step{
+				{rate: 2.99, expected: DecisionNotify},
+				{rate: 2.99, after: 2 * time.Hour, expected: DecisionSkip},
+				{rate: 2.99, after: 4 * time.Hour, expected: DecisionNotify},
+			},
+		},
+		{
+			name: "precision",
+			policy: config.Policy{
+				Precision: 0.01,
+			},
+			steps: []step{
+				{rate: 2.99, expected: DecisionNotify},
+				{rate: 2.985, after: 10 * time.Minute, expected: DecisionSkip},
+				{rate: 2.97, after: 20 * time.Minute, expected: DecisionNotify},
+			},
+		},
+		{
+			name: "hysteresis",
+			policy: config.Policy{
+				RearmDelta: 0.05,
+			},
+			steps: []step{
+				{rate: 2.99, expected: DecisionNotify},
+				{rate: 3.01, after: 10 * time.Minute, expected: DecisionSkip},
+				{rate: 2.98, after: 20 * time.Minute, expected: DecisionSkip},
+				{rate: 2.98, after: 2 * time.Hour, expected: DecisionSkip},
+				{rate: 3.04, after: 3 * time.Hour, expected: DecisionSkip},
+				{rate: 2.99, after: 4 * time.Hour, expected: DecisionSkip},
+				{rate: 3.06, after: 5 * time.Hour, expected: DecisionSkip},
+				{rate: 2.99, after: 5*time.Hour + 10*time.Minute, expected: DecisionNotify},
+			},
+		},
+		{
+			name: "new low only",
+			policy: config.Policy{
+				NewLowOnly: true,
+			},
+			steps: []step{
+				{rate: 2.99, expected: DecisionNotify},
+				{rate: 2.99, after: 2 * time.Hour, expected: DecisionSkip},
+				{rate: 2.98, after: 3 * time.Hour, expected: DecisionNotify},
+				{rate: 3.5, after: 4 * time.Hour, expected: DecisionSkip},
+				{rate: 2.99, after: 24 * time.Hour, expected: DecisionSkip},
+				{rate: 2.97, after: 25 * time.Hour, expected: DecisionNotify},
+			},
+		},
+		{
+			name: "new low only with hysteresis",
+			policy: config.Policy{
+				NewLowOnly: true,
+				RearmDelta: 0.1,
+			},
+			steps: []step{
+				{rate: 2.9, expected: DecisionNotify},
+				{rate: 2.8, after: 10 * time.Minute, expected: DecisionSkip},
+				{rate: 3.2, after: 20 * time.Minute, expected: DecisionSkip},
+				{rate: 2.95, after: 30 * time.Minute, expected: DecisionNotify},
+			},
+		},
+		{
+			name: "max per day",
+			policy: config.Policy{
+				Cooldown:  time.Minute,
+				MaxPerDay: 2,
+			},
+			steps: []step{
+				{rate: 2.99, expected: DecisionNotify},
+				{rate: 2.99, after: 2 * time.Minute, expected: DecisionNotify},
+				{rate: 2.99, after: 4 * time.Minute, expected: DecisionSkip},
+				{rate: 2.5, after: 6 * time.Minute, expected: DecisionSkip},
+				{rate: 2.99, after: 25 * time.Hour, expected: DecisionNotify},
+			},
+		},
+		{
+			name: "escalation",
+			policy: config.Policy{
+				RearmDelta: 0.05,
+				Escalation: config.Escalation{
+					Step: 0.1,
+				},
+			},
+			steps: []step{
+				{rate: 2.95, expected: DecisionNotify},
+				{rate: 2.91, after: 10 * time.Minute, expected: DecisionSkip},
+				{rate: 2.9, after: 20 * time.Minute, expected: DecisionEscalate},
+				{rate: 2.85, after: 30 * time.Minute, expected: DecisionSkip},
+				{rate: 2.75, after: 40 * time.Minute, expected: DecisionEscalate},
+				{rate: 2.5, after: 50 * time.Minute, expected: DecisionEscalate},
+				{rate: 2.5, after: 5 * time.Hour, expected: DecisionSkip},
+			},
+		},
+	}
+
+	start := time.Date(2023, 6, 30, 10, 0, 0, 0, time.UTC)
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			n := NewNotification(config.Notification{
+				Threshold: 3.0,
+				Policy:    tc.policy,
+			})
+
+			for i, s := range tc.steps {
+				now := start.Add(s.after)
+				n.Observe(s.rate)
+				actual := n.Check(s.rate, now)
+				require.Equal(t, s.expected, actual, "step #%d: rate=%.3f %s", i, s.rate, notificationInfo(*n))
+				if actual != DecisionSkip {
+					n.Notified(s.rate, now)
+				}
+			}
+		})
+	}
+}
@@ -1,36 +1,188 @@
 package service
 
 import (
+	"context"
 	"fmt"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/rs/zerolog/log"
 
+	"github.com/buglloc/sowettybot/internal/config"
 	"github.com/buglloc/sowettybot/internal/history"
+	"github.com/buglloc/sowettybot/internal/i18n"
+	"github.com/buglloc/sowettybot/internal/models"
+	"github.com/buglloc/sowettybot/internal/rules"
+	"github.com/buglloc/sowettybot/internal/sinks"
+	"github.com/buglloc/sowettybot/internal/storage"
 )
 
+const (
+	sinkSendTimeout = 1 * time.Minute
+	rulesStateName  = "rules"
+	// maxChatRules keeps a chat from making every tick evaluate heaps of rules
+	maxChatRules = 20
+)
+
+var errTooManyRules = i18n.Errorf("rule.too_many", maxChatRules)
+
+type ChatRule struct {
+	ChatID int    `json:"chat_id"`
+	Rule   string `json:"rule"`
+}
+
 type Notifier struct {
-	bot           *BotWrapper
+	// alerts delivers chat rule alerts, the outbox queue in production
+	alerts        sinks.MdSender
 	history       *history.History
+	storage       *storage.Storage
+	series        []string
+	mu            sync.Mutex
 	notifications []*Notification
+	prefs         *PreferenceStore
+	digester      *Digester
 	checkPeriod   time.Duration
-	lastTick      time.Time
 	lastCheck     time.Time
 }
 
 func (n *Notifier) Initialize() error {
+	var chatRules []ChatRule
+	if err := n.storage.Load(rulesStateName, &chatRules); err != nil {
+		return fmt.Errorf("unable to load chat rules: %w", err)
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	for _, cr := range chatRules {
+		notification, err := n.newChatRule(cr)
+		if err != nil {
+			log.Error().Err(err).Int("chat_id", cr.ChatID).Str("rule", cr.Rule).Msg("skip invalid chat rule")
+			continue
+		}
+
+		n.notifications = append(n.notifications, notification)
+	}
+
 	return nil
 }
 
-func (n *Notifier) Tick() {
-	now := time.Now()
-	if now.Sub(n.lastTick) < n.checkPeriod {
-		return
+// ChatRules returns rules added by the chat itself.
+func (n *Notifier) ChatRules(chatID int) []string {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	var out []string
+	for _, notification := range n.notifications {
+		if notification.chatRule && notification.ChatID == chatID {
+			out = append(out, notification.rule.String())
+		}
+	}
+
+	return out
+}
+
+// Status describes every notification and when it fired last.
+func (n *Notifier) Status(loc *i18n.Localizer, now time.Time) []models.StatusRule {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	out := make([]models.StatusRule, len(n.notifications))
+	for i, notification := range n.notifications {
+		out[i] = models.StatusRule{
+			ChatID:    notification.ChatID,
+			LastFired: notification.lastSend,
+		}
+
+		if notification.sameDay(now) {
+			out[i].FiredToday = notification.dayCount
+		}
+
+		if notification.rule != nil {
+			out[i].Rule = notification.rule.String()
+		} else {
+			out[i].Rule = loc.T("status.rule.threshold", loc.Float(notification.Threshold, 2))
+		}
+	}
+
+	return out
+}
+
+func (n *Notifier) AddChatRule(chatID int, rule string) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	count := 0
+	for _, notification := range n.notifications {
+		if notification.chatRule && notification.ChatID == chatID {
+			count++
+		}
+	}
+
+	if count >= maxChatRules {
+		return errTooManyRules
+	}
+
+	notification, err := n.newChatRule(ChatRule{
+		ChatID: chatID,
+		Rule:   rule,
+	})
+	if err != nil {
+		return err
+	}
+
+	n.notifications = append(n.notifications, notification)
+	return n.lockedSaveChatRules()
+}
+
+// RemoveChatRule removes the idx-th (zero based) chat rule.
+func (n *Notifier) RemoveChatRule(chatID int, idx int) (bool, error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	for i, notification := range n.notifications {
+		if !notification.chatRule || notification.ChatID != chatID {
+			continue
+		}
+
+		if idx > 0 {
+			idx--
+			continue
+		}
+
+		n.notifications = append(n.notifications[:i], n.notifications[i+1:]...)
+		return true, n.lockedSaveChatRules()
+	}
+
+	return false, nil
+}
+
+// ForgetChat drops every rule added by the chat.
+func (n *Notifier) ForgetChat(chatID int) (bool, error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	notifications := n.notifications[:0]
+	for _, notification := range n.notifications {
+		if notification.chatRule && notification.ChatID == chatID {
+			continue
+		}
+
+		notifications = append(notifications, notification)
+	}
+
+	if len(notifications) == len(n.notifications) {
+		return false, nil
 	}
 
-	n.lastTick = now
-	entries, err := n.history.Entries(1)
+	n.notifications = notifications
+	return true, n.lockedSaveChatRules()
+}
+
+func (n *Notifier) Tick() {
+	now := time.Now()
+	entries, err := n.history.Entries(0)
 	if err != nil {
 		log.Error().Err(err).Msg("unable to get history")
 		return
@@ -40,39 +192,235 @@ func (n *Notifier) Tick() {
 		return
 	}
 
-	entry := entries[0]
+	entry := entries[len(entries)-1]
 	if n.lastCheck.After(entry.When) {
 		return
 	}
 
 	n.lastCheck = now
-	var notification strings.Builder
+	minRate := 0.0
+	for _, v := range entry.Values {
+		if v == 0.0 {
+			continue
+		}
+
+		if minRate == 0.0 || v < minRate {
+			minRate = v
+		}
+	}
+
+	env := rules.NewHistoryEnv(entries, now)
+
+	type firedAlert struct {
+		notification *Notification
+		targets      []sinks.Sink
+		alert        sinks.Alert
+	}
+
+	// sinks may take a while, so alerts are sent without holding the lock
+	var fired []firedAlert
+	n.mu.Lock()
 	for _, cfg := range n.notifications {
-		notification.Reset()
-		minRate := 0.0
-		for i, v := range entry.Values {
-			if !cfg.ShouldNotify(v) {
+		var decision Decision
+		if cfg.rule != nil {
+			// hour() and weekday() are about the chat time
+			active, err := cfg.rule.Eval(env.In(chatLocation(n.prefs, n.digester, cfg.ChatID)))
+			if err != nil {
+				log.Error().Err(err).Str("rule", cfg.rule.String()).Msg("unable to evaluate rule")
 				continue
 			}
 
-			if v < minRate {
-				minRate = v
-			}
+			cfg.ObserveRule(active)
+			decision = cfg.CheckRule(active, minRate, now)
+		} else {
+			cfg.Observe(minRate)
+			decision = cfg.Check(minRate, now)
+		}
+
+		if decision == DecisionSkip {
+			continue
+		}
+
+		alert := n.buildAlert(cfg, decision, entry)
+		targets := cfg.sinks
+		if decision == DecisionEscalate {
+			targets = append(targets[:len(targets):len(targets)], cfg.escalationSinks...)
+		}
+
+		fired = append(fired, firedAlert{
+			notification: cfg,
+			targets:      targets,
+			alert:        alert,
+		})
+	}
+	n.mu.Unlock()
+
+	for _, f := range fired {
+		if !n.send(f.targets, f.alert) {
+			continue
+		}
+
+		n.mu.Lock()
+		f.notification.Notified(minRate, now)
+		n.mu.Unlock()
+	}
+}
+
+func (n *Notifier) buildAlert(cfg *Notification, decision Decision, entry models.History) sinks.Alert {
+	alert := sinks.Alert{
+		Threshold: cfg.Threshold,
+		When:      entry.When,
+	}
+
+	loc := n.prefs.For(cfg.ChatID, "")
+	var notification strings.Builder
+	switch {
+	case cfg.rule != nil:
+		notification.WriteString(loc.T("alert.rule", cfg.rule))
+	case decision == DecisionEscalate:
+		notification.WriteString(loc.T("alert.escalate", loc.Float(cfg.Threshold, 2)))
+	default:
+		notification.WriteString(loc.T("alert.threshold", loc.Float(cfg.Threshold, 2)))
+	}
+	notification.WriteByte('\n')
+
+	for i, v := range entry.Values {
+		if v == 0.0 {
+			continue
+		}
+
+		if cfg.rule == nil && cfg.compareRate(v, cfg.Threshold) == 1 {
+			continue
+		}
+
+		_, _ = fmt.Fprintf(&notification, "%s: %s\n", entry.Names[i], loc.Float(v, 2))
+		alert.Rates = append(alert.Rates, sinks.AlertRate{
+			Name: entry.Names[i],
+			Rate: v,
+		})
+	}
+
+	alert.Text = notification.String()
+	return alert
+}
+
+func (n *Notifier) send(targets []sinks.Sink, alert sinks.Alert) bool {
+	delivered := false
+	for _, sink := range targets {
+		err := func() error {
+			ctx, cancel := context.WithTimeout(context.Background(), sinkSendTimeout)
+			defer cancel()
+
+			return sink.Send(ctx, alert)
+		}()
+		if err != nil {
+			log.Error().Err(err).Str("sink", sink.Name()).Str("message", alert.Text).Msg("unable to send notification")
+			continue
+		}
+
+		delivered = true
+	}
+
+	return delivered
+}
+
+// chatSink sends alerts into the chat, it is named after the chat as there is no sink config for it.
+func chatSink(bot sinks.MdSender, chatID int) sinks.Sink {
+	return sinks.NewTelegramSink(fmt.Sprintf("chat:%d", chatID), bot, chatID)
+}
+
+func (n *Notifier) newChatRule(cr ChatRule) (*Notification, error) {
+	notification, err := NewRuleNotification(
+		config.Notification{
+			Rule:   cr.Rule,
+			ChatID: cr.ChatID,
+		},
+		n.series,
+		chatSink(n.alerts, cr.ChatID),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	notification.chatRule = true
+	return notification, nil
+}
+
+func (n *Notifier) lockedSaveChatRules() error {
+	var chatRules []ChatRule
+	for _, notification := range n.notifications {
+		if !notification.chatRule {
+			continue
+		}
+
+		chatRules = append(chatRules, ChatRule{
+			ChatID: notification.ChatID,
+			Rule:   notification.rule.String(),
+		})
+	}
+
+	if err := n.storage.Save(rulesStateName, chatRules); err != nil {
+		return fmt.Errorf("unable to save chat rules: %w", err)
+	}
+
+	return nil
+}
 
-			if notification.Len() == 0 {
-				_, _ = fmt.Fprintf(&notification, "YAY! Nice exchange rate (threshold is %.2f)!\n", cfg.Threshold)
+func NewNotifications(cfg config.Notifier, series []string, bot sinks.MdSender) ([]*Notification, error) {
+	named := make(map[string]sinks.Sink, len(cfg.Sinks))
+	for _, sc := range cfg.Sinks {
+		if _, exists := named[sc.Name]; exists {
+			return nil, fmt.Errorf("duplicate sink %q", sc.Name)
+		}
+
+		sink, err := sinks.NewSink(sc, bot)
+		if err != nil {
+			return nil, fmt.Errorf("invalid sink: %w", err)
+		}
+
+		named[sc.Name] = sink
+	}
+
+	out := make([]*Notification, len(cfg.Notifications))
+	for i, nc := range cfg.Notifications {
+		var targets []sinks.Sink
+		if nc.ChatID != 0 {
+			targets = append(targets, chatSink(bot, nc.ChatID))
+		}
+
+		for _, name := range nc.Sinks {
+			sink, ok := named[name]
+			if !ok {
+				return nil, fmt.Errorf("notification #%d: unknown sink %q", i, name)
 			}
-			_, _ = fmt.Fprintf(&notification, "%s: %.2f\n", entry.Names[i], v)
+
+			targets = append(targets, sink)
 		}
 
-		if notification.Len() > 0 {
-			msg := notification.String()
-			err := n.bot.SendMdMessage(cfg.ChatID, msg, 0)
+		if len(targets) == 0 {
+			return nil, fmt.Errorf("notification #%d: no chat_id or sinks configured", i)
+		}
+
+		if nc.Rule != "" {
+			notification, err := NewRuleNotification(nc, series, targets...)
 			if err != nil {
-				log.Error().Err(err).Str("message", msg).Msg("unable to send notification")
-			} else {
-				cfg.Notified(minRate)
+				return nil, fmt.Errorf("notification #%d: invalid rule %q: %w", i, nc.Rule, err)
+			}
+
+			out[i] = notification
+		} else {
+			out[i] = NewNotification(nc, targets...)
+		}
+
+		for _, name := range nc.Policy.Escalation.Sinks {
+			sink, ok := named[name]
+			if !ok {
+				return nil, fmt.Errorf("notification #%d: unknown escalation sink %q", i, name)
 			}
+
+			out[i].escalationSinks = append(out[i].escalationSinks, sink)
 		}
 	}
+
+	return out, nil
 }
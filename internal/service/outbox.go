@@ -0,0 +1,474 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/buglloc/sowettybot/internal/config"
+	"github.com/buglloc/sowettybot/internal/ratelimit"
+	"github.com/buglloc/sowettybot/internal/sinks"
+	"github.com/buglloc/sowettybot/internal/storage"
+)
+
+const (
+	outboxStateName = "outbox"
+	// outboxAttempts bounds retries of direct sends, someone is waiting for them
+	outboxAttempts = 3
+	// outboxMaxWait is the longest a direct send waits for the send rate or a flood wait
+	outboxMaxWait    = 30 * time.Second
+	outboxMinBackoff = time.Second
+	outboxMaxBackoff = 5 * time.Minute
+	// outboxMaxAge drops queued messages that are too late to matter
+	outboxMaxAge = 24 * time.Hour
+	// outboxGlobalKey is the only key of the global limiter
+	outboxGlobalKey = 0
+)
+
+// OutboxMessage is a queued message waiting for delivery.
+type OutboxMessage struct {
+	ID       int       `json:"id"`
+	ChatID   int       `json:"chat_id"`
+	Text     string    `json:"text"`
+	Created  time.Time `json:"created"`
+	Attempts int       `json:"attempts"`
+	NextTry  time.Time `json:"next_try"`
+}
+
+// Outbox paces everything sent to chats under the global and per chat send rates, sits out
+// the flood waits Telegram sets for a chat and retries sends that never reached Telegram.
+// Queued messages are persisted and delivered in the background by Run, so they survive restarts.
+type Outbox struct {
+	Messenger
+	storage    *storage.Storage
+	global     *ratelimit.Limiter
+	chats      *ratelimit.Limiter
+	minBackoff time.Duration
+	wake       chan struct{}
+	mu         sync.Mutex
+	floodUntil map[int]time.Time
+	// muted tells the chats the bot may not send to for now, nil means none
+	muted  func(chatID int) bool
+	queue  []OutboxMessage
+	nextID int
+}
+
+var _ Messenger = (*Outbox)(nil)
+
+// errChatMuted is returned for sends to muted chats, they aren't even tried.
+var errChatMuted = errors.New("chat is muted")
+
+func NewOutbox(bot Messenger, store *storage.Storage, limits config.SendLimits) *Outbox {
+	return &Outbox{
+		Messenger:  bot,
+		storage:    store,
+		global:     ratelimit.NewLimiter(limits.Global.Burst, limits.Global.Every),
+		chats:      ratelimit.NewLimiter(limits.Chat.Burst, limits.Chat.Every),
+		minBackoff: outboxMinBackoff,
+		wake:       make(chan struct{}, 1),
+		floodUntil: make(map[int]time.Time),
+		nextID:     1,
+	}
+}
+
+func (o *Outbox) Initialize() error {
+	var queue []OutboxMessage
+	if err := o.storage.Load(outboxStateName, &queue); err != nil {
+		return fmt.Errorf("unable to load outbox: %w", err)
+	}
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.queue = queue
+	for _, msg := range queue {
+		if msg.ID >= o.nextID {
+			o.nextID = msg.ID + 1
+		}
+	}
+
+	return nil
+}
+
+// Len returns the number of queued messages.
+func (o *Outbox) Len() int {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	return len(o.queue)
+}
+
+// Enqueue saves the message to be delivered by Run, a long one is queued as several parts.
+func (o *Outbox) Enqueue(chatID int, text string) error {
+	o.mu.Lock()
+	now := time.Now()
+	for _, part := range splitMdMessage(text, tgMaxMessageLen) {
+		o.queue = append(o.queue, OutboxMessage{
+			ID:      o.nextID,
+			ChatID:  chatID,
+			Text:    part,
+			Created: now,
+			NextTry: now,
+		})
+		o.nextID++
+	}
+	err := o.lockedSave()
+	o.mu.Unlock()
+
+	select {
+	case o.wake <- struct{}{}:
+	default:
+	}
+
+	return err
+}
+
+// PauseMuted holds sends to the chats muted reports, their queued messages wait until the chat is unmuted.
+func (o *Outbox) PauseMuted(muted func(chatID int) bool) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.muted = muted
+}
+
+func (o *Outbox) isMuted(chatID int) bool {
+	o.mu.Lock()
+	muted := o.muted
+	o.mu.Unlock()
+
+	return muted != nil && muted(chatID)
+}
+
+// Queued returns the sender for notifier sinks, its messages go through the queue.
+func (o *Outbox) Queued() sinks.MdSender {
+	return queuedSender{outbox: o}
+}
+
+// Run delivers queued messages until the context is done.
+func (o *Outbox) Run(ctx context.Context) {
+	for {
+		wait := o.deliverDue(ctx)
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-o.wake:
+			timer.Stop()
+		case <-timer.C:
+		}
+	}
+}
+
+// SendMdMessage splits the message if it doesn't fit one, each part is paced and retried on its own,
+// so a failed part doesn't repeat the delivered ones. Too long messages are sent as a text document.
+func (o *Outbox) SendMdMessage(chatID int, text string, replyTo int) error {
+	parts := splitMdMessage(text, tgMaxMessageLen)
+	if len(parts) > tgMaxMessageParts {
+		return o.SendMdDocument(chatID, textDocument(text), "", replyTo)
+	}
+
+	for _, part := range parts {
+		err := o.do(chatID, func() error {
+			return o.Messenger.SendMdMessage(chatID, part, replyTo)
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (o *Outbox) PostMdMessage(chatID int, text string, replyTo int) (msgID int, err error) {
+	err = o.do(chatID, func() error {
+		msgID, err = o.Messenger.PostMdMessage(chatID, text, replyTo)
+		return err
+	})
+	return
+}
+
+func (o *Outbox) PostMdMessageWithButtons(chatID int, text string, replyTo int, buttons [][]InlineButton) (msgID int, err error) {
+	err = o.do(chatID, func() error {
+		msgID, err = o.Messenger.PostMdMessageWithButtons(chatID, text, replyTo, buttons)
+		return err
+	})
+	return
+}
+
+func (o *Outbox) EditMdMessage(chatID int, messageID int, text string) error {
+	return o.do(chatID, func() error {
+		return o.Messenger.EditMdMessage(chatID, messageID, text)
+	})
+}
+
+func (o *Outbox) EditMdMessageWithButtons(chatID int, messageID int, text string, buttons [][]InlineButton) error {
+	return o.do(chatID, func() error {
+		return o.Messenger.EditMdMessageWithButtons(chatID, messageID, text, buttons)
+	})
+}
+
+func (o *Outbox) PinMessage(chatID int, messageID int) error {
+	return o.do(chatID, func() error {
+		return o.Messenger.PinMessage(chatID, messageID)
+	})
+}
+
+func (o *Outbox) UnpinMessage(chatID int, messageID int) error {
+	return o.do(chatID, func() error {
+		return o.Messenger.UnpinMessage(chatID, messageID)
+	})
+}
+
+func (o *Outbox) SendMdPhoto(chatID int, caption string, photo Photo, replyTo int) (fileID string, err error) {
+	err = o.do(chatID, func() error {
+		fileID, err = o.Messenger.SendMdPhoto(chatID, caption, photo, replyTo)
+		return err
+	})
+	return
+}
+
+func (o *Outbox) SendMdPhotoWithButtons(chatID int, caption string, photo Photo, replyTo int, buttons [][]InlineButton) (fileID string, err error) {
+	err = o.do(chatID, func() error {
+		fileID, err = o.Messenger.SendMdPhotoWithButtons(chatID, caption, photo, replyTo, buttons)
+		return err
+	})
+	return
+}
+
+func (o *Outbox) SendMdDocument(chatID int, doc Document, caption string, replyTo int) error {
+	return o.do(chatID, func() error {
+		return o.Messenger.SendMdDocument(chatID, doc, caption, replyTo)
+	})
+}
+
+func (o *Outbox) EditMdPhoto(chatID int, messageID int, caption string, photo Photo, buttons [][]InlineButton) (fileID string, err error) {
+	err = o.do(chatID, func() error {
+		fileID, err = o.Messenger.EditMdPhoto(chatID, messageID, caption, photo, buttons)
+		return err
+	})
+	return
+}
+
+func (o *Outbox) UploadPhoto(chatID int, photoPath string) (fileID string, err error) {
+	err = o.do(chatID, func() error {
+		fileID, err = o.Messenger.UploadPhoto(chatID, photoPath)
+		return err
+	})
+	return
+}
+
+// do makes a direct send, retrying it a few times within outboxMaxWait.
+func (o *Outbox) do(chatID int, call func() error) error {
+	ctx, cancel := context.WithTimeout(context.Background(), outboxMaxWait)
+	defer cancel()
+
+	for attempt := 1; ; attempt++ {
+		err := o.try(ctx, chatID, call)
+		delay, retry := o.retryDelay(err, attempt)
+		if !retry || attempt == outboxAttempts {
+			return err
+		}
+
+		log.Warn().Err(err).Int("chat_id", chatID).Dur("delay", delay).Msg("retry telegram send")
+		if sleepCtx(ctx, delay) != nil {
+			return err
+		}
+	}
+}
+
+// try waits for the send rate and calls once, a flood wait pauses the following sends to the chat.
+func (o *Outbox) try(ctx context.Context, chatID int, call func() error) error {
+	if o.isMuted(chatID) {
+		return errChatMuted
+	}
+
+	if err := o.pace(ctx, chatID); err != nil {
+		return fmt.Errorf("wait for send rate: %w", err)
+	}
+
+	err := call()
+	if wait, ok := floodWait(err); ok {
+		o.mu.Lock()
+		now := time.Now()
+		for id, until := range o.floodUntil {
+			if !until.After(now) {
+				delete(o.floodUntil, id)
+			}
+		}
+
+		if until := now.Add(wait); until.After(o.floodUntil[chatID]) {
+			o.floodUntil[chatID] = until
+		}
+		o.mu.Unlock()
+	}
+
+	return err
+}
+
+func (o *Outbox) pace(ctx context.Context, chatID int) error {
+	for {
+		o.mu.Lock()
+		wait := time.Until(o.floodUntil[chatID])
+		o.mu.Unlock()
+
+		if wait <= 0 {
+			if v := o.global.Allow(outboxGlobalKey); !v.Allowed {
+				wait = v.RetryAfter
+			} else if v := o.chats.Allow(chatID); !v.Allowed {
+				wait = v.RetryAfter
+			} else {
+				return nil
+			}
+		}
+
+		if err := sleepCtx(ctx, wait); err != nil {
+			return err
+		}
+	}
+}
+
+// retryDelay tells whether the failed send is worth repeating and when.
+func (o *Outbox) retryDelay(err error, attempt int) (time.Duration, bool) {
+	if wait, ok := floodWait(err); ok {
+		return wait, true
+	}
+
+	if !notSent(err) {
+		return 0, false
+	}
+
+	delay := o.minBackoff
+	for i := 1; i < attempt && delay < outboxMaxBackoff; i++ {
+		delay *= 2
+	}
+
+	if delay > outboxMaxBackoff {
+		delay = outboxMaxBackoff
+	}
+
+	return delay, true
+}
+
+// deliverDue sends the queued messages that are due and returns the time until the next one.
+// Messages to a chat are delivered in order, so one waiting for a retry holds back the later ones.
+func (o *Outbox) deliverDue(ctx context.Context) time.Duration {
+	o.mu.Lock()
+	queue := append([]OutboxMessage(nil), o.queue...)
+	o.mu.Unlock()
+
+	held := make(map[int]bool)
+	for _, msg := range queue {
+		if held[msg.ChatID] || time.Now().Before(msg.NextTry) || o.isMuted(msg.ChatID) {
+			held[msg.ChatID] = true
+			continue
+		}
+
+		err := o.try(ctx, msg.ChatID, func() error {
+			return o.Messenger.SendMdMessage(msg.ChatID, msg.Text, 0)
+		})
+		if ctx.Err() != nil {
+			return 0
+		}
+
+		if !o.settle(msg, err) {
+			held[msg.ChatID] = true
+		}
+	}
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	// muted chats are looked at again in outboxMaxBackoff at most
+	wait := outboxMaxBackoff
+	for _, msg := range o.queue {
+		if o.muted != nil && o.muted(msg.ChatID) {
+			continue
+		}
+
+		if next := time.Until(msg.NextTry); next < wait {
+			wait = next
+		}
+	}
+
+	if wait < 0 {
+		wait = 0
+	}
+
+	return wait
+}
+
+// settle removes the message once delivered or given up on, it's false if the message is kept for a retry.
+func (o *Outbox) settle(msg OutboxMessage, err error) bool {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	idx := -1
+	for i := range o.queue {
+		if o.queue[i].ID == msg.ID {
+			idx = i
+			break
+		}
+	}
+
+	if idx < 0 {
+		return true
+	}
+
+	delay, retry := o.retryDelay(err, msg.Attempts+1)
+	switch {
+	case err == nil:
+	case !retry:
+		log.Error().Err(err).Int("chat_id", msg.ChatID).Str("message", msg.Text).Msg("drop undeliverable message")
+	case time.Since(msg.Created) > outboxMaxAge:
+		log.Error().Err(err).Int("chat_id", msg.ChatID).Str("message", msg.Text).Msg("drop expired message")
+	default:
+		log.Warn().Err(err).Int("chat_id", msg.ChatID).Dur("delay", delay).Msg("retry queued message")
+		o.queue[idx].Attempts++
+		o.queue[idx].NextTry = time.Now().Add(delay)
+		if err := o.lockedSave(); err != nil {
+			log.Error().Err(err).Msg("unable to save outbox")
+		}
+
+		return false
+	}
+
+	o.queue = append(o.queue[:idx], o.queue[idx+1:]...)
+	if err := o.lockedSave(); err != nil {
+		log.Error().Err(err).Msg("unable to save outbox")
+	}
+
+	return true
+}
+
+func (o *Outbox) lockedSave() error {
+	if err := o.storage.Save(outboxStateName, o.queue); err != nil {
+		return fmt.Errorf("unable to save outbox: %w", err)
+	}
+
+	return nil
+}
+
+type queuedSender struct {
+	outbox *Outbox
+}
+
+func (s queuedSender) SendMdMessage(chatID int, text string, _ int) error {
+	return s.outbox.Enqueue(chatID, text)
+}
+
+func sleepCtx(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,176 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	tgerrors "github.com/SakoDroid/telego/errors"
+	objs "github.com/SakoDroid/telego/objects"
+	"github.com/stretchr/testify/require"
+
+	"github.com/buglloc/sowettybot/internal/config"
+	"github.com/buglloc/sowettybot/internal/storage"
+)
+
+// flakyMessenger fails sends with the queued errors first, then records them.
+type flakyMessenger struct {
+	Messenger
+	mu   sync.Mutex
+	errs []error
+	sent []string
+}
+
+func (m *flakyMessenger) SendMdMessage(_ int, text string, _ int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if len(m.errs) > 0 {
+		err := m.errs[0]
+		m.errs = m.errs[1:]
+		if err != nil {
+			return err
+		}
+	}
+
+	m.sent = append(m.sent, text)
+	return nil
+}
+
+func (m *flakyMessenger) delivered() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return append([]string(nil), m.sent...)
+}
+
+func tgFailure(code int, desc string) error {
+	return &tgerrors.MethodNotSentError{
+		Method:        "sendMessage",
+		FailureResult: &objs.FailureResult{ErrorCode: code, Description: desc},
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	o := NewOutbox(nil, storage.NewStorage(""), config.SendLimits{})
+	cases := []struct {
+		name  string
+		err   error
+		delay time.Duration
+		retry bool
+	}{
+		{name: "ok"},
+		{name: "flood", err: tgFailure(429, "Too Many Requests: retry after 7"), delay: 7 * time.Second, retry: true},
+		{name: "flood_no_delay", err: tgFailure(429, "Too Many Requests"), delay: time.Second, retry: true},
+		{name: "server", err: tgFailure(502, "Bad Gateway")},
+		{name: "dial", err: &tgerrors.MethodNotSentError{Method: "sendMessage", Reason: `Post "https://api.telegram.org": dial tcp: lookup api.telegram.org: no such host`}, delay: 4 * time.Second, retry: true},
+		{name: "reset", err: &tgerrors.MethodNotSentError{Method: "sendMessage", Reason: "read tcp: connection reset by peer"}},
+		{name: "blocked", err: tgFailure(403, "Forbidden: bot was blocked by the user")},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			delay, retry := o.retryDelay(tc.err, 3)
+			require.Equal(t, tc.retry, retry)
+			require.Equal(t, tc.delay, delay)
+		})
+	}
+}
+
+func TestOutboxRetriesDirectSends(t *testing.T) {
+	bot := &flakyMessenger{errs: []error{&tgerrors.MethodNotSentError{Method: "sendMessage", Reason: "dial tcp: connect: connection refused"}, nil}}
+	o := NewOutbox(bot, storage.NewStorage(""), config.SendLimits{})
+	o.minBackoff = time.Millisecond
+
+	require.NoError(t, o.SendMdMessage(1, "hello", 0))
+	require.Equal(t, []string{"hello"}, bot.delivered())
+
+	bot.errs = []error{tgFailure(400, "Bad Request: chat not found")}
+	require.Error(t, o.SendMdMessage(1, "lost", 0))
+	require.Equal(t, []string{"hello"}, bot.delivered())
+}
+
+func TestOutboxSplitsLongMessages(t *testing.T) {
+	line := strings.Repeat("a", 100)
+	text := strings.Repeat(line+"\n", 60) + line
+	parts := splitMdMessage(text, tgMaxMessageLen)
+	require.Len(t, parts, 2)
+
+	// the second part fails once, the first one isn't sent again
+	bot := &flakyMessenger{errs: []error{nil, &tgerrors.MethodNotSentError{Method: "sendMessage", Reason: "dial tcp: connect: connection refused"}}}
+	o := NewOutbox(bot, storage.NewStorage(""), config.SendLimits{})
+	o.minBackoff = time.Millisecond
+	require.NoError(t, o.SendMdMessage(1, text, 0))
+	require.Equal(t, parts, bot.delivered())
+
+	queued := NewOutbox(nil, storage.NewStorage(""), config.SendLimits{})
+	require.NoError(t, queued.Enqueue(1, text))
+	require.Equal(t, 2, queued.Len())
+}
+
+func TestOutboxQueueSurvivesRestart(t *testing.T) {
+	store := storage.NewStorage(t.TempDir())
+	down := &flakyMessenger{errs: []error{
+		&tgerrors.MethodNotSentError{Method: "sendMessage", Reason: "connection refused"},
+	}}
+	o := NewOutbox(down, store, config.SendLimits{})
+	require.NoError(t, o.Initialize())
+	require.NoError(t, o.Queued().SendMdMessage(1, "first", 0))
+	require.NoError(t, o.Queued().SendMdMessage(1, "second", 0))
+	require.NoError(t, o.Queued().SendMdMessage(2, "other", 0))
+
+	// the failed message holds back the next one to the same chat only
+	o.deliverDue(context.Background())
+	require.Equal(t, []string{"other"}, down.delivered())
+	require.Equal(t, 2, o.Len())
+
+	up := &flakyMessenger{}
+	restarted := NewOutbox(up, store, config.SendLimits{})
+	require.NoError(t, restarted.Initialize())
+	require.Equal(t, 2, restarted.Len())
+
+	restarted.mu.Lock()
+	restarted.queue[0].NextTry = time.Now()
+	restarted.mu.Unlock()
+
+	restarted.deliverDue(context.Background())
+	require.Equal(t, []string{"first", "second"}, up.delivered())
+	require.Equal(t, 0, restarted.Len())
+}
+
+func TestOutboxFloodWaitPerChat(t *testing.T) {
+	o := NewOutbox(nil, storage.NewStorage(""), config.SendLimits{})
+	flood := tgFailure(429, "Too Many Requests: retry after 30")
+	require.Equal(t, flood, o.try(context.Background(), 1, func() error { return flood }))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	// the flooded chat waits, the others don't
+	require.NoError(t, o.try(ctx, 2, func() error { return nil }))
+	require.ErrorIs(t, o.try(ctx, 1, func() error { return nil }), context.DeadlineExceeded)
+}
+
+func TestOutboxPausesMutedChats(t *testing.T) {
+	bot := &flakyMessenger{}
+	o := NewOutbox(bot, storage.NewStorage(""), config.SendLimits{})
+	muted := true
+	o.PauseMuted(func(chatID int) bool {
+		return chatID == 1 && muted
+	})
+
+	require.ErrorIs(t, o.SendMdMessage(1, "direct", 0), errChatMuted)
+	require.NoError(t, o.Enqueue(1, "queued"))
+	require.NoError(t, o.Enqueue(2, "other"))
+
+	// the queued message waits for the chat to be unmuted
+	require.Equal(t, outboxMaxBackoff, o.deliverDue(context.Background()))
+	require.Equal(t, []string{"other"}, bot.delivered())
+	require.Equal(t, 1, o.Len())
+
+	muted = false
+	o.deliverDue(context.Background())
+	require.Equal(t, []string{"other", "queued"}, bot.delivered())
+}
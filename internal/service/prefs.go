@@ -0,0 +1,245 @@
+package service
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/buglloc/sowettybot/internal/config"
+	"github.com/buglloc/sowettybot/internal/i18n"
+	"github.com/buglloc/sowettybot/internal/renderer"
+	"github.com/buglloc/sowettybot/internal/storage"
+)
+
+const (
+	prefsStateName = "prefs"
+	// langsStateName is the state of /lang overrides kept before preferences, migrated on the first start
+	langsStateName = "langs"
+)
+
+type ChartSize string
+
+const (
+	ChartSizeSmall  ChartSize = "small"
+	ChartSizeMedium ChartSize = "medium"
+	ChartSizeLarge  ChartSize = "large"
+)
+
+var chartSizes = []ChartSize{ChartSizeSmall, ChartSizeMedium, ChartSizeLarge}
+
+// Height is also the width of each 192 entries, so long ranges get wider charts.
+func (s ChartSize) Height() int {
+	switch s {
+	case ChartSizeSmall:
+		return 384
+	case ChartSizeLarge:
+		return 768
+	default:
+		return 512
+	}
+}
+
+// Preferences are per user defaults, channels have their own ones. Zero values mean defaults.
+type Preferences struct {
+	Lang      i18n.Lang `json:"lang,omitempty"`
+	Timezone  string    `json:"timezone,omitempty"`
+	Exchanges []string  `json:"exchanges,omitempty"`
+	// ChartRange is the /history range when none is given
+	ChartRange ChartRange `json:"chart_range,omitempty"`
+	ChartSize  ChartSize  `json:"chart_size,omitempty"`
+	HideSMA    bool       `json:"hide_sma,omitempty"`
+	Dark       bool       `json:"dark,omitempty"`
+}
+
+func (p Preferences) IsZero() bool {
+	return p.Lang == "" && p.Timezone == "" && len(p.Exchanges) == 0 && p.ChartRange == "" &&
+		p.ChartSize == "" && !p.HideSMA && !p.Dark
+}
+
+// IsFavourite reports whether the exchange is shown by default, all of them are if none is picked.
+func (p Preferences) IsFavourite(slug string) bool {
+	if len(p.Exchanges) == 0 {
+		return true
+	}
+
+	for _, s := range p.Exchanges {
+		if s == slug {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ToggleFavourite shows or hides the exchange, hiding the last one shows all of them again.
+func (p *Preferences) ToggleFavourite(exchanges []config.Exchange, slug string) {
+	var out []string
+	for _, ex := range exchanges {
+		isFav := p.IsFavourite(ex.Slug)
+		if ex.Slug == slug {
+			isFav = !isFav
+		}
+
+		if isFav {
+			out = append(out, ex.Slug)
+		}
+	}
+
+	if len(out) == len(exchanges) {
+		out = nil
+	}
+	p.Exchanges = out
+}
+
+// ChartView applies the preferred range and hides the rest of exchanges, fallback is used when no range is preferred.
+func (p Preferences) ChartView(exchanges []config.Exchange, fallback ChartRange) ChartView {
+	view := ChartView{Range: fallback}
+	if p.ChartRange != "" {
+		view.Range = p.ChartRange
+	}
+
+	for i, ex := range exchanges {
+		if !p.IsFavourite(ex.Slug) {
+			view = view.Toggle(i)
+		}
+	}
+
+	return view
+}
+
+func (p Preferences) GraphConfig(entries int) *renderer.GraphConfig {
+	height := p.ChartSize.Height()
+	width := math.Ceil(float64(entries)/192) * float64(height)
+	return renderer.NewGraphConfig().
+		Width(int(width)).
+		Height(height).
+		WithSMA(!p.HideSMA).
+		Dark(p.Dark)
+}
+
+// graphKey tells apart charts of the same entries rendered with different preferences.
+func (p Preferences) graphKey() string {
+	return fmt.Sprintf("%d:%t:%t", p.ChartSize.Height(), !p.HideSMA, p.Dark)
+}
+
+// PreferenceStore keeps Preferences by user id, or by chat id for channels. A private chat id is
+// the user one, so background jobs like digests look the chat id up.
+type PreferenceStore struct {
+	storage  *storage.Storage
+	fallback i18n.Lang
+	mu       sync.Mutex
+	prefs    map[int]Preferences
+}
+
+func NewPreferenceStore(store *storage.Storage, fallbackLang string) *PreferenceStore {
+	lang, ok := i18n.ParseLang(fallbackLang)
+	if !ok {
+		lang = i18n.LangEN
+	}
+
+	return &PreferenceStore{
+		storage:  store,
+		fallback: lang,
+		prefs:    make(map[int]Preferences),
+	}
+}
+
+func (s *PreferenceStore) Initialize() error {
+	var prefs map[int]Preferences
+	if err := s.storage.Load(prefsStateName, &prefs); err != nil {
+		return fmt.Errorf("unable to load preferences: %w", err)
+	}
+
+	migrate := prefs == nil
+	if migrate {
+		prefs = make(map[int]Preferences)
+		langs := make(map[int]i18n.Lang)
+		if err := s.storage.Load(langsStateName, &langs); err != nil {
+			return fmt.Errorf("unable to load languages: %w", err)
+		}
+
+		for chatID, lang := range langs {
+			prefs[chatID] = Preferences{Lang: lang}
+		}
+		migrate = len(langs) > 0
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.prefs = prefs
+	if migrate {
+		return s.lockedSave()
+	}
+
+	return nil
+}
+
+func (s *PreferenceStore) Get(chatID int) Preferences {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.prefs[chatID]
+}
+
+// Update changes the chat preferences with fn and saves them, returns the updated ones.
+func (s *PreferenceStore) Update(chatID int, fn func(p *Preferences)) (Preferences, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	p := s.prefs[chatID]
+	fn(&p)
+	if p.IsZero() {
+		delete(s.prefs, chatID)
+	} else {
+		s.prefs[chatID] = p
+	}
+
+	return p, s.lockedSave()
+}
+
+// For picks the preferred language first, then the user language code and the configured default at last.
+func (s *PreferenceStore) For(chatID int, code string) *i18n.Localizer {
+	if lang := s.Get(chatID).Lang; lang != "" {
+		return i18n.NewLocalizer(lang)
+	}
+
+	if lang, ok := i18n.ParseLang(code); ok {
+		return i18n.NewLocalizer(lang)
+	}
+
+	return i18n.NewLocalizer(s.fallback)
+}
+
+// Location returns the preferred time zone, nil if there is none.
+func (s *PreferenceStore) Location(chatID int) *time.Location {
+	tz := s.Get(chatID).Timezone
+	if tz == "" {
+		return nil
+	}
+
+	loc, err := time.LoadLocation(tz)
+	if err != nil {
+		return nil
+	}
+
+	return loc
+}
+
+// chatLocation returns the time zone of the chat: the preferred one, else the digest one or the default.
+func chatLocation(prefs *PreferenceStore, digester *Digester, chatID int) *time.Location {
+	if tz := prefs.Location(chatID); tz != nil {
+		return tz
+	}
+
+	return digester.Location(chatID)
+}
+
+func (s *PreferenceStore) lockedSave() error {
+	if err := s.storage.Save(prefsStateName, s.prefs); err != nil {
+		return fmt.Errorf("unable to save preferences: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,51 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/buglloc/sowettybot/internal/config"
+	"github.com/buglloc/sowettybot/internal/i18n"
+	"github.com/buglloc/sowettybot/internal/storage"
+)
+
+func TestPreferencesMigrateLangs(t *testing.T) {
+	store := storage.NewStorage(t.TempDir())
+	require.NoError(t, store.Save(langsStateName, map[int]i18n.Lang{1: i18n.LangRU}))
+
+	prefs := NewPreferenceStore(store, "en")
+	require.NoError(t, prefs.Initialize())
+	require.Equal(t, i18n.LangRU, prefs.Get(1).Lang)
+
+	// the old state is not applied twice
+	_, err := prefs.Update(1, func(p *Preferences) { p.Lang = "" })
+	require.NoError(t, err)
+
+	prefs = NewPreferenceStore(store, "en")
+	require.NoError(t, prefs.Initialize())
+	require.True(t, prefs.Get(1).IsZero())
+}
+
+func TestPreferencesToggleFavourite(t *testing.T) {
+	exchanges := []config.Exchange{{Slug: "a"}, {Slug: "b"}, {Slug: "c"}}
+	cases := []struct {
+		name   string
+		in     []string
+		toggle string
+		out    []string
+	}{
+		{name: "hide", in: nil, toggle: "b", out: []string{"a", "c"}},
+		{name: "show", in: []string{"a"}, toggle: "c", out: []string{"a", "c"}},
+		{name: "show all", in: []string{"a", "c"}, toggle: "b", out: nil},
+		{name: "hide last", in: []string{"a"}, toggle: "a", out: nil},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			p := Preferences{Exchanges: tc.in}
+			p.ToggleFavourite(exchanges, tc.toggle)
+			require.Equal(t, tc.out, p.Exchanges)
+		})
+	}
+}
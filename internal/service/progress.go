@@ -0,0 +1,126 @@
+package service
+
+import (
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	ChatActionTyping      = "typing"
+	ChatActionUploadPhoto = "upload_photo"
+	// progressEditInterval keeps edits within Telegram limits, intermediate updates are dropped
+	progressEditInterval = time.Second
+	// chatActionInterval is a bit less than 5 seconds Telegram shows the action for
+	chatActionInterval = 4 * time.Second
+)
+
+// Progress is a placeholder reply edited as the work goes, so slow handlers show signs of life.
+type Progress struct {
+	bot     Messenger
+	chatID  int
+	replyTo int
+	mu      sync.Mutex
+	msgID   int
+	text    string
+	edited  time.Time
+}
+
+// NewProgress sends the placeholder, if it fails the final text is sent as a new message.
+func NewProgress(bot Messenger, chatID int, replyTo int, text string) *Progress {
+	p := &Progress{
+		bot:     bot,
+		chatID:  chatID,
+		replyTo: replyTo,
+		text:    text,
+		edited:  time.Now(),
+	}
+
+	msgID, err := bot.PostMdMessage(chatID, text, replyTo)
+	if err != nil {
+		log.Error().Err(err).Int("chat_id", chatID).Msg("unable to send progress placeholder")
+	}
+
+	p.msgID = msgID
+	return p
+}
+
+// Update edits the placeholder unless it was edited less than a second ago.
+func (p *Progress) Update(text string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.msgID == 0 || text == p.text || time.Since(p.edited) < progressEditInterval {
+		return
+	}
+
+	if err := p.bot.EditMdMessage(p.chatID, p.msgID, text); err != nil && !isNotModified(err) {
+		log.Error().Err(err).Int("chat_id", p.chatID).Msg("unable to update progress")
+		return
+	}
+
+	p.text = text
+	p.edited = time.Now()
+}
+
+// Finish replaces the placeholder with the final text.
+func (p *Progress) Finish(text string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.msgID == 0 {
+		return p.bot.SendMdMessage(p.chatID, text, p.replyTo)
+	}
+
+	if text == p.text {
+		return nil
+	}
+
+	err := p.bot.EditMdMessage(p.chatID, p.msgID, text)
+	if err != nil && !isNotModified(err) {
+		log.Error().Err(err).Int("chat_id", p.chatID).Msg("unable to finish progress, sending a new message")
+		return p.bot.SendMdMessage(p.chatID, text, p.replyTo)
+	}
+
+	p.text = text
+	return nil
+}
+
+// keepChatAction shows the chat action ("typing", "upload_photo") until stop is called.
+// The first action is sent right away, so it can't come after whatever the caller sends next,
+// and stop waits for the repeats to end.
+func keepChatAction(bot Messenger, chatID int, action string) (stop func()) {
+	if err := bot.ChatAction(chatID, action); err != nil {
+		log.Warn().Err(err).Int("chat_id", chatID).Msg("unable to send chat action")
+		return func() {}
+	}
+
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+
+		ticker := time.NewTicker(chatActionInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+			case <-done:
+				return
+			}
+
+			if err := bot.ChatAction(chatID, action); err != nil {
+				log.Warn().Err(err).Int("chat_id", chatID).Msg("unable to send chat action")
+				return
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() { close(done) })
+		<-stopped
+	}
+}
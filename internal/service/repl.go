@@ -0,0 +1,126 @@
+package service
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/SakoDroid/telego/objects"
+
+	"github.com/buglloc/sowettybot/internal/config"
+)
+
+const (
+	replChatID = 1
+	replHelp   = `Type commands as in a private chat with the bot, e.g. /rates
+  @<query>  runs an inline query, e.g. "@chart 3d"
+  !<data>   presses a button of the last message with buttons
+  Ctrl+D    exits
+`
+)
+
+// Repl runs the command handlers against a terminal instead of Telegram.
+// The state is kept in memory, access control and rate limits are off, scheduled jobs don't run.
+type Repl struct {
+	service *Service
+	console *Console
+	in      io.Reader
+	out     io.Writer
+	lang    string
+	nextID  int
+}
+
+func NewRepl(cfg *config.Config, in io.Reader, out io.Writer) (*Repl, error) {
+	replCfg := *cfg
+	replCfg.Storage.Dir = ""
+	replCfg.Access.Enabled = false
+	replCfg.Limits.User = config.RateLimit{}
+	replCfg.Limits.Chat = config.RateLimit{}
+	replCfg.Limits.Send = config.SendLimits{}
+
+	console := NewConsole(out)
+	s, err := newService(&replCfg, console)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Repl{
+		service: s,
+		console: console,
+		in:      in,
+		out:     out,
+		lang:    cfg.Language,
+	}, nil
+}
+
+func (r *Repl) Run(ctx context.Context) error {
+	if err := r.service.initialize(); err != nil {
+		return err
+	}
+
+	_, _ = fmt.Fprint(r.out, replHelp)
+	scanner := bufio.NewScanner(r.in)
+	for {
+		_, _ = fmt.Fprint(r.out, "> ")
+		if !scanner.Scan() {
+			_, _ = fmt.Fprintln(r.out)
+			return scanner.Err()
+		}
+
+		if ctx.Err() != nil {
+			return nil
+		}
+
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		r.Handle(line)
+	}
+}
+
+// Handle passes the line to the handlers as a message, an inline query or a button press.
+func (r *Repl) Handle(line string) {
+	r.nextID++
+	from := objects.User{
+		Id:           replChatID,
+		FirstName:    "repl",
+		LanguageCode: r.lang,
+	}
+	chat := &objects.Chat{Id: replChatID, Type: "private"}
+
+	switch {
+	case strings.HasPrefix(line, "@"):
+		r.service.handlers.HandleInline(&objects.Update{
+			InlineQuery: &objects.InlineQuery{
+				Id:    fmt.Sprint(r.nextID),
+				From:  &from,
+				Query: strings.TrimSpace(line[1:]),
+			},
+		})
+	case strings.HasPrefix(line, "!"):
+		ok := r.service.handlers.HandleCallback(&objects.Update{
+			CallbackQuery: &objects.CallbackQuery{
+				Id:      fmt.Sprint(r.nextID),
+				From:    from,
+				Message: objects.Message{MessageId: r.console.Keyboard(), Chat: chat},
+				Data:    line[1:],
+			},
+		})
+		if !ok {
+			_, _ = fmt.Fprintf(r.out, "unsupported button %q\n", line[1:])
+		}
+	default:
+		r.service.handlers.HandleMessage(&objects.Update{
+			Message: &objects.Message{
+				MessageId: r.nextID,
+				From:      &from,
+				Chat:      chat,
+				Text:      line,
+			},
+		})
+	}
+}
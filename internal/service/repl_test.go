@@ -0,0 +1,25 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/buglloc/sowettybot/internal/config"
+)
+
+func TestRepl(t *testing.T) {
+	cfg, err := config.LoadConfig()
+	require.NoError(t, err)
+
+	var out bytes.Buffer
+	repl, err := NewRepl(cfg, strings.NewReader("/chatid\n/rule add korona < 3\n/rule list\n"), &out)
+	require.NoError(t, err)
+	require.NoError(t, repl.Run(context.Background()))
+
+	require.Contains(t, out.String(), "chat id: `1`")
+	require.Contains(t, out.String(), "1. korona < 3")
+}
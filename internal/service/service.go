@@ -8,6 +8,8 @@ import (
 
 	"github.com/SakoDroid/telego"
 	"github.com/SakoDroid/telego/configs"
+	tglogger "github.com/SakoDroid/telego/logger"
+	"github.com/SakoDroid/telego/objects"
 	"github.com/jellydator/ttlcache/v3"
 	"github.com/rs/zerolog/log"
 
@@ -15,26 +17,30 @@ import (
 	"github.com/buglloc/sowettybot/internal/history"
 	"github.com/buglloc/sowettybot/internal/models"
 	"github.com/buglloc/sowettybot/internal/rateit"
+	"github.com/buglloc/sowettybot/internal/ratelimit"
 	"github.com/buglloc/sowettybot/internal/renderer"
+	"github.com/buglloc/sowettybot/internal/scheduler"
+	"github.com/buglloc/sowettybot/internal/storage"
 )
 
 type Service struct {
 	handlers  *CommandsHandler
 	notifier  *Notifier
+	digester  *Digester
+	live      *LiveBoards
+	groups    *Groups
+	prefs     *PreferenceStore
+	access    *Access
+	outbox    *Outbox
+	scheduler *scheduler.Scheduler
 	bot       *BotWrapper
+	webhook   *Webhook
 	closed    chan struct{}
 	ctx       context.Context
 	cancelCtx context.CancelFunc
 }
 
 func NewService(cfg *config.Config) (*Service, error) {
-	rtc, err := rateit.NewClient(
-		rateit.WithUpstream(cfg.RateIT.Upstream),
-	)
-	if err != nil {
-		return nil, fmt.Errorf("unable to create rateit client: %w", err)
-	}
-
 	up := configs.DefaultUpdateConfigs()
 	botCfg := configs.BotConfigs{
 		BotAPI:         configs.DefaultBotAPI,
@@ -49,34 +55,131 @@ func NewService(cfg *config.Config) (*Service, error) {
 		return nil, fmt.Errorf("unable to create bot: %w", err)
 	}
 
-	notifications := make([]*Notification, len(cfg.Notifier.Notifications))
-	for i, n := range cfg.Notifier.Notifications {
-		notifications[i] = NewNotification(n)
+	var webhook *Webhook
+	if cfg.Telegram.Webhook.Enabled {
+		webhook, err = NewWebhook(cfg.Telegram.Webhook)
+		if err != nil {
+			return nil, fmt.Errorf("unable to create webhook: %w", err)
+		}
+
+		// telego initializes its logger in Run, which is for long polling only
+		tglogger.InitTheLogger(&botCfg)
+	}
+
+	bw := &BotWrapper{
+		Bot:    bot,
+		apiURL: botCfg.BotAPI + botCfg.APIKey,
+	}
+	s, err := newService(cfg, bw)
+	if err != nil {
+		return nil, err
+	}
+
+	s.bot = bw
+	s.webhook = webhook
+	return s, nil
+}
+
+// newService wires the components on top of the messenger, receiving updates is up to the caller.
+func newService(cfg *config.Config, bw Messenger) (*Service, error) {
+	rtc, err := rateit.NewClient(
+		rateit.WithUpstream(cfg.RateIT.Upstream),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create rateit client: %w", err)
+	}
+
+	checkPeriod := cfg.Notifier.CheckPeriod
+	if checkPeriod <= 0 {
+		checkPeriod = 1 * time.Minute
+	}
+
+	series := make([]string, len(cfg.Exchanges))
+	for i, ex := range cfg.Exchanges {
+		series[i] = ex.Slug
+	}
+
+	if cfg.Storage.Dir == "" {
+		log.Warn().Msg("storage dir is not set, the bot state will be lost on restart")
+	}
+
+	store := storage.NewStorage(cfg.Storage.Dir)
+	outbox := NewOutbox(bw, store, cfg.Limits.Send)
+	notifications, err := NewNotifications(cfg.Notifier, series, outbox.Queued())
+	if err != nil {
+		return nil, fmt.Errorf("unable to create notifications: %w", err)
 	}
 
-	bw := &BotWrapper{Bot: bot}
 	hist := history.NewHistory(cfg.History.StorageFile, cfg.Limits.History.Overall)
+	hr := renderer.NewHistoryRenderer()
+	sched := scheduler.NewScheduler()
+	prefs := NewPreferenceStore(store, cfg.Language)
+	access := NewAccess(cfg.Access, outbox, store, prefs)
+	digester := &Digester{
+		bot:       outbox,
+		history:   hist,
+		renderer:  hr,
+		storage:   store,
+		scheduler: sched,
+		exchanges: cfg.Exchanges,
+		prefs:     prefs,
+		defaultTZ: cfg.Digest.Timezone,
+	}
+
+	live := &LiveBoards{
+		bot:       outbox,
+		history:   hist,
+		renderer:  hr,
+		storage:   store,
+		exchanges: cfg.Exchanges,
+		prefs:     prefs,
+		digester:  digester,
+	}
+
+	notifier := &Notifier{
+		alerts:        outbox.Queued(),
+		history:       hist,
+		storage:       store,
+		series:        series,
+		notifications: notifications,
+		prefs:         prefs,
+		digester:      digester,
+		checkPeriod:   checkPeriod,
+	}
 
 	ctx, cancel := context.WithCancel(context.Background())
 	return &Service{
 		handlers: &CommandsHandler{
-			bot:       bw,
+			bot:       outbox,
 			rtc:       rtc,
 			history:   hist,
-			renderer:  renderer.NewHistoryRenderer(),
+			renderer:  hr,
 			exchanges: cfg.Exchanges,
 			limits:    cfg.Limits,
+			best:      cfg.Best,
 			ratesCache: ttlcache.New[string, models.Rate](
 				ttlcache.WithTTL[string, models.Rate](5 * time.Minute),
 			),
+			digester:   digester,
+			notifier:   notifier,
+			live:       live,
+			charts:     NewChartCache(cfg.Telegram.MediaChatID),
+			prefs:      prefs,
+			access:     access,
+			userLimits: ratelimit.NewLimiter(cfg.Limits.User.Burst, cfg.Limits.User.Every),
+			chatLimits: ratelimit.NewLimiter(cfg.Limits.Chat.Burst, cfg.Limits.Chat.Every),
+			renders:    newRenderSlots(cfg.Limits.ChartRenders),
+			outbox:     outbox,
+			startedAt:  time.Now(),
 		},
-		notifier: &Notifier{
-			bot:           bw,
-			history:       hist,
-			notifications: notifications,
-			checkPeriod:   cfg.Notifier.CheckPeriod,
-		},
-		bot:       bw,
+		notifier:  notifier,
+		digester:  digester,
+		live:      live,
+		groups:    &Groups{bot: outbox, storage: store, prefs: prefs},
+		prefs:     prefs,
+		access:    access,
+		outbox:    outbox,
+		scheduler: sched,
 		closed:    make(chan struct{}),
 		ctx:       ctx,
 		cancelCtx: cancel,
@@ -84,45 +187,162 @@ func NewService(cfg *config.Config) (*Service, error) {
 }
 
 func (s *Service) Start() error {
-	if err := s.bot.Run(); err != nil {
+	updateChannel, err := s.receiveUpdates()
+	if err != nil {
 		return fmt.Errorf("run failed: %w", err)
 	}
 
 	defer close(s.closed)
 
-	if err := s.notifier.Initialize(); err != nil {
-		return fmt.Errorf("unable to register handlers: %w", err)
+	if err := s.initialize(); err != nil {
+		return err
 	}
 
-	if err := s.handlers.Initialize(); err != nil {
-		return fmt.Errorf("unable to register handlers: %w", err)
-	}
+	s.scheduler.Add("handlers", scheduler.Every(1*time.Minute), s.handlers.Tick)
+	s.scheduler.Add("notifier", scheduler.Every(s.notifier.checkPeriod), s.notifier.Tick)
+	s.scheduler.Add("live", scheduler.Every(1*time.Minute), s.live.Tick)
+
+	schedulerDone := make(chan struct{})
+	go func() {
+		defer close(schedulerDone)
+		s.scheduler.Run(s.ctx)
+	}()
+	defer func() { <-schedulerDone }()
 
-	updateTicker := time.NewTicker(1 * time.Minute)
-	defer updateTicker.Stop()
+	outboxDone := make(chan struct{})
+	go func() {
+		defer close(outboxDone)
+		s.outbox.Run(s.ctx)
+	}()
+	defer func() { <-outboxDone }()
 
-	//Monitores any other update
-	updateChannel := *s.bot.GetUpdateChannel()
 	for {
 		select {
-		case <-updateTicker.C:
-			s.handlers.Tick()
-			s.notifier.Tick()
 		case u := <-updateChannel:
-			if u.Message != nil {
-				_, _ = s.bot.SendMessage(u.Message.Chat.Id, "Sorry, unsupported command", "", u.Message.MessageId, false, false)
+			switch {
+			case u.Message != nil, u.ChannelPost != nil:
+				dispatch("message", func() { s.handlers.HandleMessage(u) })
+			case u.InlineQuery != nil:
+				dispatch("inline", func() { s.handlers.HandleInline(u) })
+			case u.CallbackQuery != nil:
+				dispatch("callback", func() {
+					if !s.handlers.HandleCallback(u) {
+						log.Warn().Str("data", u.CallbackQuery.Data).Msg("receive unsupported callback query")
+					}
+				})
+			case u.MyChatMember != nil:
+				dispatch("my_chat_member", func() { s.groups.HandleMyChatMember(u) })
+			default:
+				log.Warn().Str("type", u.GetType()).Msg("receive unsupported update")
 			}
-
-			log.Warn().Str("type", u.GetType()).Msg("receive unsupported update")
 		case <-s.ctx.Done():
 			return nil
 		}
 	}
 }
 
+// dispatch handles the update off the update loop, so a slow handler doesn't hold up the others
+// and a panicking one doesn't take the bot down.
+func dispatch(name string, handle func()) {
+	go func() {
+		defer func() {
+			if err := recover(); err != nil {
+				log.Error().
+					Any("err", err).
+					Str("handler", name).
+					Msg("panic occurred")
+			}
+		}()
+
+		handle()
+	}()
+}
+
+// initialize loads the state and registers the handlers.
+func (s *Service) initialize() error {
+	if err := s.outbox.Initialize(); err != nil {
+		return fmt.Errorf("unable to initialize outbox: %w", err)
+	}
+
+	if err := s.prefs.Initialize(); err != nil {
+		return fmt.Errorf("unable to initialize preferences: %w", err)
+	}
+
+	if err := s.access.Initialize(); err != nil {
+		return fmt.Errorf("unable to initialize access: %w", err)
+	}
+
+	if err := s.notifier.Initialize(); err != nil {
+		return fmt.Errorf("unable to register handlers: %w", err)
+	}
+
+	if err := s.digester.Initialize(); err != nil {
+		return fmt.Errorf("unable to initialize digests: %w", err)
+	}
+
+	if err := s.live.Initialize(); err != nil {
+		return fmt.Errorf("unable to initialize live boards: %w", err)
+	}
+
+	if err := s.groups.Initialize(); err != nil {
+		return fmt.Errorf("unable to initialize groups: %w", err)
+	}
+	s.groups.OnLeave(s.forgetChat)
+	s.outbox.PauseMuted(s.groups.Muted)
+
+	if err := s.handlers.Initialize(); err != nil {
+		return fmt.Errorf("unable to register handlers: %w", err)
+	}
+
+	return nil
+}
+
+// receiveUpdates starts long polling, or the webhook server if configured.
+func (s *Service) receiveUpdates() (<-chan *objects.Update, error) {
+	if s.webhook == nil {
+		if err := s.bot.Run(); err != nil {
+			return nil, err
+		}
+
+		return *s.bot.GetUpdateChannel(), nil
+	}
+
+	if err := s.webhook.Listen(); err != nil {
+		return nil, err
+	}
+
+	if err := s.bot.SetWebhook(s.webhook.cfg.URL, s.webhook.cfg.SecretToken); err != nil {
+		_ = s.webhook.Shutdown(context.Background())
+		return nil, fmt.Errorf("set webhook: %w", err)
+	}
+
+	log.Info().Str("addr", s.webhook.Addr()).Str("url", s.webhook.cfg.URL).Msg("webhook started")
+	return s.webhook.Updates(), nil
+}
+
+func (s *Service) forgetChat(chatID int) {
+	if _, err := s.digester.Unsubscribe(chatID); err != nil {
+		log.Error().Err(err).Int("chat_id", chatID).Msg("unable to drop digest")
+	}
+
+	if _, err := s.live.Forget(chatID); err != nil {
+		log.Error().Err(err).Int("chat_id", chatID).Msg("unable to drop live board")
+	}
+
+	if _, err := s.notifier.ForgetChat(chatID); err != nil {
+		log.Error().Err(err).Int("chat_id", chatID).Msg("unable to drop chat rules")
+	}
+}
+
 func (s *Service) Shutdown(ctx context.Context) error {
 	s.cancelCtx()
 
+	if s.webhook != nil {
+		if err := s.webhook.Shutdown(ctx); err != nil {
+			log.Error().Err(err).Msg("webhook shutdown failed")
+		}
+	}
+
 	select {
 	case <-ctx.Done():
 		return errors.New("shutdown time out")
@@ -0,0 +1,263 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/SakoDroid/telego/objects"
+	"github.com/rs/zerolog/log"
+
+	"github.com/buglloc/sowettybot/internal/i18n"
+)
+
+const settingsCallbackPrefix = "settings:"
+
+// settingsRanges are the /history defaults to pick from, the empty one is the config default
+var settingsRanges = []ChartRange{"", ChartRangeDay, ChartRangeWeek, ChartRangeMonth, ChartRangeAll}
+
+// cycle returns the option next to the current one, the first one if current isn't among them.
+func cycle[T comparable](options []T, current T) T {
+	for i, opt := range options {
+		if opt == current {
+			return options[(i+1)%len(options)]
+		}
+	}
+
+	return options[0]
+}
+
+func (h *CommandsHandler) handleSettings(u *objects.Update) {
+	loc := h.localizer(u)
+	chatID := u.Message.Chat.Id
+	key := prefsKey(u)
+	reply, err := func() (string, error) {
+		args := newCmdArgs(u.Message.Text)
+		if args.Len() == 0 {
+			text, buttons := h.settingsView(loc, h.location(key, chatID), h.prefs.Get(key))
+			_, err := h.bot.PostMdMessageWithButtons(chatID, text, u.Message.MessageId, buttons)
+			return "", err
+		}
+
+		cmd, ok := args.Keyword("tz", "reset")
+		if !ok {
+			return h.invalidArgs(loc, i18n.Errorf("settings.err.action", args.Peek()), "settings"), nil
+		}
+
+		var tz string
+		if cmd == "tz" {
+			if args.Len() == 0 {
+				return h.invalidArgs(loc, i18n.Errorf("settings.err.timezone_required"), "settings"), nil
+			}
+
+			tz = args.ShiftRaw()
+			if strings.EqualFold(tz, "auto") {
+				tz = ""
+			} else if _, err := time.LoadLocation(tz); err != nil {
+				return h.invalidArgs(loc, i18n.Errorf("settings.err.timezone", tz), "settings"), nil
+			}
+		}
+
+		if err := args.Done(); err != nil {
+			return h.invalidArgs(loc, err, "settings"), nil
+		}
+
+		if cmd == "reset" {
+			if _, err := h.prefs.Update(key, func(p *Preferences) { *p = Preferences{} }); err != nil {
+				return "", err
+			}
+
+			return h.localizer(u).T("settings.reset"), nil
+		}
+
+		if _, err := h.prefs.Update(key, func(p *Preferences) { p.Timezone = tz }); err != nil {
+			return "", err
+		}
+
+		if tz == "" {
+			return loc.T("settings.tz.auto"), nil
+		}
+
+		return loc.T("settings.tz.set", tz), nil
+	}()
+
+	if err != nil {
+		log.Error().Err(err).Int("chat_id", chatID).Msg("failed to handle settings")
+		reply = loc.T("error.generic", err)
+	}
+
+	if reply == "" {
+		return
+	}
+
+	h.reply(u, reply)
+}
+
+// settingsView describes the preferences and makes a button per each of them.
+func (h *CommandsHandler) settingsView(loc *i18n.Localizer, tz *time.Location, prefs Preferences) (string, [][]InlineButton) {
+	onOff := func(on bool) string {
+		if on {
+			return loc.T("settings.on")
+		}
+
+		return loc.T("settings.off")
+	}
+
+	lang := loc.T("settings.auto")
+	if prefs.Lang != "" {
+		lang = i18n.NewLocalizer(prefs.Lang).T("lang.name")
+	}
+
+	tzName := prefs.Timezone
+	if tzName == "" {
+		tzName = loc.T("settings.auto")
+	}
+
+	chartRange := loc.T("settings.default")
+	if prefs.ChartRange != "" {
+		chartRange = prefs.ChartRange.Describe(loc, tz)
+	}
+
+	size := prefs.ChartSize
+	if size == "" {
+		size = ChartSizeMedium
+	}
+
+	exchanges := make([]string, 0, len(h.exchanges))
+	for _, ex := range h.favourites(prefs) {
+		exchanges = append(exchanges, ex.Name)
+	}
+
+	text := loc.T(
+		"settings.summary",
+		lang,
+		tzName,
+		strings.Join(exchanges, ", "),
+		chartRange,
+		loc.T("settings.size."+string(size)),
+		onOff(!prefs.HideSMA),
+		onOff(prefs.Dark),
+	)
+
+	exRow := make([]InlineButton, len(h.exchanges))
+	for i, ex := range h.exchanges {
+		mark := "✓"
+		if !prefs.IsFavourite(ex.Slug) {
+			mark = "✗"
+		}
+
+		exRow[i] = InlineButton{
+			Text: fmt.Sprintf("%s %s", mark, ex.Name),
+			Data: settingsCallbackPrefix + "ex:" + ex.Slug,
+		}
+	}
+
+	return text, [][]InlineButton{
+		{
+			{Text: loc.T("settings.button.lang"), Data: settingsCallbackPrefix + "lang"},
+			{Text: loc.T("settings.button.range"), Data: settingsCallbackPrefix + "range"},
+		},
+		{
+			{Text: loc.T("settings.button.size"), Data: settingsCallbackPrefix + "size"},
+			{Text: loc.T("settings.button.sma"), Data: settingsCallbackPrefix + "sma"},
+			{Text: loc.T("settings.button.dark"), Data: settingsCallbackPrefix + "dark"},
+		},
+		exRow,
+		{
+			{Text: loc.T("settings.button.reset"), Data: settingsCallbackPrefix + "reset"},
+		},
+	}
+}
+
+// applySetting changes the preference the button stands for, it's not ok for unknown buttons.
+func (h *CommandsHandler) applySetting(p *Preferences, data string) bool {
+	setting, arg, _ := strings.Cut(strings.TrimPrefix(data, settingsCallbackPrefix), ":")
+	switch setting {
+	case "lang":
+		langs := append([]i18n.Lang{""}, i18n.Langs...)
+		p.Lang = cycle(langs, p.Lang)
+	case "range":
+		p.ChartRange = cycle(settingsRanges, p.ChartRange)
+	case "size":
+		size := p.ChartSize
+		if size == "" {
+			size = ChartSizeMedium
+		}
+
+		p.ChartSize = cycle(chartSizes, size)
+		if p.ChartSize == ChartSizeMedium {
+			p.ChartSize = ""
+		}
+	case "sma":
+		p.HideSMA = !p.HideSMA
+	case "dark":
+		p.Dark = !p.Dark
+	case "ex":
+		for _, ex := range h.exchanges {
+			if ex.Slug == arg {
+				p.ToggleFavourite(h.exchanges, arg)
+				return true
+			}
+		}
+
+		return false
+	case "reset":
+		*p = Preferences{}
+	default:
+		return false
+	}
+
+	return true
+}
+
+func (h *CommandsHandler) handleSettingsCallback(u *objects.Update) {
+	q := u.CallbackQuery
+	chatID := q.Message.Chat.Id
+	key := callbackPrefsKey(q)
+	loc := h.prefs.For(key, q.From.LanguageCode)
+
+	answer, err := func() (string, error) {
+		if !h.access.Allowed(chatID, q.From.Id) {
+			return loc.T("access.denied"), nil
+		}
+
+		if key == chatID && !h.isChatAdmin(q.Message.Chat, q.From.Id) {
+			return loc.T("error.admin_only"), nil
+		}
+
+		// in groups the settings message shows the preferences of whoever asked for it
+		if owner := q.Message.ReplyToMessage; key != chatID && owner != nil && owner.From != nil && owner.From.Id != key {
+			return loc.T("settings.not_yours"), nil
+		}
+
+		if v := h.allow(chatID, q.From.Id); !v.Allowed {
+			return h.slowDown(loc, v), nil
+		}
+
+		known := true
+		prefs, err := h.prefs.Update(key, func(p *Preferences) {
+			known = h.applySetting(p, q.Data)
+		})
+		if err != nil {
+			return "", err
+		}
+
+		if !known {
+			return loc.T("settings.outdated"), nil
+		}
+
+		// the language may have just changed
+		loc = h.prefs.For(key, q.From.LanguageCode)
+		text, buttons := h.settingsView(loc, h.location(key, chatID), prefs)
+		return "", h.bot.EditMdMessageWithButtons(chatID, q.Message.MessageId, text, buttons)
+	}()
+
+	if err != nil {
+		log.Error().Err(err).Int("chat_id", chatID).Msg("failed to update settings")
+		answer = loc.T("error.generic", err)
+	}
+
+	if err := h.bot.AnswerCallback(q.Id, answer); err != nil {
+		log.Error().Err(err).Int("chat_id", chatID).Msg("unable to answer callback query")
+	}
+}
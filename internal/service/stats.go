@@ -0,0 +1,78 @@
+package service
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/buglloc/sowettybot/internal/models"
+	"github.com/buglloc/sowettybot/internal/stats"
+)
+
+const defaultStatsPeriod = 24 * time.Hour
+
+// buildStats summarizes history within [from, to), slug limits it to a single exchange.
+func (h *CommandsHandler) buildStats(from, to time.Time, slug string) (models.Stats, error) {
+	out := models.Stats{
+		From: from,
+		To:   to,
+	}
+
+	entries, err := h.history.Between(from, to)
+	if err != nil {
+		return out, fmt.Errorf("get entries: %w", err)
+	}
+
+	for _, s := range stats.Summarize(entries) {
+		if s.Count == 0 || (slug != "" && s.Name != slug) {
+			continue
+		}
+
+		ex := models.StatsExchange{
+			Name:       exchangeName(h.exchanges, s.Name),
+			Count:      s.Count,
+			Latest:     s.Last,
+			LatestAt:   s.LastAt,
+			Min:        s.Min,
+			MinAt:      s.MinAt,
+			Max:        s.Max,
+			MaxAt:      s.MaxAt,
+			Mean:       s.Mean,
+			Median:     s.Median,
+			StdDev:     s.StdDev,
+			Volatility: s.Volatility,
+		}
+
+		prev, err := h.valueAt(s.Name, s.LastAt.Add(-24*time.Hour))
+		if err != nil {
+			return out, err
+		}
+
+		if prev != 0 {
+			ex.HasDayChange = true
+			ex.DayChange = s.Last - prev
+			ex.DayChangePct = ex.DayChange / prev * 100
+		}
+
+		out.Exchanges = append(out.Exchanges, ex)
+	}
+
+	return out, nil
+}
+
+// valueAt returns the last known rate of the exchange not later than at, or zero if there is none within a day.
+func (h *CommandsHandler) valueAt(slug string, at time.Time) (float64, error) {
+	entries, err := h.history.Between(at.Add(-24*time.Hour), at)
+	if err != nil {
+		return 0, fmt.Errorf("get entries: %w", err)
+	}
+
+	for i := len(entries) - 1; i >= 0; i-- {
+		for j, name := range entries[i].Names {
+			if name == slug && j < len(entries[i].Values) && entries[i].Values[j] != 0 {
+				return entries[i].Values[j], nil
+			}
+		}
+	}
+
+	return 0, nil
+}
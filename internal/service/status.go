@@ -0,0 +1,100 @@
+package service
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/SakoDroid/telego/objects"
+	"github.com/rs/zerolog/log"
+
+	"github.com/buglloc/sowettybot/internal/buildinfo"
+	"github.com/buglloc/sowettybot/internal/i18n"
+	"github.com/buglloc/sowettybot/internal/models"
+)
+
+func (h *CommandsHandler) handleStatus(u *objects.Update) {
+	loc := h.localizer(u)
+	reply, err := func() (string, error) {
+		status, err := h.buildStatus(loc, time.Now())
+		if err != nil {
+			return "", err
+		}
+
+		return h.renderer.For(loc, h.location(prefsKey(u), u.Message.Chat.Id)).Status(status)
+	}()
+
+	if err != nil {
+		log.Error().Err(err).Int("chat_id", u.Message.Chat.Id).Msg("failed to build status")
+		reply = loc.T("error.generic", err)
+	}
+
+	h.reply(u, reply)
+}
+
+func (h *CommandsHandler) buildStatus(loc *i18n.Localizer, now time.Time) (models.Status, error) {
+	out := models.Status{
+		Version:   buildinfo.String(),
+		StartedAt: h.startedAt,
+		Uptime:    now.Sub(h.startedAt),
+		Rules:     h.notifier.Status(loc, now),
+	}
+
+	last, err := h.history.Entries(1)
+	if err != nil {
+		return out, fmt.Errorf("get entries: %w", err)
+	}
+
+	if len(last) > 0 {
+		out.LastEntryAt = last[0].When
+		out.LastEntryAge = now.Sub(last[0].When)
+	}
+
+	for _, route := range h.rtc.Routes() {
+		name := route.Route
+		for _, ex := range h.exchanges {
+			if ex.Route == route.Route {
+				name = ex.Name
+				break
+			}
+		}
+
+		out.Routes = append(out.Routes, models.StatusRoute{
+			Name:        name,
+			Route:       route.Route,
+			LastSuccess: route.LastSuccess,
+			LastFailure: route.LastFailure,
+			LastError:   route.LastError,
+		})
+	}
+
+	metrics := h.ratesCache.Metrics()
+	out.Cache = models.StatusCache{
+		Items:      h.ratesCache.Len(),
+		Hits:       metrics.Hits,
+		Misses:     metrics.Misses,
+		Insertions: metrics.Insertions,
+		Evictions:  metrics.Evictions,
+	}
+
+	out.Queues = []models.StatusQueue{
+		{
+			Name:     loc.T("status.queue.renders"),
+			Depth:    len(h.renders),
+			Capacity: cap(h.renders),
+		},
+		{
+			Name:  loc.T("status.queue.access"),
+			Depth: len(h.access.PendingRequests()),
+		},
+		{
+			Name:  loc.T("status.queue.jobs"),
+			Depth: h.digester.scheduler.Len(),
+		},
+		{
+			Name:  loc.T("status.queue.outbox"),
+			Depth: h.outbox.Len(),
+		},
+	}
+
+	return out, nil
+}
@@ -1,20 +1,590 @@
 package service
 
 import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode/utf8"
+
 	"github.com/SakoDroid/telego"
+	tgerrors "github.com/SakoDroid/telego/errors"
+	"github.com/SakoDroid/telego/objects"
 
 	"github.com/buglloc/sowettybot/internal/renderer"
 )
 
 const (
 	tgMdMode = "MarkdownV2"
+	// tgMaxMessageLen is the message size limit, checked against the escaped text to stay on the safe side
+	tgMaxMessageLen = 4096
+	// tgMaxMessageParts is the most a message is split into, longer ones are sent as a document
+	tgMaxMessageParts = 4
+	codeFence         = "```"
 )
 
 type BotWrapper struct {
 	*telego.Bot
+	// apiURL is the bot API endpoint with the key, e.g. "https://api.telegram.org/bot<key>"
+	apiURL string
+}
+
+// Messenger is the part of the bot API the handlers use, BotWrapper implements it on top of telego.
+type Messenger interface {
+	SendMdMessage(chatID int, text string, replyTo int) error
+	PostMdMessage(chatID int, text string, replyTo int) (int, error)
+	PostMdMessageWithButtons(chatID int, text string, replyTo int, buttons [][]InlineButton) (int, error)
+	EditMdMessage(chatID int, messageID int, text string) error
+	EditMdMessageWithButtons(chatID int, messageID int, text string, buttons [][]InlineButton) error
+	PinMessage(chatID int, messageID int) error
+	UnpinMessage(chatID int, messageID int) error
+	SendMdPhoto(chatID int, caption string, photo Photo, replyTo int) (string, error)
+	SendMdDocument(chatID int, doc Document, caption string, replyTo int) error
+	SendMdPhotoWithButtons(chatID int, caption string, photo Photo, replyTo int, buttons [][]InlineButton) (string, error)
+	EditMdPhoto(chatID int, messageID int, caption string, photo Photo, buttons [][]InlineButton) (string, error)
+	AnswerCallback(queryID string, text string) error
+	UploadPhoto(chatID int, photoPath string) (string, error)
+	AnswerInline(queryID string, cacheTime int, personal bool, results []InlineResult) error
+	ChatMemberStatus(chatID int, userID int) (string, error)
+	ChatAction(chatID int, action string) error
+	Username() (string, error)
+	SetCommands(scope string, lang string, commands []BotCommand) error
 }
 
+var _ Messenger = (*BotWrapper)(nil)
+
+type BotCommand struct {
+	Command     string `json:"command"`
+	Description string `json:"description"`
+}
+
+// Document is a file sent as an attachment, Name is the file name the chat sees.
+type Document struct {
+	Name string
+	Data []byte
+}
+
+// SendMdMessage sends a single message, the Outbox splits the longer ones.
 func (b *BotWrapper) SendMdMessage(chatID int, text string, replyTo int) error {
-	_, err := b.Bot.SendMessage(chatID, renderer.EscapeTgMd(text), tgMdMode, replyTo, false, false)
+	_, err := b.PostMdMessage(chatID, text, replyTo)
+	return err
+}
+
+// PostMdMessage sends the message and returns its id.
+func (b *BotWrapper) PostMdMessage(chatID int, text string, replyTo int) (int, error) {
+	rsp, err := b.Bot.SendMessage(chatID, renderer.EscapeTgMd(text), tgMdMode, replyTo, false, false)
+	if err != nil {
+		return 0, err
+	}
+
+	return rsp.Result.MessageId, nil
+}
+
+// PostMdMessageWithButtons sends the message with an inline keyboard and returns its id.
+func (b *BotWrapper) PostMdMessageWithButtons(chatID int, text string, replyTo int, buttons [][]InlineButton) (int, error) {
+	kb := b.Bot.CreateInlineKeyboard()
+	fillKeyboard(kb, buttons)
+	rsp, err := b.Bot.AdvancedMode().ASendMessage(chatID, renderer.EscapeTgMd(text), tgMdMode, replyTo, false, false, nil, false, true, kb)
+	if err != nil {
+		return 0, err
+	}
+
+	return rsp.Result.MessageId, nil
+}
+
+func (b *BotWrapper) EditMdMessage(chatID int, messageID int, text string) error {
+	_, err := b.Bot.GetMsgEditor(chatID).EditText(messageID, renderer.EscapeTgMd(text), "", tgMdMode, nil, false, nil)
+	if isNotModified(err) {
+		return nil
+	}
+
+	return err
+}
+
+// EditMdMessageWithButtons replaces the text of an already sent message and its inline keyboard.
+func (b *BotWrapper) EditMdMessageWithButtons(chatID int, messageID int, text string, buttons [][]InlineButton) error {
+	kb := b.Bot.CreateInlineKeyboard()
+	fillKeyboard(kb, buttons)
+	_, err := b.Bot.GetMsgEditor(chatID).EditText(messageID, renderer.EscapeTgMd(text), "", tgMdMode, nil, false, kb)
+	if isNotModified(err) {
+		return nil
+	}
+
 	return err
 }
+
+func (b *BotWrapper) PinMessage(chatID int, messageID int) error {
+	_, err := b.Bot.GetChatManagerById(chatID).PinMessage(messageID, true)
+	return err
+}
+
+func (b *BotWrapper) UnpinMessage(chatID int, messageID int) error {
+	_, err := b.Bot.GetChatManagerById(chatID).UnpinMessage(messageID)
+	return err
+}
+
+func (b *BotWrapper) SendMdPhoto(chatID int, caption string, photo Photo, replyTo int) (string, error) {
+	return b.SendMdPhotoWithButtons(chatID, caption, photo, replyTo, nil)
+}
+
+// SendMdPhotoWithButtons sends the photo with an inline keyboard attached, one slice per row, and returns its file_id.
+func (b *BotWrapper) SendMdPhotoWithButtons(chatID int, caption string, photo Photo, replyTo int, buttons [][]InlineButton) (string, error) {
+	var sender *telego.MediaSender
+	if len(buttons) == 0 {
+		sender = b.Bot.SendPhoto(chatID, replyTo, caption, tgMdMode)
+	} else {
+		kb := b.Bot.CreateInlineKeyboard()
+		fillKeyboard(kb, buttons)
+		sender = b.Bot.AdvancedMode().ASendPhoto(chatID, replyTo, caption, tgMdMode, nil, true, kb)
+	}
+
+	if photo.FileID != "" {
+		rsp, err := sender.SendByFileIdOrUrl(photo.FileID, false, false)
+		if err != nil {
+			return "", err
+		}
+
+		return largestPhoto(rsp.Result.Photo, photo.FileID), nil
+	}
+
+	f, err := os.Open(photo.Path)
+	if err != nil {
+		return "", fmt.Errorf("open photo: %w", err)
+	}
+	defer func() { _ = f.Close() }()
+
+	rsp, err := sender.SendByFile(f, false, false)
+	if err != nil {
+		return "", err
+	}
+
+	return largestPhoto(rsp.Result.Photo, ""), nil
+}
+
+// SendMdDocument sends the data as a file attachment.
+func (b *BotWrapper) SendMdDocument(chatID int, doc Document, caption string, replyTo int) error {
+	// telego names the attachment after the file
+	dir, err := os.MkdirTemp("", "sowettybot-doc-*")
+	if err != nil {
+		return fmt.Errorf("create document dir: %w", err)
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+
+	docPath := filepath.Join(dir, filepath.Base(doc.Name))
+	if err := os.WriteFile(docPath, doc.Data, 0o600); err != nil {
+		return fmt.Errorf("write document: %w", err)
+	}
+
+	f, err := os.Open(docPath)
+	if err != nil {
+		return fmt.Errorf("open document: %w", err)
+	}
+	defer func() { _ = f.Close() }()
+
+	_, err = b.Bot.SendDocument(chatID, replyTo, renderer.EscapeTgMd(caption), tgMdMode).SendByFile(f, false, false)
+	return err
+}
+
+// EditMdPhoto replaces the photo of an already sent message and its inline keyboard, returns the new file_id.
+func (b *BotWrapper) EditMdPhoto(chatID int, messageID int, caption string, photo Photo, buttons [][]InlineButton) (string, error) {
+	kb := b.Bot.CreateInlineKeyboard()
+	fillKeyboard(kb, buttons)
+	editor := b.Bot.GetMsgEditor(chatID).EditMediaPhoto(messageID, caption, tgMdMode, nil, kb)
+
+	var rsp *objects.DefaultResult
+	var err error
+	if photo.FileID != "" {
+		rsp, err = editor.EditByFileIdOrURL(photo.FileID)
+	} else {
+		var f *os.File
+		f, err = os.Open(photo.Path)
+		if err != nil {
+			return "", fmt.Errorf("open photo: %w", err)
+		}
+		defer func() { _ = f.Close() }()
+
+		rsp, err = editor.EditByFile(f)
+	}
+
+	if isNotModified(err) {
+		return photo.FileID, nil
+	}
+
+	if err != nil {
+		return "", err
+	}
+
+	var msg objects.Message
+	if err := json.Unmarshal(rsp.Result, &msg); err != nil {
+		// inline messages are edited with "true" as the result
+		return photo.FileID, nil
+	}
+
+	return largestPhoto(msg.Photo, photo.FileID), nil
+}
+
+func (b *BotWrapper) AnswerCallback(queryID string, text string) error {
+	_, err := b.Bot.AnswerCallbackQuery(queryID, text, false)
+	return err
+}
+
+type InlineButton struct {
+	Text string
+	Data string
+}
+
+type callbackKeyboard interface {
+	AddCallbackButton(text, data string, row int)
+}
+
+func fillKeyboard(kb callbackKeyboard, buttons [][]InlineButton) {
+	for i, row := range buttons {
+		for _, btn := range row {
+			// telego rows are 1-based
+			kb.AddCallbackButton(btn.Text, btn.Data, i+1)
+		}
+	}
+}
+
+// UploadPhoto sends the photo silently and returns its Telegram file_id.
+func (b *BotWrapper) UploadPhoto(chatID int, photoPath string) (string, error) {
+	f, err := os.Open(photoPath)
+	if err != nil {
+		return "", fmt.Errorf("open photo: %w", err)
+	}
+	defer func() { _ = f.Close() }()
+
+	rsp, err := b.Bot.SendPhoto(chatID, 0, "", "").SendByFile(f, true, false)
+	if err != nil {
+		return "", err
+	}
+
+	if rsp.Result == nil || len(rsp.Result.Photo) == 0 {
+		return "", errors.New("no photo in response")
+	}
+
+	return rsp.Result.Photo[len(rsp.Result.Photo)-1].FileId, nil
+}
+
+// Photo is a file to upload or a file_id of an already uploaded one, the file_id wins.
+type Photo struct {
+	Path   string
+	FileID string
+}
+
+// largestPhoto returns the file_id of the biggest size, Telegram sends several.
+func largestPhoto(sizes []objects.PhotoSize, fallback string) string {
+	if len(sizes) == 0 {
+		return fallback
+	}
+
+	return sizes[len(sizes)-1].FileId
+}
+
+// InlineResult is either a MarkdownV2 article or a cached photo, depending on PhotoFileID.
+type InlineResult struct {
+	ID          string
+	Title       string
+	Description string
+	Text        string
+	PhotoFileID string
+}
+
+// AnswerInline answers the inline query, personal results are cached for the sender only.
+func (b *BotWrapper) AnswerInline(queryID string, cacheTime int, personal bool, results []InlineResult) error {
+	rsp := b.Bot.AdvancedMode().AAnswerInlineQuery(queryID, cacheTime, personal, "", "", "")
+	for _, r := range results {
+		var err error
+		if r.PhotoFileID != "" {
+			err = rsp.AddCachedPhoto(r.ID, r.Title, r.PhotoFileID, r.Description, renderer.EscapeTgMd(r.Text), tgMdMode, nil, nil, nil)
+		} else {
+			msg := rsp.CreateTextMessage(renderer.EscapeTgMd(r.Text), tgMdMode, nil, true)
+			err = rsp.AddArticle(r.ID, r.Title, "", r.Description, "", 0, 0, true, msg, nil)
+		}
+
+		if err != nil {
+			return fmt.Errorf("add inline result %q: %w", r.ID, err)
+		}
+	}
+
+	_, err := rsp.Send()
+	return err
+}
+
+// ChatAction shows the bot status, e.g. "typing", for 5 seconds or until the next message.
+func (b *BotWrapper) ChatAction(chatID int, action string) error {
+	_, err := b.Bot.SendChatAction(chatID, action)
+	return err
+}
+
+// ChatMemberStatus returns the member status: creator, administrator, member, restricted, left or kicked.
+func (b *BotWrapper) ChatMemberStatus(chatID int, userID int) (string, error) {
+	rsp, err := b.Bot.GetChatManagerById(chatID).GetMember(userID)
+	if err != nil {
+		return "", err
+	}
+
+	var member struct {
+		Status string `json:"status"`
+	}
+	if err := json.Unmarshal([]byte(rsp), &member); err != nil {
+		return "", fmt.Errorf("parse chat member: %w", err)
+	}
+
+	return member.Status, nil
+}
+
+func (b *BotWrapper) Username() (string, error) {
+	rsp, err := b.Bot.GetMe()
+	if err != nil {
+		return "", err
+	}
+
+	if rsp.Result == nil {
+		return "", errors.New("no user in response")
+	}
+
+	return rsp.Result.Username, nil
+}
+
+// SetCommands replaces the command menu for the scope ("default", "all_private_chats", etc.) and language,
+// an empty language is the fallback one. telego never fills the scope type, so the method is called directly.
+func (b *BotWrapper) SetCommands(scope string, lang string, commands []BotCommand) error {
+	var req struct {
+		Commands []BotCommand `json:"commands"`
+		Scope    struct {
+			Type string `json:"type"`
+		} `json:"scope"`
+		LanguageCode string `json:"language_code,omitempty"`
+	}
+	req.Commands = commands
+	req.Scope.Type = scope
+	req.LanguageCode = lang
+	return b.call("setMyCommands", req)
+}
+
+// SetWebhook asks Telegram to post updates to the url, with the secret token in the X-Telegram-Bot-Api-Secret-Token header.
+// telego can't pass the secret token, so the method is called directly.
+func (b *BotWrapper) SetWebhook(url string, secretToken string) error {
+	return b.call("setWebhook", struct {
+		URL         string `json:"url"`
+		SecretToken string `json:"secret_token,omitempty"`
+	}{
+		URL:         url,
+		SecretToken: secretToken,
+	})
+}
+
+// call sends the bot API request bypassing telego.
+func (b *BotWrapper) call(method string, req interface{}) error {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return fmt.Errorf("marshal request: %w", err)
+	}
+
+	httpClient := &http.Client{Timeout: 30 * time.Second}
+	rsp, err := httpClient.Post(b.apiURL+"/"+method, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("send request: %w", err)
+	}
+	defer func() { _ = rsp.Body.Close() }()
+
+	var result struct {
+		Ok          bool   `json:"ok"`
+		Description string `json:"description"`
+	}
+	if err := json.NewDecoder(rsp.Body).Decode(&result); err != nil {
+		return fmt.Errorf("parse response: %w", err)
+	}
+
+	if !result.Ok {
+		return fmt.Errorf("%s failed: %s", method, result.Description)
+	}
+
+	return nil
+}
+
+func tgErrorDescription(err error) string {
+	var tgErr *tgerrors.MethodNotSentError
+	if !errors.As(err, &tgErr) || tgErr.FailureResult == nil {
+		return ""
+	}
+
+	return strings.ToLower(tgErr.FailureResult.Description)
+}
+
+func isNotModified(err error) bool {
+	return strings.Contains(tgErrorDescription(err), "message is not modified")
+}
+
+// isMessageGone reports whether the message was deleted or can't be reached anymore.
+func isMessageGone(err error) bool {
+	desc := tgErrorDescription(err)
+	for _, reason := range []string{
+		"message to edit not found",
+		"message can't be edited",
+		"message_id_invalid",
+		"chat not found",
+		"bot was blocked by the user",
+		"bot was kicked",
+		"not enough rights",
+	} {
+		if strings.Contains(desc, reason) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// floodWait returns how long Telegram asks to wait after a 429, telego drops the response
+// parameters so it's parsed from the description, e.g. "Too Many Requests: retry after 5".
+func floodWait(err error) (time.Duration, bool) {
+	var tgErr *tgerrors.MethodNotSentError
+	if !errors.As(err, &tgErr) || tgErr.FailureResult == nil || tgErr.FailureResult.ErrorCode != http.StatusTooManyRequests {
+		return 0, false
+	}
+
+	wait := time.Second
+	desc := strings.ToLower(tgErr.FailureResult.Description)
+	if _, after, ok := strings.Cut(desc, "retry after "); ok {
+		var seconds int
+		if _, err := fmt.Sscanf(after, "%d", &seconds); err == nil && seconds > 0 {
+			wait = time.Duration(seconds) * time.Second
+		}
+	}
+
+	return wait, true
+}
+
+// notSent reports whether the request surely never reached Telegram, so repeating it can't deliver
+// a message twice. telego keeps only the text of transport errors: a failed dial or TLS handshake
+// happens before anything is written, a reset or a 5xx may come after the message was applied.
+func notSent(err error) bool {
+	var tgErr *tgerrors.MethodNotSentError
+	if !errors.As(err, &tgErr) || tgErr.FailureResult != nil {
+		return false
+	}
+
+	reason := strings.ToLower(tgErr.Reason)
+	for _, marker := range []string{
+		"dial tcp",
+		"connection refused",
+		"tls handshake",
+	} {
+		if strings.Contains(reason, marker) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// splitMdMessage splits the text on line boundaries into parts of at most limit escaped characters.
+// A code block cut in two is closed at the end of the part and reopened in the next one.
+func splitMdMessage(text string, limit int) []string {
+	mdLen := func(s string) int {
+		return utf8.RuneCountInString(renderer.EscapeTgMd(s))
+	}
+
+	if mdLen(text) <= limit {
+		return []string{text}
+	}
+
+	closing := "\n" + codeFence
+	var parts []string
+	var part strings.Builder
+	partLen, hasLines := 0, false
+	openFence := ""
+	flush := func() {
+		text := strings.TrimRight(part.String(), "\n")
+		if openFence != "" {
+			text += closing
+		}
+		parts = append(parts, text)
+
+		part.Reset()
+		partLen, hasLines = 0, false
+		if openFence != "" {
+			part.WriteString(openFence + "\n")
+			partLen = mdLen(openFence) + 1
+		}
+	}
+
+	for _, line := range strings.Split(text, "\n") {
+		isFence := strings.HasPrefix(strings.TrimSpace(line), codeFence)
+		reserve := 0
+		if openFence != "" && !isFence {
+			reserve = len(closing)
+		}
+
+		if hasLines && partLen+mdLen(line)+1+reserve > limit {
+			flush()
+		}
+
+		// a single line longer than the limit is cut as is
+		for rest := limit - partLen - reserve - 1; mdLen(line) > rest && rest > 0; rest = limit - partLen - reserve - 1 {
+			cut := cutMdLine(line, rest)
+			part.WriteString(line[:cut] + "\n")
+			partLen += mdLen(line[:cut]) + 1
+			hasLines = true
+			line = line[cut:]
+			flush()
+		}
+
+		part.WriteString(line + "\n")
+		partLen += mdLen(line) + 1
+		hasLines = true
+
+		if isFence {
+			if openFence == "" {
+				openFence = strings.TrimSpace(line)
+			} else {
+				openFence = ""
+			}
+		}
+	}
+
+	if hasLines {
+		openFence = ""
+		flush()
+	}
+
+	return parts
+}
+
+// cutMdLine returns the byte offset of the longest line prefix within limit escaped characters.
+func cutMdLine(line string, limit int) int {
+	size := 0
+	for i, c := range line {
+		n := utf8.RuneCountInString(renderer.EscapeTgMd(string(c)))
+		if size+n > limit {
+			return i
+		}
+		size += n
+	}
+
+	return len(line)
+}
+
+// textDocument turns the message into a plain text file, code fences are only markup.
+func textDocument(text string) Document {
+	var out strings.Builder
+	for _, line := range strings.Split(text, "\n") {
+		if strings.HasPrefix(strings.TrimSpace(line), codeFence) {
+			continue
+		}
+
+		out.WriteString(line + "\n")
+	}
+
+	return Document{
+		Name: "message.txt",
+		Data: []byte(out.String()),
+	}
+}
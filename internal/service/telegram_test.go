@@ -0,0 +1,62 @@
+package service
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/buglloc/sowettybot/internal/renderer"
+)
+
+func TestSplitMdMessage(t *testing.T) {
+	cases := []struct {
+		name  string
+		in    string
+		limit int
+		parts []string
+	}{
+		{
+			name:  "fits",
+			in:    "```\n10:00\t1.0\n```",
+			limit: 100,
+			parts: []string{"```\n10:00\t1.0\n```"},
+		},
+		{
+			name:  "plain",
+			in:    "first line\nsecond line\nthird line",
+			limit: 24,
+			parts: []string{"first line\nsecond line", "third line"},
+		},
+		{
+			name:  "code_block",
+			in:    "rates\n```\n10:00 1\n11:00 2\n12:00 3\n```\ndone",
+			limit: 24,
+			parts: []string{"rates\n```\n10:00 1\n```", "```\n11:00 2\n12:00 3\n```", "done"},
+		},
+		{
+			name:  "long_line",
+			in:    strings.Repeat("a", 25),
+			limit: 10,
+			parts: []string{"aaaaaaaaa", "aaaaaaaaa", "aaaaaaa"},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			parts := splitMdMessage(tc.in, tc.limit)
+			require.Equal(t, tc.parts, parts)
+			for _, part := range parts {
+				require.LessOrEqual(t, utf8.RuneCountInString(renderer.EscapeTgMd(part)), tc.limit)
+				require.Zero(t, strings.Count(part, codeFence)%2, part)
+			}
+		})
+	}
+}
+
+func TestTextDocument(t *testing.T) {
+	doc := textDocument("```\n10:00\t1.0\n11:00\t2.0\n```")
+	require.Equal(t, "message.txt", doc.Name)
+	require.Equal(t, "10:00\t1.0\n11:00\t2.0\n", string(doc.Data))
+}
@@ -0,0 +1,167 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/SakoDroid/telego/objects"
+	"github.com/rs/zerolog/log"
+
+	"github.com/buglloc/sowettybot/internal/config"
+)
+
+const (
+	webhookSecretHeader = "X-Telegram-Bot-Api-Secret-Token"
+	// webhookMaxBody is way above any update we handle
+	webhookMaxBody = 1 << 20
+)
+
+// Webhook is an HTTP endpoint for Telegram updates, it replaces long polling.
+type Webhook struct {
+	cfg      config.Webhook
+	path     string
+	updates  chan *objects.Update
+	server   *http.Server
+	listener net.Listener
+	closing  chan struct{}
+	once     sync.Once
+}
+
+func NewWebhook(cfg config.Webhook) (*Webhook, error) {
+	u, err := url.Parse(cfg.URL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid webhook url: %w", err)
+	}
+
+	if u.Scheme != "https" {
+		return nil, fmt.Errorf("webhook url must be https: %s", cfg.URL)
+	}
+
+	if (cfg.CertFile == "") != (cfg.KeyFile == "") {
+		return nil, errors.New("both webhook cert_file and key_file are required for HTTPS")
+	}
+
+	path := u.Path
+	if path == "" {
+		path = "/"
+	}
+
+	if cfg.SecretToken == "" {
+		// anyone knowing the url could post updates otherwise, SetWebhook passes the token to Telegram
+		cfg.SecretToken, err = newWebhookSecret()
+		if err != nil {
+			return nil, fmt.Errorf("generate webhook secret token: %w", err)
+		}
+
+		log.Info().Msg("webhook secret token is not set, using a random one")
+	}
+
+	w := &Webhook{
+		cfg:     cfg,
+		path:    path,
+		updates: make(chan *objects.Update),
+		closing: make(chan struct{}),
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle(path, w)
+	w.server = &http.Server{
+		Addr:              cfg.Listen,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	return w, nil
+}
+
+func (w *Webhook) Updates() <-chan *objects.Update {
+	return w.updates
+}
+
+// Listen binds the address and serves in background, TLS is used if the cert is configured.
+func (w *Webhook) Listen() error {
+	listener, err := net.Listen("tcp", w.cfg.Listen)
+	if err != nil {
+		return fmt.Errorf("listen %s: %w", w.cfg.Listen, err)
+	}
+	w.listener = listener
+
+	go func() {
+		var err error
+		if w.cfg.CertFile != "" {
+			err = w.server.ServeTLS(listener, w.cfg.CertFile, w.cfg.KeyFile)
+		} else {
+			err = w.server.Serve(listener)
+		}
+
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Error().Err(err).Str("addr", w.cfg.Listen).Msg("webhook server failed")
+		}
+	}()
+
+	return nil
+}
+
+// Addr is the bound address, useful with ":0".
+func (w *Webhook) Addr() string {
+	if w.listener == nil {
+		return w.cfg.Listen
+	}
+
+	return w.listener.Addr().String()
+}
+
+func (w *Webhook) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(rw, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	token := r.Header.Get(webhookSecretHeader)
+	if subtle.ConstantTimeCompare([]byte(token), []byte(w.cfg.SecretToken)) != 1 {
+		log.Warn().Str("remote_addr", r.RemoteAddr).Msg("webhook request with invalid secret token")
+		http.Error(rw, "forbidden", http.StatusForbidden)
+		return
+	}
+
+	var u objects.Update
+	if err := json.NewDecoder(http.MaxBytesReader(rw, r.Body, webhookMaxBody)).Decode(&u); err != nil {
+		log.Warn().Err(err).Str("remote_addr", r.RemoteAddr).Msg("invalid webhook update")
+		http.Error(rw, "invalid update", http.StatusBadRequest)
+		return
+	}
+
+	// Telegram waits for the reply and retries on errors, so back pressure is fine
+	select {
+	case w.updates <- &u:
+		rw.WriteHeader(http.StatusOK)
+	case <-w.closing:
+		http.Error(rw, "shutting down", http.StatusServiceUnavailable)
+	case <-r.Context().Done():
+	}
+}
+
+// Shutdown stops accepting updates and waits for the in-flight requests.
+func (w *Webhook) Shutdown(ctx context.Context) error {
+	w.once.Do(func() { close(w.closing) })
+	return w.server.Shutdown(ctx)
+}
+
+// newWebhookSecret makes a token of the characters Telegram allows: A-Z, a-z, 0-9, _ and -.
+func newWebhookSecret() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(buf), nil
+}
@@ -0,0 +1,89 @@
+package service
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/buglloc/sowettybot/internal/config"
+)
+
+func TestWebhook(t *testing.T) {
+	w, err := NewWebhook(config.Webhook{
+		URL:         "https://bot.example.com/telegram",
+		Listen:      "127.0.0.1:0",
+		SecretToken: "s3cret",
+	})
+	require.NoError(t, err)
+	require.NoError(t, w.Listen())
+	defer func() { _ = w.Shutdown(context.Background()) }()
+
+	endpoint := "http://" + w.Addr() + "/telegram"
+	post := func(secret string, body string) int {
+		req, err := http.NewRequest(http.MethodPost, endpoint, strings.NewReader(body))
+		require.NoError(t, err)
+		req.Header.Set("Content-Type", "application/json")
+		if secret != "" {
+			req.Header.Set(webhookSecretHeader, secret)
+		}
+
+		rsp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		_ = rsp.Body.Close()
+		return rsp.StatusCode
+	}
+
+	received := make(chan int, 1)
+	go func() {
+		u := <-w.Updates()
+		received <- u.Message.Chat.Id
+	}()
+
+	update := `{"update_id": 1, "message": {"message_id": 2, "chat": {"id": 42, "type": "private"}, "text": "/rates"}}`
+	require.Equal(t, http.StatusForbidden, post("", update))
+	require.Equal(t, http.StatusForbidden, post("wrong", update))
+	require.Equal(t, http.StatusBadRequest, post("s3cret", "{"))
+	require.Equal(t, http.StatusOK, post("s3cret", update))
+
+	select {
+	case chatID := <-received:
+		require.Equal(t, 42, chatID)
+	case <-time.After(5 * time.Second):
+		t.Fatal("no update received")
+	}
+
+	rsp, err := http.Get(endpoint)
+	require.NoError(t, err)
+	_ = rsp.Body.Close()
+	require.Equal(t, http.StatusMethodNotAllowed, rsp.StatusCode)
+
+	require.NoError(t, w.Shutdown(context.Background()))
+	_, err = http.Post(endpoint, "application/json", strings.NewReader(update))
+	require.Error(t, err)
+}
+
+func TestNewWebhook(t *testing.T) {
+	_, err := NewWebhook(config.Webhook{URL: "http://bot.example.com/telegram"})
+	require.Error(t, err)
+
+	_, err = NewWebhook(config.Webhook{URL: "https://bot.example.com/telegram", CertFile: "cert.pem"})
+	require.Error(t, err)
+
+	// updates are never accepted without a secret token
+	w, err := NewWebhook(config.Webhook{URL: "https://bot.example.com/telegram"})
+	require.NoError(t, err)
+	require.Regexp(t, `^[A-Za-z0-9_-]{64}$`, w.cfg.SecretToken)
+
+	other, err := NewWebhook(config.Webhook{URL: "https://bot.example.com/telegram"})
+	require.NoError(t, err)
+	require.NotEqual(t, w.cfg.SecretToken, other.cfg.SecretToken)
+
+	rsp := httptest.NewRecorder()
+	w.ServeHTTP(rsp, httptest.NewRequest(http.MethodPost, "/telegram", strings.NewReader(`{"update_id": 1}`)))
+	require.Equal(t, http.StatusForbidden, rsp.Code)
+}
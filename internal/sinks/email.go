@@ -0,0 +1,184 @@
+package sinks
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/smtp"
+	"strings"
+	"time"
+)
+
+const (
+	DefaultEmailSubject = "sowettybot: nice exchange rate"
+)
+
+var _ Sink = (*EmailSink)(nil)
+
+type EmailSink struct {
+	name    string
+	addr    string
+	from    string
+	to      []string
+	subject string
+	auth    smtp.Auth
+}
+
+func NewEmailSink(name, addr, from string, to []string, opts ...EmailOption) *EmailSink {
+	s := &EmailSink{
+		name:    name,
+		addr:    addr,
+		from:    from,
+		to:      to,
+		subject: DefaultEmailSubject,
+	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	return s
+}
+
+func (s *EmailSink) Name() string {
+	return s.name
+}
+
+func (s *EmailSink) Send(ctx context.Context, alert Alert) error {
+	if len(s.to) == 0 {
+		return fmt.Errorf("no recipients")
+	}
+
+	var msg strings.Builder
+	_, _ = fmt.Fprintf(&msg, "From: %s\r\n", s.from)
+	_, _ = fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(s.to, ", "))
+	_, _ = fmt.Fprintf(&msg, "Subject: %s\r\n", s.subject)
+	_, _ = fmt.Fprintf(&msg, "Date: %s\r\n", alert.When.Format(time.RFC1123Z))
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	msg.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	msg.WriteString("\r\n")
+	msg.WriteString(strings.ReplaceAll(alert.Text, "\n", "\r\n"))
+
+	if err := s.sendMail(ctx, []byte(msg.String())); err != nil {
+		return fmt.Errorf("send mail: %w", err)
+	}
+
+	return nil
+}
+
+// sendMail does what smtp.SendMail does, but the whole conversation is bound to the context.
+func (s *EmailSink) sendMail(ctx context.Context, msg []byte) error {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", s.addr)
+	if err != nil {
+		return fmt.Errorf("dial: %w", err)
+	}
+	defer func() { _ = conn.Close() }()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		if err := conn.SetDeadline(deadline); err != nil {
+			return fmt.Errorf("set deadline: %w", err)
+		}
+	}
+
+	// the deadline doesn't cover cancellation, so expire the connection once the context is done
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			_ = conn.SetDeadline(time.Unix(1, 0))
+		case <-done:
+		}
+	}()
+
+	err = s.converse(conn, msg)
+	if err == nil {
+		return nil
+	}
+
+	// the connection may time out a bit earlier than the context does
+	if deadline, ok := ctx.Deadline(); ok && !time.Now().Before(deadline) {
+		<-ctx.Done()
+	}
+
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return ctxErr
+	}
+
+	return err
+}
+
+func (s *EmailSink) converse(conn net.Conn, msg []byte) error {
+	host, _, _ := net.SplitHostPort(s.addr)
+	c, err := smtp.NewClient(conn, host)
+	if err != nil {
+		return fmt.Errorf("start session: %w", err)
+	}
+	defer func() { _ = c.Close() }()
+
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err := c.StartTLS(&tls.Config{ServerName: host}); err != nil {
+			return fmt.Errorf("starttls: %w", err)
+		}
+	}
+
+	if s.auth != nil {
+		if ok, _ := c.Extension("AUTH"); !ok {
+			return fmt.Errorf("server doesn't support AUTH")
+		}
+
+		if err := c.Auth(s.auth); err != nil {
+			return fmt.Errorf("auth: %w", err)
+		}
+	}
+
+	if err := c.Mail(s.from); err != nil {
+		return fmt.Errorf("mail from: %w", err)
+	}
+
+	for _, to := range s.to {
+		if err := c.Rcpt(to); err != nil {
+			return fmt.Errorf("rcpt to %s: %w", to, err)
+		}
+	}
+
+	w, err := c.Data()
+	if err != nil {
+		return fmt.Errorf("data: %w", err)
+	}
+
+	if _, err := w.Write(msg); err != nil {
+		return fmt.Errorf("write message: %w", err)
+	}
+
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("finish message: %w", err)
+	}
+
+	return c.Quit()
+}
+
+type EmailOption func(*EmailSink)
+
+func WithEmailSubject(subject string) EmailOption {
+	return func(s *EmailSink) {
+		if subject == "" {
+			return
+		}
+
+		s.subject = subject
+	}
+}
+
+func WithEmailAuth(username, password string) EmailOption {
+	return func(s *EmailSink) {
+		if username == "" {
+			return
+		}
+
+		host, _, _ := net.SplitHostPort(s.addr)
+		s.auth = smtp.PlainAuth("", username, password, host)
+	}
+}
@@ -0,0 +1,45 @@
+package sinks
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/buglloc/sowettybot/internal/config"
+)
+
+func NewSink(cfg config.Sink, bot MdSender) (Sink, error) {
+	if cfg.Name == "" {
+		return nil, fmt.Errorf("sink name is required")
+	}
+
+	switch cfg.Kind {
+	case config.SinkKindTelegram:
+		if cfg.Telegram.ChatID == 0 {
+			return nil, fmt.Errorf("sink %q: chat_id is required", cfg.Name)
+		}
+
+		return NewTelegramSink(cfg.Name, bot, cfg.Telegram.ChatID), nil
+	case config.SinkKindWebhook:
+		if cfg.Webhook.URL == "" {
+			return nil, fmt.Errorf("sink %q: url is required", cfg.Name)
+		}
+
+		return NewWebhookSink(cfg.Name, cfg.Webhook.URL, cfg.Webhook.Headers), nil
+	case config.SinkKindEmail:
+		if cfg.Email.Addr == "" || cfg.Email.From == "" || len(cfg.Email.To) == 0 {
+			return nil, fmt.Errorf("sink %q: addr, from and to are required", cfg.Name)
+		}
+
+		return NewEmailSink(
+			cfg.Name, cfg.Email.Addr, cfg.Email.From, cfg.Email.To,
+			WithEmailSubject(cfg.Email.Subject),
+			WithEmailAuth(cfg.Email.Username, cfg.Email.Password),
+		), nil
+	case config.SinkKindLog:
+		return NewLogSink(cfg.Name), nil
+	case config.SinkKindStdout:
+		return NewWriterSink(cfg.Name, os.Stdout), nil
+	default:
+		return nil, fmt.Errorf("sink %q: unsupported kind %q", cfg.Name, cfg.Kind)
+	}
+}
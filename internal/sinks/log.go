@@ -0,0 +1,56 @@
+package sinks
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/rs/zerolog/log"
+)
+
+var _ Sink = (*LogSink)(nil)
+var _ Sink = (*WriterSink)(nil)
+
+type LogSink struct {
+	name string
+}
+
+func NewLogSink(name string) *LogSink {
+	return &LogSink{
+		name: name,
+	}
+}
+
+func (s *LogSink) Name() string {
+	return s.name
+}
+
+func (s *LogSink) Send(_ context.Context, alert Alert) error {
+	log.Info().
+		Str("sink", s.name).
+		Float64("threshold", alert.Threshold).
+		Any("rates", alert.Rates).
+		Msg("rate alert")
+	return nil
+}
+
+type WriterSink struct {
+	name string
+	w    io.Writer
+}
+
+func NewWriterSink(name string, w io.Writer) *WriterSink {
+	return &WriterSink{
+		name: name,
+		w:    w,
+	}
+}
+
+func (s *WriterSink) Name() string {
+	return s.name
+}
+
+func (s *WriterSink) Send(_ context.Context, alert Alert) error {
+	_, err := fmt.Fprintf(s.w, "[%s] %s\n", alert.When.Format("02 Jan 15:04 MST"), alert.Text)
+	return err
+}
@@ -0,0 +1,23 @@
+package sinks
+
+import (
+	"context"
+	"time"
+)
+
+type AlertRate struct {
+	Name string  `json:"name"`
+	Rate float64 `json:"rate"`
+}
+
+type Alert struct {
+	Text      string      `json:"text"`
+	Threshold float64     `json:"threshold"`
+	When      time.Time   `json:"when"`
+	Rates     []AlertRate `json:"rates"`
+}
+
+type Sink interface {
+	Name() string
+	Send(ctx context.Context, alert Alert) error
+}
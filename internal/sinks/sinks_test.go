@@ -0,0 +1,202 @@
+package sinks
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/buglloc/sowettybot/internal/config"
+)
+
+func testAlert() Alert {
+	return Alert{
+		Text:      "YAY! Nice exchange rate (threshold is 3.00)!\nkorona: 2.90\n",
+		Threshold: 3.0,
+		When:      time.Date(2023, 6, 30, 12, 0, 0, 0, time.UTC),
+		Rates: []AlertRate{
+			{
+				Name: "korona",
+				Rate: 2.9,
+			},
+		},
+	}
+}
+
+func TestWebhookSink(t *testing.T) {
+	type request struct {
+		header string
+		body   []byte
+	}
+
+	// the handler runs on the server goroutine, so the request is checked here
+	requests := make(chan request, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		requests <- request{header: r.Header.Get("X-Token"), body: body}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	sink := NewWebhookSink("hook", srv.URL, map[string]string{"X-Token": "secret"})
+	require.NoError(t, sink.Send(context.Background(), testAlert()))
+
+	req := <-requests
+	require.Equal(t, "secret", req.header)
+
+	var got Alert
+	require.NoError(t, json.Unmarshal(req.body, &got))
+	require.Equal(t, testAlert(), got)
+}
+
+func TestWebhookSink_error(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer srv.Close()
+
+	sink := NewWebhookSink("hook", srv.URL, nil)
+	require.Error(t, sink.Send(context.Background(), testAlert()))
+}
+
+func TestEmailSink(t *testing.T) {
+	addr, mails := startSMTPServer(t)
+
+	sink := NewEmailSink("mail", addr, "bot@example.com", []string{"team@example.com"}, WithEmailSubject("rates"))
+	require.NoError(t, sink.Send(context.Background(), testAlert()))
+
+	select {
+	case mail := <-mails:
+		require.Contains(t, mail, "From: bot@example.com\r\n")
+		require.Contains(t, mail, "To: team@example.com\r\n")
+		require.Contains(t, mail, "Subject: rates\r\n")
+		require.Contains(t, mail, "korona: 2.90\r\n")
+	case <-time.After(5 * time.Second):
+		t.Fatal("mail was not delivered")
+	}
+}
+
+func TestEmailSink_timeout(t *testing.T) {
+	// the server accepts connections, but never greets
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+
+			// the client hangs up once it gives up
+			go func() {
+				_, _ = io.Copy(io.Discard, conn)
+				_ = conn.Close()
+			}()
+		}
+	}()
+
+	sink := NewEmailSink("mail", ln.Addr().String(), "bot@example.com", []string{"team@example.com"})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	require.ErrorIs(t, sink.Send(ctx, testAlert()), context.DeadlineExceeded)
+
+	ctx, cancel = context.WithCancel(context.Background())
+	time.AfterFunc(100*time.Millisecond, cancel)
+	require.ErrorIs(t, sink.Send(ctx, testAlert()), context.Canceled)
+}
+
+func TestWriterSink(t *testing.T) {
+	var out bytes.Buffer
+	sink := NewWriterSink("stdout", &out)
+	require.NoError(t, sink.Send(context.Background(), testAlert()))
+	require.True(t, strings.HasPrefix(out.String(), "[30 Jun 12:00 UTC] YAY!"))
+}
+
+func TestNewSink_name(t *testing.T) {
+	cases := []config.Sink{
+		{Name: "team", Kind: config.SinkKindTelegram, Telegram: config.TelegramSink{ChatID: -100}},
+		{Name: "hook", Kind: config.SinkKindWebhook, Webhook: config.WebhookSink{URL: "http://localhost"}},
+		{Name: "mail", Kind: config.SinkKindEmail, Email: config.EmailSink{Addr: "localhost:25", From: "bot@example.com", To: []string{"team@example.com"}}},
+		{Name: "journal", Kind: config.SinkKindLog},
+		{Name: "console", Kind: config.SinkKindStdout},
+	}
+	for _, cfg := range cases {
+		t.Run(string(cfg.Kind), func(t *testing.T) {
+			sink, err := NewSink(cfg, nil)
+			require.NoError(t, err)
+			require.Equal(t, cfg.Name, sink.Name())
+		})
+	}
+}
+
+// startSMTPServer starts a minimal SMTP stand-in that accepts a single mail.
+func startSMTPServer(t *testing.T) (string, <-chan string) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = ln.Close() })
+
+	mails := make(chan string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer func() { _ = conn.Close() }()
+
+		r := bufio.NewReader(conn)
+		reply := func(msg string) {
+			_, _ = fmt.Fprintf(conn, "%s\r\n", msg)
+		}
+
+		reply("220 localhost ESMTP")
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+
+			cmd := strings.ToUpper(strings.TrimSpace(line))
+			switch {
+			case strings.HasPrefix(cmd, "EHLO"), strings.HasPrefix(cmd, "HELO"):
+				reply("250 localhost")
+			case strings.HasPrefix(cmd, "MAIL"), strings.HasPrefix(cmd, "RCPT"):
+				reply("250 OK")
+			case cmd == "DATA":
+				reply("354 go ahead")
+				var data strings.Builder
+				for {
+					line, err := r.ReadString('\n')
+					if err != nil {
+						return
+					}
+
+					if line == ".\r\n" {
+						break
+					}
+					data.WriteString(line)
+				}
+				mails <- data.String()
+				reply("250 OK")
+			case cmd == "QUIT":
+				reply("221 bye")
+				return
+			default:
+				reply("502 unsupported")
+			}
+		}
+	}()
+
+	return ln.Addr().String(), mails
+}
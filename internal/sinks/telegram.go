@@ -0,0 +1,33 @@
+package sinks
+
+import (
+	"context"
+)
+
+var _ Sink = (*TelegramSink)(nil)
+
+type MdSender interface {
+	SendMdMessage(chatID int, text string, replyTo int) error
+}
+
+type TelegramSink struct {
+	name   string
+	bot    MdSender
+	chatID int
+}
+
+func NewTelegramSink(name string, bot MdSender, chatID int) *TelegramSink {
+	return &TelegramSink{
+		name:   name,
+		bot:    bot,
+		chatID: chatID,
+	}
+}
+
+func (s *TelegramSink) Name() string {
+	return s.name
+}
+
+func (s *TelegramSink) Send(_ context.Context, alert Alert) error {
+	return s.bot.SendMdMessage(s.chatID, alert.Text, 0)
+}
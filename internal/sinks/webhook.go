@@ -0,0 +1,53 @@
+package sinks
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+)
+
+const (
+	DefaultWebhookRetries = 3
+	DefaultWebhookTimeout = 30 * time.Second
+)
+
+var _ Sink = (*WebhookSink)(nil)
+
+type WebhookSink struct {
+	name  string
+	url   string
+	httpc *resty.Client
+}
+
+func NewWebhookSink(name, url string, headers map[string]string) *WebhookSink {
+	return &WebhookSink{
+		name: name,
+		url:  url,
+		httpc: resty.New().
+			SetRetryCount(DefaultWebhookRetries).
+			SetTimeout(DefaultWebhookTimeout).
+			SetHeaders(headers),
+	}
+}
+
+func (s *WebhookSink) Name() string {
+	return s.name
+}
+
+func (s *WebhookSink) Send(ctx context.Context, alert Alert) error {
+	rsp, err := s.httpc.R().
+		SetContext(ctx).
+		SetBody(alert).
+		Post(s.url)
+	if err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+
+	if !rsp.IsSuccess() {
+		return fmt.Errorf("non-200 status code: %s", rsp.Status())
+	}
+
+	return nil
+}
@@ -0,0 +1,118 @@
+package stats
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"github.com/buglloc/sowettybot/internal/models"
+)
+
+type Summary struct {
+	Name   string
+	Count  int
+	First  float64
+	Last   float64
+	LastAt time.Time
+	Min    float64
+	MinAt  time.Time
+	Max    float64
+	MaxAt  time.Time
+	Mean   float64
+	Median float64
+	StdDev float64
+	// Volatility is the standard deviation of relative changes between neighbour values, in percents
+	Volatility float64
+}
+
+// Summarize calculates per-exchange statistics, zero values (failed fetches) are skipped.
+func Summarize(entries []models.History) []Summary {
+	if len(entries) == 0 {
+		return nil
+	}
+
+	out := make([]Summary, len(entries[0].Names))
+	for i, name := range entries[0].Names {
+		out[i].Name = name
+
+		var values []float64
+		for _, entry := range entries {
+			if i >= len(entry.Values) || entry.Values[i] == 0.0 {
+				continue
+			}
+
+			v := entry.Values[i]
+			s := &out[i]
+			if s.Count == 0 {
+				s.First = v
+				s.Min, s.MinAt = v, entry.When
+				s.Max, s.MaxAt = v, entry.When
+			}
+
+			if v < s.Min {
+				s.Min, s.MinAt = v, entry.When
+			}
+
+			if v > s.Max {
+				s.Max, s.MaxAt = v, entry.When
+			}
+
+			s.Last, s.LastAt = v, entry.When
+			s.Count++
+			values = append(values, v)
+		}
+
+		if out[i].Count > 0 {
+			out[i].Mean = mean(values)
+			out[i].Median = median(values)
+			out[i].StdDev = stdDev(values, out[i].Mean)
+			out[i].Volatility = volatility(values)
+		}
+	}
+
+	return out
+}
+
+func mean(values []float64) float64 {
+	var sum float64
+	for _, v := range values {
+		sum += v
+	}
+
+	return sum / float64(len(values))
+}
+
+func median(values []float64) float64 {
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+
+	mid := len(sorted) / 2
+	if len(sorted)%2 == 0 {
+		return (sorted[mid-1] + sorted[mid]) / 2
+	}
+
+	return sorted[mid]
+}
+
+// stdDev is the population standard deviation
+func stdDev(values []float64, mean float64) float64 {
+	var sum float64
+	for _, v := range values {
+		sum += (v - mean) * (v - mean)
+	}
+
+	return math.Sqrt(sum / float64(len(values)))
+}
+
+func volatility(values []float64) float64 {
+	if len(values) < 2 {
+		return 0
+	}
+
+	changes := make([]float64, len(values)-1)
+	for i := 1; i < len(values); i++ {
+		changes[i-1] = (values[i] - values[i-1]) / values[i-1] * 100
+	}
+
+	return stdDev(changes, mean(changes))
+}
@@ -0,0 +1,41 @@
+package stats
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/buglloc/sowettybot/internal/models"
+)
+
+func TestSummarize(t *testing.T) {
+	start := time.Date(2023, 6, 1, 0, 0, 0, 0, time.UTC)
+	values := []float64{3.0, 2.9, 0, 3.1, 2.8}
+	entries := make([]models.History, len(values))
+	for i, v := range values {
+		entries[i] = models.History{
+			When:   start.Add(time.Duration(i) * time.Hour),
+			Names:  []string{"korona"},
+			Values: []float64{v},
+		}
+	}
+
+	out := Summarize(entries)
+	require.Len(t, out, 1)
+
+	s := out[0]
+	require.Equal(t, "korona", s.Name)
+	require.Equal(t, 4, s.Count)
+	require.Equal(t, 3.0, s.First)
+	require.Equal(t, 2.8, s.Last)
+	require.Equal(t, start.Add(4*time.Hour), s.LastAt)
+	require.Equal(t, 2.8, s.Min)
+	require.Equal(t, start.Add(4*time.Hour), s.MinAt)
+	require.Equal(t, 3.1, s.Max)
+	require.Equal(t, start.Add(3*time.Hour), s.MaxAt)
+	require.InDelta(t, 2.95, s.Mean, 1e-9)
+	require.InDelta(t, 2.95, s.Median, 1e-9)
+	require.InDelta(t, 0.1118, s.StdDev, 1e-4)
+	require.Greater(t, s.Volatility, 0.0)
+}
@@ -0,0 +1,88 @@
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+type Storage struct {
+	dir string
+	mu  sync.Mutex
+}
+
+func NewStorage(dir string) *Storage {
+	return &Storage{
+		dir: dir,
+	}
+}
+
+func (s *Storage) Load(name string, out interface{}) error {
+	if s.dir == "" {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data, err := os.ReadFile(s.path(name))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+
+		return fmt.Errorf("unable to read %q: %w", name, err)
+	}
+
+	if err := json.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("invalid %q state: %w", name, err)
+	}
+
+	return nil
+}
+
+func (s *Storage) Save(name string, in interface{}) error {
+	if s.dir == "" {
+		return nil
+	}
+
+	data, err := json.MarshalIndent(in, "", "  ")
+	if err != nil {
+		return fmt.Errorf("unable to marshal %q: %w", name, err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := os.MkdirAll(s.dir, 0o700); err != nil {
+		return fmt.Errorf("unable to create storage dir: %w", err)
+	}
+
+	f, err := os.CreateTemp(s.dir, name+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("create temporary file: %w", err)
+	}
+	defer func() { _ = os.RemoveAll(f.Name()) }()
+
+	if _, err := f.Write(data); err != nil {
+		_ = f.Close()
+		return fmt.Errorf("unable to write %q: %w", name, err)
+	}
+
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("unable to write %q: %w", name, err)
+	}
+
+	if err := os.Rename(f.Name(), s.path(name)); err != nil {
+		return fmt.Errorf("unable to replace %q: %w", name, err)
+	}
+
+	return nil
+}
+
+func (s *Storage) path(name string) string {
+	return filepath.Join(s.dir, name+".json")
+}